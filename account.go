@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+)
+
+// accountTags are the Organizations tags of the account scanned, nil unless requested
+var accountTags map[string]string
+
+// getAccountTags looks up the tags of an account in Organizations, which only the management
+// account and the delegated administrators can read
+func getAccountTags(ctx context.Context, config aws.Config, account string) (map[string]string, error) {
+	client := organizations.New(config)
+	tags := make(map[string]string)
+	var token *string
+	for {
+		response, err := client.ListTagsForResourceRequest(&organizations.ListTagsForResourceInput{
+			ResourceId: aws.String(account),
+			NextToken:  token,
+		}).Send(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the Organizations tags of account %s, %v", account, err)
+		}
+		for _, tag := range response.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		token = response.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return tags, nil
+}
+
+// accountTagMismatches are the keys whose value on the resource differs from the value on its
+// account, e.g. a resource of another business unit than its account
+func accountTagMismatches(tags map[string]string, keys []string) []string {
+	var messages []string
+	for _, key := range keys {
+		expected, ok := lookupTag(accountTags, key)
+		if !ok || expected == "" {
+			continue
+		}
+		if value, ok := lookupTag(tags, key); ok && value != "" && !strings.EqualFold(value, expected) {
+			messages = append(messages, fmt.Sprintf("account tag: %s is %q, the account's is %q", key, value, expected))
+		}
+	}
+	sort.Strings(messages)
+	return messages
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+)
+
+// tagChange is the tags to add to a resource
+type tagChange struct {
+	arn  string
+	tags map[string]string
+}
+
+// apply runs the apply subcommand: apply [-dry-run] [-rate n] [-log file] [-emit-script file]
+// remediationFile, adding the tags of the remediation file to each of its resources
+func apply(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report apply", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var flags taggerFlags
+	flags.register(fs, "added")
+	delimiter := fs.String("csv-delimiter", ",", "csv field delimiter of the remediation file and of the log, a single character or \"tab\"")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report apply [options] remediationFile"+
+			"\n\tadds the tags of the remediation file to its resources, logging the result of each resource"+
+			"\n\tremediationFile: a migration report, whose ARN and Tags To Add columns are read, a report of"+
+			"\n\t                 -suggest-tags, whose ARN and Suggested Tags columns are read, or a json object"+
+			"\n\t                 mapping resource ARNs to the tags to add"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if err := flags.validate(); err != nil {
+		return err
+	}
+	var dialect CSVDialect
+	var err error
+	if dialect.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		return err
+	}
+	changes, err := loadRemediation(fs.Arg(0), dialect)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tagger, closeTagger, err := flags.open(context.TODO(), cfg, w, dialect)
+	if err != nil {
+		return err
+	}
+	defer closeTagger()
+
+	for _, change := range changes {
+		tagger.tag(change.arn, change.tags)
+	}
+	fmt.Fprintf(stderr, "%d resources, %d failed\n", len(changes), tagger.failed)
+	if tagger.failed > 0 {
+		return fmt.Errorf("tagging failed for %d resources", tagger.failed)
+	}
+	return nil
+}
+
+// openTaggerLog opens the log of the changes, appending to path when set, and tells
+// whether it appends to a previous log
+func openTaggerLog(w io.Writer, path string) (io.WriteCloser, bool, error) {
+	if path == "" {
+		return nopCloser{w}, false, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return f, info.Size() > 0, nil
+}
+
+// loadRemediation reads the tags to add to each resource, from a json object mapping the
+// ARNs to their tags or from the ARN and Tags To Add columns of a csv migration report, or
+// the Suggested Tags column of a report of -suggest-tags
+func loadRemediation(path string, dialect CSVDialect) ([]tagChange, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var changes []tagChange
+	if strings.HasSuffix(path, ".json") {
+		var remediation map[string]map[string]string
+		if err := json.Unmarshal(body, &remediation); err != nil {
+			return nil, fmt.Errorf("remediation %s: %v", path, err)
+		}
+		for arn, tags := range remediation {
+			if len(tags) > 0 {
+				changes = append(changes, tagChange{arn, tags})
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].arn < changes[j].arn
+		})
+		return changes, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\uFEFF"))))
+	if dialect.Delimiter != 0 {
+		reader.Comma = dialect.Delimiter
+	}
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("remediation %s: %v", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	index := make(map[string]int)
+	for i, column := range rows[0] {
+		index[column] = i
+	}
+	arnColumn, ok := index["ARN"]
+	tagsColumn, ok2 := index["Tags To Add"]
+	if !ok2 {
+		tagsColumn, ok2 = index["Suggested Tags"]
+	}
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("remediation %s: expected the ARN and Tags To Add or Suggested Tags columns", path)
+	}
+	for line, row := range rows[1:] {
+		var tags map[string]string
+		if row[tagsColumn] != "" {
+			if err := json.Unmarshal([]byte(row[tagsColumn]), &tags); err != nil {
+				return nil, fmt.Errorf("remediation %s line %d: %v", path, line+2, err)
+			}
+		}
+		if len(tags) == 0 {
+			continue
+		} else if row[arnColumn] == "" {
+			return nil, fmt.Errorf("remediation %s line %d: the resource has no ARN", path, line+2)
+		}
+		changes = append(changes, tagChange{row[arnColumn], tags})
+	}
+	return changes, nil
+}
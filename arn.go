@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// arnResolver builds the ARN of a resource from its cloudformation physical id
+type arnResolver func(resourceType string, id string) string
+
+// newArnResolver knows the ARN format of the supported resource types, for other types
+// the physical id is used when it already is an ARN; the ARNs read from the stack outputs
+// with -arns-from-outputs come before the formats
+func newArnResolver(partition string, region string, account string) arnResolver {
+	// arn:partition:service::account-id:resource-type/resource-id for global services
+	global := func(service string, resource string) func(string) string {
+		return func(id string) string {
+			return fmt.Sprintf("arn:%s:%s::%s:%s/%s", partition, service, account, resource, id)
+		}
+	}
+	formats := map[string]func(string) string{
+		"AWS::Lambda::Function": arnF3(partition, serviceRegion(region, "lambda"), account, "lambda", "function"),
+		"AWS::SSM::Parameter": func(id string) string {
+			// parameter hierarchies already start with a slash
+			return arnF2(partition, serviceRegion(region, "ssm"), account, "ssm", "parameter")(strings.TrimPrefix(id, "/"))
+		},
+		"AWS::ServiceCatalog::CloudFormationProduct": arnF2(partition, serviceRegion(region, "servicecatalog"), account, "catalog", "product"),
+		"AWS::ServiceCatalog::Portfolio":             arnF2(partition, serviceRegion(region, "servicecatalog"), account, "catalog", "portfolio"),
+		"AWS::S3::Bucket": func(id string) string {
+			return fmt.Sprintf("arn:%s:s3:::%s", partition, id)
+		},
+		"AWS::IAM::Role":                global("iam", "role"),
+		"AWS::IAM::InstanceProfile":     global("iam", "instance-profile"),
+		"AWS::CloudFront::Distribution": global("cloudfront", "distribution"),
+		"AWS::Route53::HostedZone": func(id string) string {
+			return fmt.Sprintf("arn:%s:route53:::hostedzone/%s", partition, id)
+		},
+		"AWS::EC2::LaunchTemplate":             arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "launch-template"),
+		"AWS::EC2::RouteTable":                 arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "route-table"),
+		"AWS::EC2::SecurityGroup":              arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "security-group"),
+		"AWS::EC2::Subnet":                     arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "subnet"),
+		"AWS::EC2::VPC":                        arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "vpc"),
+		"AWS::EC2::VPCEndpoint":                arnF2(partition, serviceRegion(region, "ec2"), account, "ec2", "vpc-endpoint"),
+		"AWS::Glue::Crawler":                   arnF2(partition, serviceRegion(region, "glue"), account, "glue", "crawler"),
+		"AWS::Glue::Job":                       arnF2(partition, serviceRegion(region, "glue"), account, "glue", "job"),
+		"AWS::Glue::Trigger":                   arnF2(partition, serviceRegion(region, "glue"), account, "glue", "trigger"),
+		"AWS::Glue::Database":                  arnF2(partition, serviceRegion(region, "glue"), account, "glue", "database"),
+		"AWS::DynamoDB::Table":                 arnF2(partition, serviceRegion(region, "dynamodb"), account, "dynamodb", "table"),
+		"AWS::KinesisFirehose::DeliveryStream": arnF2(partition, serviceRegion(region, "firehose"), account, "firehose", "deliverystream"),
+		"AWS::Logs::LogGroup":                  arnF3(partition, serviceRegion(region, "cloudwatchlogs"), account, "logs", "log-group"),
+		"AWS::Cloudwatch::Alarm":               arnF3(partition, serviceRegion(region, "cloudwatch"), account, "cloudwatch", "alarm"),
+		"AWS::Events::Rule":                    arnF2(partition, serviceRegion(region, "cloudwatchevents"), account, "events", "rule"),
+		"AWS::Config::ConfigRule":              arnF2(partition, serviceRegion(region, "configservice"), account, "config", "config-rule"),
+		"AWS::KMS::Key":                        arnF2(partition, serviceRegion(region, "kms"), account, "kms", "key"),
+	}
+	return func(resourceType string, id string) string {
+		if strings.HasPrefix(id, "arn:") {
+			return id
+		}
+		if arn, ok := arnOverrides[id]; ok {
+			return arn
+		}
+		if format, ok := formats[resourceType]; ok {
+			return format(id)
+		}
+		return ""
+	}
+}
+
+// arnService extracts the service namespace of an ARN
+func arnService(arn string) string {
+	split := strings.SplitN(arn, ":", 4)
+	if len(split) < 4 {
+		return ""
+	}
+	return split[2]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+)
+
+// athenaQuery runs a query with Athena and returns the rows of its results, without the
+// header; the results are written to output, or to the output location of the primary
+// workgroup when empty
+func athenaQuery(ctx context.Context, config aws.Config, database string, query string, output string) [][]string {
+	client := athena.New(config)
+	input := &athena.StartQueryExecutionInput{
+		QueryString:           aws.String(query),
+		QueryExecutionContext: &athena.QueryExecutionContext{Database: aws.String(database)},
+	}
+	if output != "" {
+		input.ResultConfiguration = &athena.ResultConfiguration{OutputLocation: aws.String(output)}
+	}
+	started, err := client.StartQueryExecutionRequest(input).Send(ctx)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for {
+		response, err := client.GetQueryExecutionRequest(&athena.GetQueryExecutionInput{
+			QueryExecutionId: started.QueryExecutionId,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		status := response.QueryExecution.Status
+		if status.State == athena.QueryExecutionStateSucceeded {
+			break
+		} else if status.State == athena.QueryExecutionStateFailed || status.State == athena.QueryExecutionStateCancelled {
+			panic(fmt.Sprintf("athena query %s %s: %s", aws.StringValue(started.QueryExecutionId),
+				status.State, aws.StringValue(status.StateChangeReason)))
+		}
+		time.Sleep(time.Second)
+	}
+
+	var rows [][]string
+	var token *string
+	header := true
+	for {
+		response, err := client.GetQueryResultsRequest(&athena.GetQueryResultsInput{
+			QueryExecutionId: started.QueryExecutionId,
+			NextToken:        token,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, row := range response.ResultSet.Rows {
+			// the first row of the first page names the columns
+			if header {
+				header = false
+				continue
+			}
+			var cells []string
+			for _, datum := range row.Data {
+				cells = append(cells, aws.StringValue(datum.VarCharValue))
+			}
+			rows = append(rows, cells)
+		}
+		token = response.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return rows
+}
@@ -2,33 +2,399 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"sort"
 	"strings"
+	"time"
 )
 
-func getStackResources(ctx context.Context, config aws.Config, search *string) []cloudformation.StackResource {
+// StackResource is a cloudformation resource along with the details gathered from its stack
+type StackResource struct {
+	cloudformation.StackResource
+	// ConstructPath is the aws:cdk:path metadata of resources deployed by the CDK
+	ConstructPath string
+	Stack         Stack
+	// Skipped is set for the stacks whose resources could not be listed
+	Skipped error
+}
+
+// Resource is the resource as reported
+func (r StackResource) Resource() Resource {
+	return Resource{
+		Type:          aws.StringValue(r.ResourceType),
+		Name:          aws.StringValue(r.PhysicalResourceId),
+		ConstructPath: r.ConstructPath,
+		Stack:         r.Stack,
+		LastUpdated:   aws.TimeValue(r.Timestamp),
+	}
+}
+
+// Stack identifies the stack a resource belongs to and how the stack was deployed
+type Stack struct {
+	Name string
+	Id   string
+	// Origin is SERVICE_CATALOG for provisioned products, PIPELINE for stacks deployed
+	// through a cloudformation service role, as pipelines do, and CUSTOM otherwise; with
+	// -created-by-cloudtrail it is the ARN of the principal that created the stack instead
+	Origin          string
+	CreationTime    time.Time
+	LastUpdatedTime time.Time
+	// Environment is the environment of the tag policy the stack is in, if any
+	Environment string
+	// Tags are the tags of the stack itself
+	Tags map[string]string
+	// Outputs are the values of the outputs of the stack by output key, and Exports those
+	// of its exported outputs by export name
+	Outputs map[string]string
+	Exports map[string]string
+}
+
+func newStack(stack cloudformation.Stack) Stack {
+	origin := "CUSTOM"
+	if stack.RoleARN != nil {
+		origin = "PIPELINE"
+	}
+	tags := make(map[string]string)
+	for _, tag := range stack.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		if strings.HasPrefix(aws.StringValue(tag.Key), "aws:servicecatalog:") {
+			origin = "SERVICE_CATALOG"
+		}
+	}
+	outputs := make(map[string]string)
+	exports := make(map[string]string)
+	for _, output := range stack.Outputs {
+		outputs[aws.StringValue(output.OutputKey)] = aws.StringValue(output.OutputValue)
+		if output.ExportName != nil {
+			exports[aws.StringValue(output.ExportName)] = aws.StringValue(output.OutputValue)
+		}
+	}
+	return Stack{
+		Name:            aws.StringValue(stack.StackName),
+		Id:              aws.StringValue(stack.StackId),
+		Origin:          origin,
+		CreationTime:    aws.TimeValue(stack.CreationTime),
+		LastUpdatedTime: aws.TimeValue(stack.LastUpdatedTime),
+		Environment:     stackEnvironment(aws.StringValue(stack.StackName), tags),
+		Tags:            tags,
+		Outputs:         outputs,
+		Exports:         exports,
+	}
+}
+
+func getStackResources(ctx context.Context, config aws.Config, search *string) []StackResource {
 	sc := *servicecatalog.New(config)
 	cf := *cloudformation.New(config)
 
-	var resources []cloudformation.StackResource
-	for _, stack := range listStacks(ctx, cf, search) {
-		for _, resource := range describeStackResources(ctx, cf, stack.StackName) {
+	var fallback *cloudformation.Client
+	var resources []StackResource
+	for _, s := range describeStacks(ctx, cf, search) {
+		stack := newStack(s)
+		client := cf
+		stackResources, err := listStackResources(ctx, client, s.StackName)
+		if isAccessDenied(err) && fallbackRoleArn != "" {
+			if fallback == nil {
+				fallback = cloudformation.New(fallbackConfig(config, fallbackRoleArn))
+			}
+			client = *fallback
+			stackResources, err = listStackResources(ctx, client, s.StackName)
+		}
+		if isAccessDenied(err) {
+			resources = append(resources, skippedStackResource(stack, err))
+			continue
+		} else if err != nil {
+			panic(err.Error())
+		}
+		paths := getConstructPaths(ctx, client, s.StackName)
+		for _, resource := range stackResources {
 			if "AWS::ServiceCatalog::CloudFormationProduct" == *resource.ResourceType {
 				for _, product := range searchProvisionedProducts(ctx, sc, resource.PhysicalResourceId) {
-					resources = append(resources, getStackResources(ctx, config, product.Id)...)
+					productStack := getStackResources(ctx, config, product.Id)
+					if includeProducts {
+						resources = append(resources, productResources(product, productStack)...)
+					}
+					resources = append(resources, productStack...)
 				}
 			} else {
-				resources = append(resources, resource)
+				resources = append(resources, StackResource{resource, paths[*resource.LogicalResourceId], stack, nil})
+			}
+		}
+	}
+	return resources
+}
+
+// stackResources returns the stacks of the resources as resources of their own
+func stackResources(resources []StackResource) []StackResource {
+	var stacks []StackResource
+	seen := make(map[string]bool)
+	for _, resource := range resources {
+		stack := resource.Stack
+		// a skipped stack is already reported as a resource of its own
+		if seen[stack.Id] || resource.Skipped != nil {
+			continue
+		}
+		seen[stack.Id] = true
+		timestamp := stack.LastUpdatedTime
+		if timestamp.IsZero() {
+			timestamp = stack.CreationTime
+		}
+		stacks = append(stacks, StackResource{
+			StackResource: cloudformation.StackResource{
+				LogicalResourceId:  aws.String(stack.Name),
+				PhysicalResourceId: aws.String(stack.Id),
+				ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+				StackId:            aws.String(stack.Id),
+				StackName:          aws.String(stack.Name),
+				Timestamp:          aws.Time(timestamp),
+			},
+			Stack: stack,
+		})
+	}
+	return stacks
+}
+
+// getStackTags looks up the tags of a stack, or of a nested stack, by id
+func getStackTags(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+	cf := cloudformation.New(config)
+	response, err := cf.DescribeStacksRequest(&cloudformation.DescribeStacksInput{StackName: aws.String(id)}).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Stacks) == 0 {
+		return nil, fmt.Errorf("stack %s not found", id)
+	}
+	return newStack(response.Stacks[0]).Tags, nil
+}
+
+// getDistributionTags looks up the tags of a CloudFront distribution by id, the tags being
+// nested in a list of items which the generic lookup does not read
+func getDistributionTags(client *cloudfront.Client, arn func(string) string) func(context.Context, aws.Config, string) (map[string]string, error) {
+	return func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+		response, err := client.ListTagsForResourceRequest(&cloudfront.ListTagsForResourceInput{
+			Resource: aws.String(arn(id)),
+		}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string]string)
+		if response.Tags != nil {
+			for _, tag := range response.Tags.Items {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		return tags, nil
+	}
+}
+
+// getHostedZoneTags looks up the tags of a Route 53 hosted zone by id, the tags being nested
+// in the tag set of the response which the generic lookup does not read
+func getHostedZoneTags(client *route53.Client) func(context.Context, aws.Config, string) (map[string]string, error) {
+	return func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+		response, err := client.ListTagsForResourceRequest(&route53.ListTagsForResourceInput{
+			ResourceId:   aws.String(id),
+			ResourceType: route53.TagResourceTypeHostedzone,
+		}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string]string)
+		if response.ResourceTagSet != nil {
+			for _, tag := range response.ResourceTagSet.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		return tags, nil
+	}
+}
+
+// getProductTags looks up the tags of a Service Catalog product by id, along with the values
+// of its active TagOptions whose key is not a tag of the product, the values of a key with
+// several options being joined with a comma; DescribeProduct returns neither, so reading them
+// requires servicecatalog:DescribeProductAsAdmin
+func getProductTags(client *servicecatalog.Client) func(context.Context, aws.Config, string) (map[string]string, error) {
+	return func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+		response, err := client.DescribeProductAsAdminRequest(&servicecatalog.DescribeProductAsAdminInput{
+			Id: aws.String(id),
+		}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tags := make(map[string]string)
+		for _, tag := range response.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		options := make(map[string][]string)
+		for _, option := range response.TagOptions {
+			if aws.BoolValue(option.Active) {
+				options[aws.StringValue(option.Key)] = append(options[aws.StringValue(option.Key)], aws.StringValue(option.Value))
+			}
+		}
+		for key, values := range options {
+			if _, ok := tags[key]; !ok {
+				sort.Strings(values)
+				tags[key] = strings.Join(values, ",")
+			}
+		}
+		return tags, nil
+	}
+}
+
+// getProvisionedProductTags looks up the tags of a Service Catalog provisioned product by id,
+// which only its search returns
+func getProvisionedProductTags(client *servicecatalog.Client) func(context.Context, aws.Config, string) (map[string]string, error) {
+	return func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+		for _, p := range searchProvisionedProducts(ctx, *client, aws.String("id:"+id)) {
+			if aws.StringValue(p.Id) != id {
+				continue
+			}
+			tags := make(map[string]string)
+			for _, tag := range p.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			return tags, nil
+		}
+		return nil, awserr.New(servicecatalog.ErrCodeResourceNotFoundException, "provisioned product "+id+" not found", nil)
+	}
+}
+
+// includeProducts reports the provisioned products and their products found by the scan
+// as resources of their own, with -include-products
+var includeProducts bool
+
+// productResources returns a provisioned product and its product as resources of their own
+// with -include-products, in the stack of the resources of the provisioned product if any
+func productResources(p servicecatalog.ProvisionedProductAttribute, resources []StackResource) []StackResource {
+	stack := Stack{
+		Name:         aws.StringValue(p.Name),
+		Id:           aws.StringValue(p.Arn),
+		Origin:       "SERVICE_CATALOG",
+		CreationTime: aws.TimeValue(p.CreatedTime),
+	}
+	if len(resources) > 0 {
+		stack = resources[0].Stack
+	}
+	return []StackResource{{
+		StackResource: cloudformation.StackResource{
+			LogicalResourceId:  p.Name,
+			PhysicalResourceId: p.Id,
+			ResourceType:       aws.String("AWS::ServiceCatalog::CloudFormationProvisionedProduct"),
+			StackName:          aws.String(stack.Name),
+			Timestamp:          p.CreatedTime,
+		},
+		Stack: stack,
+	}, {
+		StackResource: cloudformation.StackResource{
+			LogicalResourceId:  p.ProductId,
+			PhysicalResourceId: p.ProductId,
+			ResourceType:       aws.String("AWS::ServiceCatalog::CloudFormationProduct"),
+			StackName:          aws.String(stack.Name),
+			Timestamp:          p.CreatedTime,
+		},
+		Stack: stack,
+	}}
+}
+
+// uniqueProducts leaves out the products of productResources already reported for another of
+// their provisioned products, a product launched several times being reported once; the
+// searches of the resources are filtered alike
+func uniqueProducts(resources []StackResource, searches []string) ([]StackResource, []string) {
+	seen := make(map[string]bool)
+	var uniqueResources []StackResource
+	var uniqueSearches []string
+	for i, resource := range resources {
+		// the products of productResources are the ones without a stack id
+		if resource.StackId == nil && aws.StringValue(resource.ResourceType) == "AWS::ServiceCatalog::CloudFormationProduct" {
+			if seen[aws.StringValue(resource.PhysicalResourceId)] {
+				continue
 			}
+			seen[aws.StringValue(resource.PhysicalResourceId)] = true
+		}
+		uniqueResources = append(uniqueResources, resource)
+		uniqueSearches = append(uniqueSearches, searches[i])
+	}
+	return uniqueResources, uniqueSearches
+}
+
+// getConstructPaths maps the logical ids of a stack to the aws:cdk:path metadata found in its template
+func getConstructPaths(ctx context.Context, client cloudformation.Client, stackName *string) map[string]string {
+	input := &cloudformation.GetTemplateInput{
+		StackName: stackName,
+	}
+	request := client.GetTemplateRequest(input)
+	response, err := request.Send(ctx)
+	if isAccessDenied(err) {
+		// the construct paths are left out rather than the resources
+		return nil
+	} else if err != nil {
+		panic(err.Error())
+	}
+
+	// the cdk always synthesizes json templates, yaml ones have no construct paths
+	var template struct {
+		Resources map[string]json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(aws.StringValue(response.TemplateBody)), &template); err != nil {
+		return nil
+	}
+
+	paths := make(map[string]string)
+	for id, body := range template.Resources {
+		var resource struct {
+			Metadata map[string]interface{}
+		}
+		if err := json.Unmarshal(body, &resource); err != nil {
+			continue
+		}
+		if path, ok := resource.Metadata["aws:cdk:path"].(string); ok {
+			paths[id] = path
 		}
 	}
+	return paths
+}
+
+// getProvisionedProductResources collects the resources of the provisioned products selected by
+// provisioned product name or id, product id and/or product version (provisioning artifact id)
+func getProvisionedProductResources(ctx context.Context, config aws.Config, provisioned string, product string, version string) []StackResource {
+	sc := *servicecatalog.New(config)
+
+	query := provisioned
+	if query == "" && product != "" {
+		query = "productId:" + product
+	} else if query == "" {
+		query = "provisioningArtifactId:" + version
+	}
+
+	var resources []StackResource
+	for _, p := range searchProvisionedProducts(ctx, sc, &query) {
+		if provisioned != "" && provisioned != *p.Id && provisioned != *p.Name {
+			continue
+		}
+		if product != "" && product != aws.StringValue(p.ProductId) {
+			continue
+		}
+		if version != "" && version != aws.StringValue(p.ProvisioningArtifactId) {
+			continue
+		}
+		productStack := getStackResources(ctx, config, p.Id)
+		if includeProducts {
+			resources = append(resources, productResources(p, productStack)...)
+		}
+		resources = append(resources, productStack...)
+	}
 	return resources
 }
 
-func searchProvisionedProducts(ctx context.Context, client servicecatalog.Client, id *string) []servicecatalog.ProvisionedProductAttribute {
+func searchProvisionedProducts(ctx context.Context, client servicecatalog.Client, query *string) []servicecatalog.ProvisionedProductAttribute {
 	var provisionedProducts []servicecatalog.ProvisionedProductAttribute
 	var accessLevelFilterValueSelf = "self"
 	searchQuery, err := servicecatalog.ProvisionedProductViewFilterBySearchQuery.MarshalValue()
@@ -45,7 +411,7 @@ func searchProvisionedProducts(ctx context.Context, client servicecatalog.Client
 				Value: &accessLevelFilterValueSelf,
 			},
 			Filters: map[string][]string{
-				searchQuery: {*id},
+				searchQuery: {*query},
 			},
 		}
 		request := client.SearchProvisionedProductsRequest(input)
@@ -66,37 +432,49 @@ func searchProvisionedProducts(ctx context.Context, client servicecatalog.Client
 }
 
 func describeStackResources(ctx context.Context, client cloudformation.Client, stackName *string) []cloudformation.StackResource {
+	resources, err := listStackResources(ctx, client, stackName)
+	if err != nil {
+		panic(err.Error())
+	}
+	return resources
+}
+
+// listStackResources lists the resources of a stack, the error being returned for the
+// denied calls to be told apart
+func listStackResources(ctx context.Context, client cloudformation.Client, stackName *string) ([]cloudformation.StackResource, error) {
 	input := &cloudformation.DescribeStackResourcesInput{
 		StackName: stackName,
 	}
 	request := client.DescribeStackResourcesRequest(input)
 	response, err := request.Send(ctx)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
-	return response.StackResources
+	return response.StackResources, nil
 }
 
-func listStacks(ctx context.Context, client cloudformation.Client, search *string) []cloudformation.StackSummary {
-	var stacks []cloudformation.StackSummary
+// describeStacks lists the complete stacks whose name contains search, described
+// in full to know their role and tags
+func describeStacks(ctx context.Context, client cloudformation.Client, search *string) []cloudformation.Stack {
+	var stacks []cloudformation.Stack
 	var token *string
 	for {
-		input := &cloudformation.ListStacksInput{
+		input := &cloudformation.DescribeStacksInput{
 			NextToken: token,
-			StackStatusFilter: []cloudformation.StackStatus{
-				cloudformation.StackStatusCreateComplete,
-				cloudformation.StackStatusUpdateComplete,
-			},
 		}
 
-		request := client.ListStacksRequest(input)
+		request := client.DescribeStacksRequest(input)
 		response, err := request.Send(ctx)
 		if err != nil {
 			panic(err.Error())
 		}
 
 		token = response.NextToken
-		for _, s := range response.StackSummaries {
+		for _, s := range response.Stacks {
+			if s.StackStatus != cloudformation.StackStatusCreateComplete &&
+				s.StackStatus != cloudformation.StackStatusUpdateComplete {
+				continue
+			}
 			if strings.Contains(*s.StackName, *search) {
 				stacks = append(stacks, s)
 			}
@@ -116,4 +494,148 @@ func getAccount(ctx context.Context, config aws.Config) string {
 		panic(err)
 	}
 	return *response.Account
-}
\ No newline at end of file
+}
+
+// getPartition resolves the partition (aws, aws-cn, aws-us-gov...) of the configured region
+func getPartition(config aws.Config) string {
+	endpoint, err := config.EndpointResolver.ResolveEndpoint(sts.EndpointsID, config.Region)
+	if err != nil || endpoint.PartitionID == "" {
+		return "aws"
+	}
+	return endpoint.PartitionID
+}
+
+// RequiredTagsRule is an AWS Config required-tags managed rule, with the compliance of
+// the resources it evaluated by type and physical id
+type RequiredTagsRule struct {
+	Name       string
+	Keys       []string
+	Compliance map[string]configservice.ComplianceType
+}
+
+// requiredTagsKey identifies a resource evaluated by a Config rule
+func requiredTagsKey(resourceType string, id string) string {
+	return resourceType + "/" + id
+}
+
+// getRequiredTagsRules reads the required-tags rules deployed to AWS Config, along with
+// their latest evaluations
+func getRequiredTagsRules(ctx context.Context, config aws.Config) []RequiredTagsRule {
+	client := configservice.New(config)
+
+	var rules []RequiredTagsRule
+	var token *string
+	for {
+		response, err := client.DescribeConfigRulesRequest(&configservice.DescribeConfigRulesInput{
+			NextToken: token,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, rule := range response.ConfigRules {
+			if rule.Source == nil || aws.StringValue(rule.Source.SourceIdentifier) != "REQUIRED_TAGS" {
+				continue
+			}
+			rules = append(rules, RequiredTagsRule{
+				Name:       aws.StringValue(rule.ConfigRuleName),
+				Keys:       requiredTagsKeys(aws.StringValue(rule.InputParameters)),
+				Compliance: getRuleCompliance(ctx, client, rule.ConfigRuleName),
+			})
+		}
+		token = response.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return rules
+}
+
+// requiredTagsKeys reads the tag1Key to tag6Key parameters of a required-tags rule
+func requiredTagsKeys(parameters string) []string {
+	var values map[string]string
+	if err := json.Unmarshal([]byte(parameters), &values); err != nil {
+		return nil
+	}
+	var keys []string
+	for i := 1; i <= 6; i++ {
+		if key := values[fmt.Sprintf("tag%dKey", i)]; key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func getRuleCompliance(ctx context.Context, client *configservice.Client, name *string) map[string]configservice.ComplianceType {
+	compliance := make(map[string]configservice.ComplianceType)
+	var token *string
+	for {
+		response, err := client.GetComplianceDetailsByConfigRuleRequest(&configservice.GetComplianceDetailsByConfigRuleInput{
+			ConfigRuleName: name,
+			NextToken:      token,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, result := range response.EvaluationResults {
+			if result.EvaluationResultIdentifier == nil || result.EvaluationResultIdentifier.EvaluationResultQualifier == nil {
+				continue
+			}
+			qualifier := result.EvaluationResultIdentifier.EvaluationResultQualifier
+			compliance[requiredTagsKey(aws.StringValue(qualifier.ResourceType), aws.StringValue(qualifier.ResourceId))] = result.ComplianceType
+		}
+		token = response.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return compliance
+}
+
+// listCostAllocationTagsInput is the input of the ListCostAllocationTags operation of the
+// cost explorer, which this version of the SDK predates
+type listCostAllocationTagsInput struct {
+	_          struct{} `type:"structure"`
+	Type       *string  `type:"string"`
+	MaxResults *int64   `type:"integer"`
+	NextToken  *string  `type:"string"`
+}
+
+type listCostAllocationTagsOutput struct {
+	_                  struct{}            `type:"structure"`
+	CostAllocationTags []costAllocationTag `type:"list"`
+	NextToken          *string             `type:"string"`
+}
+
+type costAllocationTag struct {
+	_      struct{} `type:"structure"`
+	TagKey *string  `type:"string"`
+	Type   *string  `type:"string"`
+	Status *string  `type:"string"`
+}
+
+// getCostAllocationTags maps the user defined tag keys known to billing to their cost
+// allocation status, Active or Inactive
+func getCostAllocationTags(ctx context.Context, config aws.Config) map[string]string {
+	client := costexplorer.New(config)
+	operation := &aws.Operation{Name: "ListCostAllocationTags", HTTPMethod: "POST", HTTPPath: "/"}
+
+	statuses := make(map[string]string)
+	var token *string
+	for {
+		input := &listCostAllocationTagsInput{Type: aws.String("UserDefined"), MaxResults: aws.Int64(1000), NextToken: token}
+		output := &listCostAllocationTagsOutput{}
+		request := client.NewRequest(operation, input, output)
+		request.SetContext(ctx)
+		if err := request.Send(); err != nil {
+			panic(err.Error())
+		}
+		for _, tag := range output.CostAllocationTags {
+			statuses[aws.StringValue(tag.TagKey)] = aws.StringValue(tag.Status)
+		}
+		token = output.NextToken
+		if token == nil {
+			break
+		}
+	}
+	return statuses
+}
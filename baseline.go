@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// the changes of a resource since the baseline
+const (
+	changeNew       = "NEW"
+	changeImproved  = "IMPROVED"
+	changeRegressed = "REGRESSED"
+	changeUnchanged = "UNCHANGED"
+)
+
+// Baseline is the modern coverage of the resources of a previous csv or json report,
+// the current resources being compared with to tell what moved
+type Baseline struct {
+	// coverage is nil for the resources whose tags were not looked up
+	coverage map[string]*int
+	// resources describes the resources by key
+	resources map[string]baselineResource
+}
+
+// baselineResource is a resource of a previous report
+type baselineResource struct {
+	Type        string
+	Name        string
+	Stack       string
+	MissingTags []string
+	// Arn is empty when the report did not know it
+	Arn string
+}
+
+// baselineKey identifies a resource across reports by its ARN, or by its stack and
+// physical id when the ARN is unknown
+func baselineKey(arn string, stack string, name string) string {
+	if arn != "" {
+		return arn
+	}
+	return stack + "/" + name
+}
+
+// loadBaseline reads a report written with the csv, json or jsonl format, optionally gzip
+// compressed, the format being told by the file extension
+func loadBaseline(path string, dialect CSVDialect) (*Baseline, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := path
+	if strings.HasSuffix(name, ".gz") {
+		name = strings.TrimSuffix(name, ".gz")
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("baseline %s: %v", path, err)
+		}
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("baseline %s: %v", path, err)
+		}
+	}
+
+	baseline := &Baseline{coverage: make(map[string]*int), resources: make(map[string]baselineResource)}
+	if strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl") {
+		err = baseline.readJSON(body)
+	} else {
+		err = baseline.readCSV(body, dialect)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("baseline %s: %v", path, err)
+	}
+	return baseline, nil
+}
+
+// readJSON reads a json array of records or json lines
+func (b *Baseline) readJSON(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+	for decoder.More() {
+		var record jsonRecord
+		if err := decoder.Decode(&record); err != nil {
+			return err
+		}
+		key := baselineKey(record.Arn, record.Stack, record.Id)
+		b.coverage[key] = record.ModernCoverage
+		b.resources[key] = baselineResource{record.Type, record.Id, record.Stack, record.MissingTags, record.Arn}
+	}
+	return nil
+}
+
+// readCSV reads a csv report, locating the columns by their header
+func (b *Baseline) readCSV(body []byte, dialect CSVDialect) error {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\uFEFF"))))
+	if dialect.Delimiter != 0 {
+		reader.Comma = dialect.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	columns, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int)
+	for i, column := range columns {
+		index[column] = i
+	}
+	for _, column := range []string{"Resource Name", "Modern Coverage"} {
+		if _, ok := index[column]; !ok {
+			return fmt.Errorf("missing the %s column", column)
+		}
+	}
+	cell := func(row []string, column string) string {
+		if i, ok := index[column]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var coverage *int
+		if value, err := strconv.Atoi(strings.TrimSuffix(cell(row, "Modern Coverage"), "%")); err == nil {
+			coverage = &value
+		}
+		key := baselineKey(cell(row, "ARN"), cell(row, "Stack Name"), cell(row, "Resource Name"))
+		b.coverage[key] = coverage
+		var missing []string
+		if value := cell(row, "Missing Tags"); value != "" {
+			missing = strings.Split(value, ",")
+		}
+		b.resources[key] = baselineResource{cell(row, "Type"), cell(row, "Resource Name"), cell(row, "Stack Name"), missing, cell(row, "ARN")}
+	}
+}
+
+// change compares the modern coverage of a resource with the baseline, resources whose
+// tags are unknown now or then being unchanged
+func (b *Baseline) change(key string, coverage *int) string {
+	previous, ok := b.coverage[key]
+	switch {
+	case !ok:
+		return changeNew
+	case previous == nil || coverage == nil || *previous == *coverage:
+		return changeUnchanged
+	case *coverage > *previous:
+		return changeImproved
+	default:
+		return changeRegressed
+	}
+}
+
+// changeSummary counts the changes since the baseline, written to w on Close along with
+// the resources of the baseline no longer found
+type changeSummary struct {
+	w        io.Writer
+	baseline *Baseline
+	counts   map[string]int
+	seen     map[string]bool
+}
+
+func newChangeSummary(w io.Writer, baseline *Baseline) *changeSummary {
+	return &changeSummary{
+		w:        w,
+		baseline: baseline,
+		counts:   make(map[string]int),
+		seen:     make(map[string]bool),
+	}
+}
+
+// Add counts the change of a resource, those whose tags are unknown being unchanged
+func (c *changeSummary) Add(result Result) {
+	var coverage *int
+	if result.Finding != nil {
+		coverage = &result.Finding.ModernCoverage
+	}
+	key := baselineKey(result.Arn, result.Resource.Stack.Name, result.Resource.Name)
+	c.seen[key] = true
+	c.counts[c.baseline.change(key, coverage)]++
+}
+
+// Write is a no-op, the summary is written on Close
+func (c *changeSummary) Write() {
+}
+
+func (c *changeSummary) Close() {
+	removed := 0
+	for key := range c.baseline.coverage {
+		if !c.seen[key] {
+			removed++
+		}
+	}
+	fmt.Fprintf(c.w, "changes since the baseline: %d new, %d improved, %d regressed, %d unchanged, %d removed\n",
+		c.counts[changeNew], c.counts[changeImproved], c.counts[changeRegressed], c.counts[changeUnchanged], removed)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+)
+
+// cloudTrailEvent is the part of the CloudTrail record of an event identifying its caller
+// and the stack it applies to
+type cloudTrailEvent struct {
+	UserIdentity struct {
+		Arn string `json:"arn"`
+	} `json:"userIdentity"`
+	ResponseElements struct {
+		StackId string `json:"stackId"`
+	} `json:"responseElements"`
+}
+
+// principal is the ARN of the caller, the service for the calls AWS services make
+func (e cloudTrailEvent) principal(event cloudtrail.Event) string {
+	if e.UserIdentity.Arn != "" {
+		return e.UserIdentity.Arn
+	}
+	return aws.StringValue(event.Username)
+}
+
+// stackCreators resolves the principal that created each stack from its CreateStack event,
+// looked up once per stack
+type stackCreators struct {
+	ctx      context.Context
+	client   *cloudtrail.Client
+	creators map[string]string
+}
+
+func newStackCreators(ctx context.Context, config aws.Config) *stackCreators {
+	return &stackCreators{
+		ctx:      ctx,
+		client:   cloudtrail.New(config),
+		creators: make(map[string]string),
+	}
+}
+
+// creator returns the ARN of the principal that created the stack, or its origin when
+// CloudTrail no longer has the event, only keeping the last 90 days
+func (c *stackCreators) creator(stack Stack) string {
+	creator, ok := c.creators[stack.Id]
+	if !ok {
+		creator = c.lookup(stack)
+		c.creators[stack.Id] = creator
+	}
+	if creator == "" {
+		return stack.Origin
+	}
+	return creator
+}
+
+// lookup searches the CreateStack events around the creation of the stack for the one
+// that created it
+func (c *stackCreators) lookup(stack Stack) string {
+	if stack.CreationTime.IsZero() || time.Since(stack.CreationTime) > 90*24*time.Hour {
+		return ""
+	}
+	var token *string
+	for {
+		response, err := c.client.LookupEventsRequest(&cloudtrail.LookupEventsInput{
+			LookupAttributes: []cloudtrail.LookupAttribute{
+				{AttributeKey: cloudtrail.LookupAttributeKeyEventName, AttributeValue: aws.String("CreateStack")},
+			},
+			StartTime: aws.Time(stack.CreationTime.Add(-5 * time.Minute)),
+			EndTime:   aws.Time(stack.CreationTime.Add(5 * time.Minute)),
+			NextToken: token,
+		}).Send(c.ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, event := range response.Events {
+			var record cloudTrailEvent
+			if err := json.Unmarshal([]byte(aws.StringValue(event.CloudTrailEvent)), &record); err != nil {
+				continue
+			}
+			if record.ResponseElements.StackId == stack.Id {
+				return record.principal(event)
+			}
+		}
+		token = response.NextToken
+		if token == nil {
+			return ""
+		}
+	}
+}
+
+// tagEventNames are the CloudTrail events of the apis adding, changing or removing tags
+var tagEventNames = map[string]bool{
+	"AddTags":                true,
+	"AddTagsToResource":      true,
+	"CreateTags":             true,
+	"DeleteBucketTagging":    true,
+	"DeleteTags":             true,
+	"PutBucketTagging":       true,
+	"RemoveTags":             true,
+	"RemoveTagsFromResource": true,
+	"TagResource":            true,
+	"TagRole":                true,
+	"TagUser":                true,
+	"UntagResource":          true,
+	"UntagRole":              true,
+	"UntagUser":              true,
+}
+
+// tagEvent is the last event that changed the tags of a resource
+type tagEvent struct {
+	Principal string
+	Time      time.Time
+	Event     string
+}
+
+// tagEvents looks up the last tag event of resources in CloudTrail, in the region
+// of their ARN, CloudTrail being regional
+type tagEvents struct {
+	ctx     context.Context
+	config  aws.Config
+	clients map[string]*cloudtrail.Client
+}
+
+func newTagEvents(ctx context.Context, config aws.Config) *tagEvents {
+	return &tagEvents{
+		ctx:     ctx,
+		config:  config,
+		clients: make(map[string]*cloudtrail.Client),
+	}
+}
+
+// client is the CloudTrail client of the region of an ARN, of the region of the config
+// for the physical ids and the ARNs of the global services
+func (c *tagEvents) client(arn string) *cloudtrail.Client {
+	region := c.config.Region
+	if parts := strings.SplitN(arn, ":", 5); len(parts) == 5 && parts[3] != "" {
+		region = parts[3]
+	}
+	client, ok := c.clients[region]
+	if !ok {
+		config := c.config.Copy()
+		config.Region = region
+		client = cloudtrail.New(config)
+		c.clients[region] = client
+	}
+	return client
+}
+
+// last returns the last tag event of the resource within the 90 days CloudTrail keeps,
+// the events being recorded under the ARN by some services and the physical id by others,
+// nil when there is none
+func (c *tagEvents) last(arn string, name string) (*tagEvent, error) {
+	var resourceNames []string
+	if arn != "" {
+		resourceNames = append(resourceNames, arn)
+	}
+	if name != "" && name != arn {
+		resourceNames = append(resourceNames, name)
+	}
+	for _, resourceName := range resourceNames {
+		change, err := c.lookup(c.client(arn), resourceName)
+		if err != nil || change != nil {
+			return change, err
+		}
+	}
+	return nil, nil
+}
+
+// lookup pages through the events of the resource, the most recent first, until a tag event
+func (c *tagEvents) lookup(client *cloudtrail.Client, resourceName string) (*tagEvent, error) {
+	var token *string
+	for {
+		response, err := client.LookupEventsRequest(&cloudtrail.LookupEventsInput{
+			LookupAttributes: []cloudtrail.LookupAttribute{
+				{AttributeKey: cloudtrail.LookupAttributeKeyResourceName, AttributeValue: aws.String(resourceName)},
+			},
+			NextToken: token,
+		}).Send(c.ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range response.Events {
+			if !tagEventNames[aws.StringValue(event.EventName)] {
+				continue
+			}
+			// a record failing to parse leaves the user name of the event as the principal
+			var record cloudTrailEvent
+			json.Unmarshal([]byte(aws.StringValue(event.CloudTrailEvent)), &record)
+			return &tagEvent{
+				Principal: record.principal(event),
+				Time:      aws.TimeValue(event.EventTime),
+				Event:     aws.StringValue(event.EventName),
+			}, nil
+		}
+		token = response.NextToken
+		if token == nil {
+			return nil, nil
+		}
+	}
+}
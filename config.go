@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// configFileName names the configuration files of the option defaults, read from
+	// ~/.aws-tag-report.yaml then ./aws-tag-report.yaml
+	configFileName = "aws-tag-report.yaml"
+	// configEnvPrefix starts the environment variables of the option defaults, e.g.
+	// AWS_TAG_REPORT_FORMAT for -format
+	configEnvPrefix = "AWS_TAG_REPORT_"
+)
+
+// applyDefaults sets the options not given on the command line from the configuration
+// files, the -config file instead when set, then from the environment variables; the files
+// map the option names to their value, a list for the options taking several values and a
+// map for those taking key=value pairs
+func applyDefaults(fs *flag.FlagSet, config string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	if config == "" {
+		config = os.Getenv(configEnvName("config"))
+	}
+	paths := []string{config}
+	if config == "" {
+		paths = []string{configFileName}
+		if home, err := os.UserHomeDir(); err == nil {
+			paths = []string{filepath.Join(home, "."+configFileName), configFileName}
+		}
+	}
+	defaults := make(map[string][]string)
+	for _, path := range paths {
+		values, err := readConfigFile(fs, path, config != "")
+		if err != nil {
+			return err
+		}
+		for name, value := range values {
+			defaults[name] = value
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if value, ok := os.LookupEnv(configEnvName(f.Name)); ok && f.Name != "config" {
+			defaults[f.Name] = []string{value}
+		}
+	})
+
+	var names []string
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if given[name] {
+			continue
+		}
+		for _, value := range defaults[name] {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid default of -%s %q: %v", name, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readConfigFile reads the option defaults of a configuration file, a missing file having
+// none unless required
+func readConfigFile(fs *flag.FlagSet, path string, required bool) (map[string][]string, error) {
+	body, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+	values := make(map[string][]string)
+	for name, value := range config {
+		if fs.Lookup(name) == nil || name == "config" {
+			return nil, fmt.Errorf("config %s: unknown option %q", path, name)
+		}
+		switch value := value.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range value {
+				values[name] = append(values[name], fmt.Sprint(item))
+			}
+		case map[string]interface{}:
+			// a map stands for the key=value list of the options as -service-region
+			var keys []string
+			for key := range value {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				values[name] = append(values[name], fmt.Sprintf("%s=%v", key, value[key]))
+			}
+		default:
+			values[name] = []string{fmt.Sprint(value)}
+		}
+	}
+	return values, nil
+}
+
+// configEnvName returns the environment variable of the default of an option
+func configEnvName(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+)
+
+// costExplorerDays is how far back the resource level data of the cost explorer goes, its
+// cost being scaled to 30 days
+const costExplorerDays = 14
+
+// Costs are the costs of the resources over the last 30 days, by resource id or ARN as
+// billing knows them
+type Costs map[string]float64
+
+// of returns the cost of a resource, known to billing by its ARN or its physical id; the
+// resources billing does not know cost nothing
+func (c Costs) of(arn string, name string) *float64 {
+	cost, ok := c[arn]
+	if !ok {
+		cost = c[name]
+	}
+	return &cost
+}
+
+// getCosts looks the costs of the resources of the account and region up with the source
+// of -monthly-cost
+func getCosts(ctx context.Context, config aws.Config, options *Options, account string, region string) Costs {
+	if options.MonthlyCost == "cur" {
+		return getCURCosts(ctx, config, options.CURTable, options.AthenaOutput, account)
+	}
+	return getCostExplorerCosts(ctx, config, region, time.Now().UTC())
+}
+
+// getCostExplorerCosts sums the daily unblended cost of each resource of the region over
+// the days the cost explorer keeps resource level data for, which must be opted in to
+func getCostExplorerCosts(ctx context.Context, config aws.Config, region string, now time.Time) Costs {
+	client := costexplorer.New(config)
+	costs := make(Costs)
+	var token *string
+	for {
+		response, err := client.GetCostAndUsageWithResourcesRequest(&costexplorer.GetCostAndUsageWithResourcesInput{
+			TimePeriod: &costexplorer.DateInterval{
+				Start: aws.String(now.AddDate(0, 0, -costExplorerDays).Format("2006-01-02")),
+				End:   aws.String(now.Format("2006-01-02")),
+			},
+			Granularity: costexplorer.GranularityDaily,
+			Filter: &costexplorer.Expression{
+				Dimensions: &costexplorer.DimensionValues{Key: costexplorer.DimensionRegion, Values: []string{region}},
+			},
+			Metrics: []string{"UnblendedCost"},
+			GroupBy: []costexplorer.GroupDefinition{
+				{Type: costexplorer.GroupDefinitionTypeDimension, Key: aws.String(string(costexplorer.DimensionResourceId))},
+			},
+			NextPageToken: token,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, result := range response.ResultsByTime {
+			for _, group := range result.Groups {
+				if len(group.Keys) == 0 {
+					continue
+				}
+				amount, err := strconv.ParseFloat(aws.StringValue(group.Metrics["UnblendedCost"].Amount), 64)
+				if err == nil {
+					costs[group.Keys[0]] += amount
+				}
+			}
+		}
+		token = response.NextPageToken
+		if token == nil {
+			break
+		}
+	}
+	for id, cost := range costs {
+		costs[id] = roundCost(cost * 30 / costExplorerDays)
+	}
+	return costs
+}
+
+// getCURCosts sums the unblended cost of each resource of the account over the last 30
+// days from the Cost and Usage Report table, queried with Athena
+func getCURCosts(ctx context.Context, config aws.Config, table string, output string, account string) Costs {
+	database, name := splitGlueTable(table)
+	query := fmt.Sprintf(`SELECT line_item_resource_id, SUM(line_item_unblended_cost)
+FROM "%s"."%s"
+WHERE line_item_usage_account_id = '%s'
+  AND line_item_usage_start_date >= date_add('day', -30, current_timestamp)
+  AND line_item_resource_id <> ''
+GROUP BY line_item_resource_id`, database, name, account)
+
+	costs := make(Costs)
+	for _, row := range athenaQuery(ctx, config, database, query, output) {
+		if len(row) < 2 {
+			continue
+		}
+		if cost, err := strconv.ParseFloat(row[1], 64); err == nil {
+			costs[row[0]] = roundCost(cost)
+		}
+	}
+	return costs
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+// formatCost formats a cost in dollars, empty when unknown
+func formatCost(cost *float64) string {
+	if cost == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*cost, 'f', 2, 64)
+}
@@ -0,0 +1,45 @@
+package main
+
+// dedupeReporter holds the resources until Close to forward each resource shared by
+// several stacks only once, its Stacks listing every stack it was found in
+type dedupeReporter struct {
+	Reporter
+	results []*Result
+	byKey   map[string]*Result
+}
+
+func newDedupeReporter(report Reporter) *dedupeReporter {
+	return &dedupeReporter{
+		Reporter: report,
+		byKey:    make(map[string]*Result),
+	}
+}
+
+// Add keeps the first result of a resource, identified by its ARN or else its physical id
+func (d *dedupeReporter) Add(result Result) {
+	key := result.Arn
+	if key == "" {
+		key = result.Resource.Type + "/" + result.Resource.Name
+	}
+	if first, ok := d.byKey[key]; ok {
+		if !containsString(first.Resource.Stacks, result.Resource.Stack.Name) {
+			first.Resource.Stacks = append(first.Resource.Stacks, result.Resource.Stack.Name)
+		}
+		return
+	}
+	result.Resource.Stacks = []string{result.Resource.Stack.Name}
+	d.byKey[key] = &result
+	d.results = append(d.results, &result)
+}
+
+// Write is a no-op, nothing is forwarded before Close
+func (d *dedupeReporter) Write() {
+}
+
+func (d *dedupeReporter) Close() {
+	for _, result := range d.results {
+		d.Reporter.Add(*result)
+	}
+	d.results, d.byKey = nil, nil
+	d.Reporter.Close()
+}
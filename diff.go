@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+)
+
+// changeRemoved marks the resources of the old report no longer found in the new one
+const changeRemoved = "REMOVED"
+
+// diffRow is a resource that changed between two reports
+type diffRow struct {
+	Change       string   `json:"change"`
+	Type         string   `json:"type"`
+	Name         string   `json:"id"`
+	Stack        string   `json:"stack"`
+	OldCoverage  *int     `json:"oldCoverage"`
+	NewCoverage  *int     `json:"newCoverage"`
+	NewlyMissing []string `json:"newlyMissingTags"`
+	// the last tag event of the regressed resources, with -who-changed
+	ChangedBy   string     `json:"changedBy,omitempty"`
+	ChangedAt   *time.Time `json:"changedAt,omitempty"`
+	ChangeEvent string     `json:"changeEvent,omitempty"`
+	arn         string
+}
+
+// diff runs the diff subcommand: diff [-format csv|json] [-csv-delimiter d] [-who-changed] old new,
+// the reports being csv, json or jsonl files, optionally gzip compressed
+func diff(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "csv", "output format, csv or json")
+	delimiter := fs.String("csv-delimiter", ",", "csv field delimiter of the reports and of the output, a single character or \"tab\"")
+	whoChanged := fs.Bool("who-changed", false, "look up in CloudTrail the principal and time of the last tag event of the regressed resources")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report diff [options] oldReport newReport"+
+			"\n\tlists the added and removed resources, the coverage changes and the newly missing tags"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("invalid -format %q, expected csv or json", *format)
+	}
+	var dialect CSVDialect
+	var err error
+	if dialect.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		return err
+	}
+
+	old, err := loadBaseline(fs.Arg(0), dialect)
+	if err != nil {
+		return err
+	}
+	current, err := loadBaseline(fs.Arg(1), dialect)
+	if err != nil {
+		return err
+	}
+	rows := diffReports(old, current)
+	if *whoChanged {
+		cfg, err := external.LoadDefaultAWSConfig()
+		if err != nil {
+			return fmt.Errorf("unable to load SDK config, %v", err)
+		}
+		if err := addTagEvents(stderr, newTagEvents(context.TODO(), cfg), rows); err != nil {
+			return err
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, row := range rows {
+		counts[row.Change]++
+	}
+	fmt.Fprintf(stderr, "%d added, %d removed, %d improved, %d regressed\n",
+		counts[changeNew], counts[changeRemoved], counts[changeImproved], counts[changeRegressed])
+
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	writer := newCSVWriter(w, dialect)
+	header := []string{"Change", "Type", "Resource Name", "Stack Name", "Old Coverage", "New Coverage", "Newly Missing Tags"}
+	if *whoChanged {
+		header = append(header, "Changed By", "Changed At", "Change Event")
+	}
+	writer.Write(header)
+	for _, row := range rows {
+		record := []string{row.Change, row.Type, row.Name, row.Stack, formatCoverage(row.OldCoverage),
+			formatCoverage(row.NewCoverage), strings.Join(row.NewlyMissing, ",")}
+		if *whoChanged {
+			changedAt := ""
+			if row.ChangedAt != nil {
+				changedAt = row.ChangedAt.UTC().Format(time.RFC3339)
+			}
+			record = append(record, row.ChangedBy, changedAt, row.ChangeEvent)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// diffReports returns the resources added, removed, whose coverage changed or missing tags
+// they did not miss before, by change, stack and name
+func diffReports(old *Baseline, current *Baseline) []diffRow {
+	var rows []diffRow
+	for key, resource := range current.resources {
+		row := diffRow{
+			Change:      old.change(key, current.coverage[key]),
+			Type:        resource.Type,
+			Name:        resource.Name,
+			Stack:       resource.Stack,
+			NewCoverage: current.coverage[key],
+			arn:         resource.Arn,
+		}
+		if previous, ok := old.resources[key]; ok {
+			row.OldCoverage = old.coverage[key]
+			for _, missing := range resource.MissingTags {
+				if !containsString(previous.MissingTags, missing) {
+					row.NewlyMissing = append(row.NewlyMissing, missing)
+				}
+			}
+		} else {
+			row.NewlyMissing = resource.MissingTags
+		}
+		if row.Change != changeUnchanged || len(row.NewlyMissing) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	for key, resource := range old.resources {
+		if _, ok := current.resources[key]; !ok {
+			rows = append(rows, diffRow{
+				Change:      changeRemoved,
+				Type:        resource.Type,
+				Name:        resource.Name,
+				Stack:       resource.Stack,
+				OldCoverage: old.coverage[key],
+			})
+		}
+	}
+
+	order := map[string]int{changeRegressed: 0, changeNew: 1, changeRemoved: 2, changeImproved: 3, changeUnchanged: 4}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Change != rows[j].Change {
+			return order[rows[i].Change] < order[rows[j].Change]
+		}
+		if rows[i].Stack != rows[j].Stack {
+			return rows[i].Stack < rows[j].Stack
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// addTagEvents adds the last tag event of the resources that regressed or miss tags they
+// carried before, to follow up with whoever removed them
+func addTagEvents(stderr io.Writer, events *tagEvents, rows []diffRow) error {
+	var regressed []int
+	for i, row := range rows {
+		if row.Change == changeRegressed || (row.Change != changeNew && len(row.NewlyMissing) > 0) {
+			regressed = append(regressed, i)
+		}
+	}
+	fmt.Fprintf(stderr, "looking up the tag changes of %d resources in CloudTrail\n", len(regressed))
+	for _, i := range regressed {
+		change, err := events.last(rows[i].arn, rows[i].Name)
+		if err != nil {
+			return fmt.Errorf("unable to look up the tag changes of %s in CloudTrail, %v", rows[i].Name, err)
+		}
+		if change != nil {
+			rows[i].ChangedBy = change.Principal
+			rows[i].ChangedAt = &change.Time
+			rows[i].ChangeEvent = change.Event
+		}
+	}
+	return nil
+}
+
+// formatCoverage formats a coverage, empty when unknown
+func formatCoverage(coverage *int) string {
+	if coverage == nil {
+		return ""
+	}
+	return strconv.Itoa(*coverage)
+}
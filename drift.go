@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// driftPollInterval is how often the status of the drift detections is checked
+const driftPollInterval = 5 * time.Second
+
+// driftStatus is the drift status of a resource as of the last drift detection of its stack:
+// IN_SYNC, MODIFIED, DELETED or NOT_CHECKED, empty for the stacks themselves
+func driftStatus(resource StackResource) string {
+	if resource.DriftInformation == nil {
+		return ""
+	}
+	return string(resource.DriftInformation.StackResourceDriftStatus)
+}
+
+// detectDrift runs the drift detection of the stacks of the resources, all at once, then
+// updates the drift information of the resources; a stack whose detection failed keeps the
+// status of its previous detection, the failure being written to w
+func detectDrift(ctx context.Context, config aws.Config, w io.Writer, resources []StackResource) {
+	client := *cloudformation.New(config)
+
+	// the stacks by id, in the order of the resources
+	var stacks []string
+	detections := make(map[string]*string)
+	for _, resource := range resources {
+		id := resource.Stack.Id
+		if _, ok := detections[id]; ok || resource.DriftInformation == nil {
+			continue
+		}
+		response, err := client.DetectStackDriftRequest(&cloudformation.DetectStackDriftInput{
+			StackName: aws.String(id),
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		stacks = append(stacks, id)
+		detections[id] = response.StackDriftDetectionId
+	}
+
+	fmt.Fprintf(w, "detecting the drift of %d stacks\n", len(stacks))
+	drifts := make(map[string]map[string]*cloudformation.StackResourceDriftInformation)
+	for _, id := range stacks {
+		for {
+			response, err := client.DescribeStackDriftDetectionStatusRequest(&cloudformation.DescribeStackDriftDetectionStatusInput{
+				StackDriftDetectionId: detections[id],
+			}).Send(ctx)
+			if err != nil {
+				panic(err.Error())
+			}
+			if response.DetectionStatus == cloudformation.StackDriftDetectionStatusDetectionInProgress {
+				time.Sleep(driftPollInterval)
+				continue
+			}
+			if response.DetectionStatus == cloudformation.StackDriftDetectionStatusDetectionFailed {
+				// the resources checked before the failure are still updated
+				fmt.Fprintf(w, "drift detection of %s failed: %s\n", id, aws.StringValue(response.DetectionStatusReason))
+			}
+			break
+		}
+		drifts[id] = make(map[string]*cloudformation.StackResourceDriftInformation)
+		for _, resource := range describeStackResources(ctx, client, aws.String(id)) {
+			drifts[id][aws.StringValue(resource.LogicalResourceId)] = resource.DriftInformation
+		}
+	}
+
+	for i, resource := range resources {
+		if drift, ok := drifts[resource.Stack.Id][aws.StringValue(resource.LogicalResourceId)]; ok && drift != nil {
+			resources[i].DriftInformation = drift
+		}
+	}
+}
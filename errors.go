@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+)
+
+// the actions taken on the errors of the tag lookups, by error code
+const (
+	// errorSkip leaves the resource out of the report and the totals, and carries on
+	errorSkip = "skip"
+	// errorWarn reports the resource as an error, writing the error to stderr, and carries on
+	errorWarn = "warn"
+	// errorFail stops the scan, as the errors of the codes not registered do
+	errorFail = "fail"
+)
+
+// defaultErrorActions warns of the resources which do not exist anymore, and of those the
+// caller may not read, of every supported service
+var defaultErrorActions = func() map[string]string {
+	actions := map[string]string{
+		"AuthorizationError": errorWarn, // sns
+	}
+	for code := range notFoundErrorCodes {
+		actions[code] = errorWarn
+	}
+	for code := range accessDeniedErrorCodes {
+		actions[code] = errorWarn
+	}
+	return actions
+}()
+
+// errorActions are the actions of the error codes, the defaults overridden by -error-action
+var errorActions = defaultErrorActions
+
+// errorActionFlag collects the actions of error codes as code=skip|warn|fail, the flag being
+// repeated for each code
+type errorActionFlag map[string]string
+
+func (f *errorActionFlag) String() string {
+	var actions []string
+	for code, action := range *f {
+		actions = append(actions, code+"="+action)
+	}
+	sort.Strings(actions)
+	return strings.Join(actions, ",")
+}
+
+func (f *errorActionFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected code=skip, code=warn or code=fail")
+	}
+	code, action := value[:i], value[i+1:]
+	if action != errorSkip && action != errorWarn && action != errorFail {
+		return fmt.Errorf("invalid action %q of %s, expected skip, warn or fail", action, code)
+	}
+	if *f == nil {
+		*f = make(errorActionFlag)
+	}
+	(*f)[code] = action
+	return nil
+}
+
+// newErrorActions are the default actions overridden by those of -error-action
+func newErrorActions(overrides map[string]string) map[string]string {
+	actions := make(map[string]string, len(defaultErrorActions)+len(overrides))
+	for code, action := range defaultErrorActions {
+		actions[code] = action
+	}
+	for code, action := range overrides {
+		actions[code] = action
+	}
+	return actions
+}
+
+// errorAction is the action of the error of a lookup, fail for the errors of the codes not
+// registered and those not coming from an api
+func errorAction(err error) string {
+	var ae awserr.Error
+	if errors.As(err, &ae) {
+		if action, ok := errorActions[ae.Code()]; ok {
+			return action
+		}
+	}
+	return errorFail
+}
+
+// publishError handles the error of a step publishing the reports, as the glue table and the
+// quicksight data set, as -error-action tells: nil unless the error is one to fail on, which
+// is returned as a ScanError
+func publishError(stderr io.Writer, step string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch errorAction(err) {
+	case errorSkip:
+		return nil
+	case errorWarn:
+		fmt.Fprintf(stderr, "unable to %s, %v\n", step, err)
+		return nil
+	}
+	return &ScanError{fmt.Errorf("unable to %s, %v", step, err)}
+}
+
+// ScanError stops a scan, a -watch carrying on with the next scan
+type ScanError struct {
+	Err error
+}
+
+func (e *ScanError) Error() string {
+	return e.Err.Error()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exemptionTag opts a resource out of the tag policy, its value being the expiry date of
+// the exemption followed by its justification, e.g. "2026-12-31 legacy bucket"
+var exemptionTag = "rlg:tag-exempt"
+
+// Exemption suppresses the findings of a known exception until it expires, matching the
+// resources by stack name pattern, resource type pattern and/or physical id or ARN
+type Exemption struct {
+	Stack    string `yaml:"stack"`
+	Type     string `yaml:"type"`
+	Resource string `yaml:"resource"`
+	Reason   string `yaml:"reason"`
+	// Expires is the last day the exemption applies, as YYYY-MM-DD
+	Expires string `yaml:"expires"`
+	expires time.Time
+}
+
+// Exemptions are the exemptions of an exemptions file still in force, along with the
+// exemption tag of the resources
+type Exemptions struct {
+	Exemptions []Exemption `yaml:"exemptions"`
+	warnings   io.Writer
+	now        time.Time
+}
+
+// loadExemptions reads the exemptions file at path, when set, warning about the expired
+// exemptions, which are left out
+func loadExemptions(path string, warnings io.Writer, now time.Time) (*Exemptions, error) {
+	exemptions := &Exemptions{warnings: warnings, now: now}
+	if path == "" {
+		return exemptions, nil
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	var file Exemptions
+	if err := decoder.Decode(&file); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("exemptions %s: %v", path, err)
+	}
+	for _, exemption := range file.Exemptions {
+		if err := exemption.validate(); err != nil {
+			return nil, fmt.Errorf("exemptions %s: %v", path, err)
+		}
+		exemption.expires, _ = parseExpiry(exemption.Expires)
+		if exemption.expired(now) {
+			fmt.Fprintf(warnings, "the exemption of %s expired on %s: %s\n", exemption.target(), exemption.Expires, exemption.Reason)
+			continue
+		}
+		exemptions.Exemptions = append(exemptions.Exemptions, exemption)
+	}
+	return exemptions, nil
+}
+
+func (e Exemption) validate() error {
+	if e.Stack == "" && e.Type == "" && e.Resource == "" {
+		return fmt.Errorf("every exemption requires a stack, type or resource")
+	}
+	if e.Reason == "" {
+		return fmt.Errorf("the exemption of %s requires a reason", e.target())
+	}
+	for _, pattern := range []string{e.Stack, e.Type} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("the exemption of %s has an invalid pattern %q", e.target(), pattern)
+		}
+	}
+	if _, err := parseExpiry(e.Expires); e.Expires != "" && err != nil {
+		return fmt.Errorf("the exemption of %s expires on an invalid date %q", e.target(), e.Expires)
+	}
+	return nil
+}
+
+// target describes the resources of the exemption
+func (e Exemption) target() string {
+	var target []string
+	for _, part := range []string{e.Stack, e.Type, e.Resource} {
+		if part != "" {
+			target = append(target, part)
+		}
+	}
+	return strings.Join(target, " ")
+}
+
+func (e Exemption) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func (e Exemption) matches(resource Resource, arn string) bool {
+	if matched, _ := path.Match(e.Stack, resource.Stack.Name); e.Stack != "" && !matched {
+		return false
+	}
+	if matched, _ := path.Match(e.Type, resource.Type); e.Type != "" && !matched {
+		return false
+	}
+	return e.Resource == "" || e.Resource == resource.Name || e.Resource == arn
+}
+
+// parseExpiry parses a YYYY-MM-DD expiry date as the end of that day
+func parseExpiry(date string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// exemption returns the justification of the exemption of the resource, empty when the
+// resource is not exempt, from the exemptions file first and its exemption tag otherwise
+func (e *Exemptions) exemption(resource Resource, arn string, tags map[string]string) string {
+	for _, exemption := range e.Exemptions {
+		if exemption.matches(resource, arn) {
+			return exemption.Reason
+		}
+	}
+
+	value, ok := tags[exemptionTag]
+	if !ok {
+		return ""
+	}
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	expires, err := parseExpiry(fields[0])
+	if err != nil {
+		fmt.Fprintf(e.warnings, "the %s tag of %s %s does not start with an expiry date: %q\n", exemptionTag, resource.Type, resource.Name, value)
+		return ""
+	}
+	if e.now.After(expires) {
+		fmt.Fprintf(e.warnings, "the exemption of %s %s expired on %s\n", resource.Type, resource.Name, fields[0])
+		return ""
+	}
+	if len(fields) == 1 || strings.TrimSpace(fields[1]) == "" {
+		return exemptionTag + " until " + fields[0]
+	}
+	return strings.TrimSpace(fields[1])
+}
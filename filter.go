@@ -0,0 +1,14 @@
+package main
+
+// noncompliantReporter only forwards the resources whose modern coverage is below the
+// minimum, the resources not supporting tags or whose tags are unknown being left out
+type noncompliantReporter struct {
+	Reporter
+	minCoverage int
+}
+
+func (f noncompliantReporter) Add(result Result) {
+	if result.Finding != nil && result.Finding.ModernCoverage < f.minCoverage {
+		f.Reporter.Add(result)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fix runs the fix subcommand: fix [-dry-run] [-rate n] [-log file] [-emit-script file]
+// [-- report options] search, walking through the noncompliant resources once scanned and
+// tagging each with the values accepted at the prompt
+func fix(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report fix", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var flags taggerFlags
+	flags.register(fs, "added")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report fix [options] [-- report options] searchString"+
+			"\n\twalks through the noncompliant resources, suggesting the values of the tags they miss from their"+
+			"\n\tstack and the other resources of the stack, and adds the tags accepted right away"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var session *fixReporter
+	err := tagScan(w, stderr, fs.Args(), &flags, func(tagger *Tagger) Reporter {
+		session = &fixReporter{
+			in:        bufio.NewReader(os.Stdin),
+			out:       stderr,
+			tagger:    tagger,
+			suggester: newTagSuggester(),
+		}
+		return session
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%d resources fixed, %d skipped, %d failed\n",
+		session.fixed, session.skipped, session.tagger.failed)
+	if session.tagger.failed > 0 {
+		return fmt.Errorf("tagging failed for %d resources", session.tagger.failed)
+	}
+	return nil
+}
+
+// fixCandidate is a noncompliant resource and the keys it misses or carries invalid values of
+type fixCandidate struct {
+	resource Resource
+	arn      string
+	keys     []string
+}
+
+// fixReporter collects the noncompliant resources and the tag values of every resource by
+// stack, then prompts for the values of each noncompliant resource on Close
+type fixReporter struct {
+	in         *bufio.Reader
+	out        io.Writer
+	tagger     *Tagger
+	suggester  *tagSuggester
+	candidates []fixCandidate
+	fixed      int
+	skipped    int
+}
+
+// Add collects the noncompliant resources, observing the tags of every resource for the
+// suggestions
+func (r *fixReporter) Add(result Result) {
+	if result.Finding == nil {
+		return
+	}
+	r.suggester.observe(result.Resource, result.Tags)
+	if keys := result.Finding.remediationKeys(); len(keys) > 0 {
+		r.candidates = append(r.candidates, fixCandidate{result.Resource, result.Arn, keys})
+	}
+}
+
+func (r *fixReporter) Write() {
+}
+
+// Close walks through the noncompliant resources, the suggestions needing every resource
+func (r *fixReporter) Close() {
+	for i, candidate := range r.candidates {
+		resource, arn := candidate.resource, candidate.arn
+		fmt.Fprintf(r.out, "\n[%d/%d] %s %s of stack %s misses %s\n", i+1, len(r.candidates),
+			extractType(resource.Type), resource.Name, resource.Stack.Name, strings.Join(candidate.keys, ", "))
+		if arn == "" {
+			fmt.Fprintln(r.out, "no known ARN, skipped")
+			r.skipped++
+			continue
+		}
+
+		tags := make(map[string]string)
+		for _, key := range candidate.keys {
+			value, ok := r.ask(key, r.suggester.suggest(key, resource))
+			if !ok {
+				r.quit(len(r.candidates) - i)
+				return
+			}
+			if value != "" {
+				tags[key] = value
+			}
+		}
+		if len(tags) == 0 {
+			r.skipped++
+			continue
+		}
+		switch r.prompt(fmt.Sprintf("add %s? [Y/n/q] ", formatTags(tags))) {
+		case "", "y", "Y", "yes":
+			if r.tagger.tag(arn, tags) {
+				r.fixed++
+			}
+		case "q":
+			r.quit(len(r.candidates) - i)
+			return
+		default:
+			r.skipped++
+		}
+	}
+}
+
+// quit skips the resources left
+func (r *fixReporter) quit(left int) {
+	fmt.Fprintf(r.out, "\n%d resources left\n", left)
+	r.skipped += left
+}
+
+// ask prompts for the value of a key until it is valid, returning false to quit; enter
+// accepts the suggestion, - skips the key
+func (r *fixReporter) ask(key string, suggestion string) (string, bool) {
+	for {
+		question := fmt.Sprintf("  %s: ", key)
+		if suggestion != "" {
+			question = fmt.Sprintf("  %s [%s]: ", key, suggestion)
+		}
+		value := r.prompt(question)
+		switch value {
+		case "q":
+			return "", false
+		case "-":
+			return "", true
+		case "":
+			value = suggestion
+		}
+		if rule := valueRules[key]; value != "" && rule != nil && !rule.valid(value) {
+			fmt.Fprintf(r.out, "  %q is not a valid %s value\n", value, key)
+			continue
+		}
+		return value, true
+	}
+}
+
+// prompt reads an answer, quitting at the end of the input
+func (r *fixReporter) prompt(question string) string {
+	fmt.Fprint(r.out, question)
+	answer, err := r.in.ReadString('\n')
+	if err != nil && answer == "" {
+		return "q"
+	}
+	return strings.TrimSpace(answer)
+}
+
+// formatTags formats tags as key=value pairs by key
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for _, key := range sortedTagKeys(tags) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ", ")
+}
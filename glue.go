@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+)
+
+// gluePartitionKeys are the partition keys of the table, those of the s3 uploads
+var gluePartitionKeys = []string{"date", "account", "region"}
+
+// GlueTable registers the report files uploaded to s3 as a table of the Glue Data Catalog,
+// so that Athena queries the reports of every account and region right away
+type GlueTable struct {
+	ctx      context.Context
+	client   *glue.Client
+	upload   *S3Upload
+	database string
+	name     string
+	input    glue.TableInput
+}
+
+// newGlueTable describes the table database.name of the csv or parquet report uploaded
+func newGlueTable(ctx context.Context, config aws.Config, table string, options *Options, upload *S3Upload) *GlueTable {
+	database, name := splitGlueTable(table)
+	location := fmt.Sprintf("s3://%s/%s", upload.bucket, upload.root)
+	var descriptor *glue.StorageDescriptor
+	parameters := map[string]string{"classification": options.Format}
+	if options.Format == "parquet" {
+		descriptor = &glue.StorageDescriptor{
+			Columns:      parquetGlueColumns(),
+			Location:     aws.String(location),
+			InputFormat:  aws.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetInputFormat"),
+			OutputFormat: aws.String("org.apache.hadoop.hive.ql.io.parquet.MapredParquetOutputFormat"),
+			SerdeInfo: &glue.SerDeInfo{
+				SerializationLibrary: aws.String("org.apache.hadoop.hive.ql.io.parquet.serde.ParquetHiveSerDe"),
+			},
+		}
+	} else {
+		separator := ","
+		if options.CSVDialect.Delimiter != 0 {
+			separator = string(options.CSVDialect.Delimiter)
+		}
+		descriptor = &glue.StorageDescriptor{
+			Columns:      csvGlueColumns(options),
+			Location:     aws.String(location),
+			InputFormat:  aws.String("org.apache.hadoop.mapred.TextInputFormat"),
+			OutputFormat: aws.String("org.apache.hadoop.hive.ql.io.HiveIgnoreKeyTextOutputFormat"),
+			SerdeInfo: &glue.SerDeInfo{
+				SerializationLibrary: aws.String("org.apache.hadoop.hive.serde2.OpenCSVSerde"),
+				Parameters:           map[string]string{"separatorChar": separator, "quoteChar": "\""},
+			},
+		}
+		parameters["skip.header.line.count"] = "1"
+	}
+	var partitionKeys []glue.Column
+	for _, key := range gluePartitionKeys {
+		partitionKeys = append(partitionKeys, glue.Column{Name: aws.String(key), Type: aws.String("string")})
+	}
+	return &GlueTable{
+		ctx:      ctx,
+		client:   glue.New(config),
+		upload:   upload,
+		database: database,
+		name:     name,
+		input: glue.TableInput{
+			Name:              aws.String(name),
+			TableType:         aws.String("EXTERNAL_TABLE"),
+			Parameters:        parameters,
+			PartitionKeys:     partitionKeys,
+			StorageDescriptor: descriptor,
+		},
+	}
+}
+
+// splitGlueTable splits database.table, an invalid name returning an empty database
+func splitGlueTable(table string) (string, string) {
+	parts := strings.SplitN(table, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", table
+	}
+	return parts[0], parts[1]
+}
+
+// parquetGlueColumns are the columns of the parquet report, but for the account and
+// region the partition keys already are
+func parquetGlueColumns() []glue.Column {
+	var columns []glue.Column
+	for _, column := range newParquetColumns() {
+		if containsString(gluePartitionKeys, column.name) {
+			continue
+		}
+		kind := "string"
+		if column.kind == parquetInt32 {
+			kind = "int"
+		}
+		columns = append(columns, glue.Column{Name: aws.String(column.name), Type: aws.String(kind)})
+	}
+	return columns
+}
+
+var nonIdentifier = regexp.MustCompile(`[^a-z0-9]+`)
+
+// csvGlueColumns names the columns of the csv report after its header, as strings the
+// csv serde reads them as, prefixing the ones named like a partition key with resource_
+func csvGlueColumns(options *Options) []glue.Column {
+	var columns []glue.Column
+	for _, title := range csvReportHeader(options) {
+		name := strings.Trim(nonIdentifier.ReplaceAllString(strings.ToLower(title), "_"), "_")
+		if containsString(gluePartitionKeys, name) {
+			name = "resource_" + name
+		}
+		columns = append(columns, glue.Column{Name: aws.String(name), Type: aws.String("string")})
+	}
+	return columns
+}
+
+// register creates the table or updates its columns to those of the report, then adds
+// the partition of the run
+func (t *GlueTable) register() error {
+	_, err := t.client.GetTableRequest(&glue.GetTableInput{
+		DatabaseName: aws.String(t.database),
+		Name:         aws.String(t.name),
+	}).Send(t.ctx)
+	var ae awserr.Error
+	if errors.As(err, &ae) && ae.Code() == glue.ErrCodeEntityNotFoundException {
+		_, err = t.client.CreateTableRequest(&glue.CreateTableInput{
+			DatabaseName: aws.String(t.database),
+			TableInput:   &t.input,
+		}).Send(t.ctx)
+	} else if err == nil {
+		_, err = t.client.UpdateTableRequest(&glue.UpdateTableInput{
+			DatabaseName: aws.String(t.database),
+			TableInput:   &t.input,
+		}).Send(t.ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("glue table %s.%s: %v", t.database, t.name, err)
+	}
+
+	descriptor := *t.input.StorageDescriptor
+	descriptor.Location = aws.String(fmt.Sprintf("s3://%s/%s", t.upload.bucket, t.upload.prefix))
+	_, err = t.client.CreatePartitionRequest(&glue.CreatePartitionInput{
+		DatabaseName: aws.String(t.database),
+		TableName:    aws.String(t.name),
+		PartitionInput: &glue.PartitionInput{
+			Values:            t.upload.partition,
+			StorageDescriptor: &descriptor,
+		},
+	}).Send(t.ctx)
+	// the partition of an earlier run of the day already holds the files
+	if errors.As(err, &ae) && ae.Code() == glue.ErrCodeAlreadyExistsException {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("glue table %s.%s: %v", t.database, t.name, err)
+	}
+	return nil
+}
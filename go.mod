@@ -1,8 +1,14 @@
 module github.com/kasvela/aws-tag-report
 
-go 1.14
+go 1.16
 
 require (
+	github.com/aws/aws-lambda-go v1.34.1
 	github.com/aws/aws-sdk-go-v2 v0.22.0
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/open-policy-agent/opa v0.45.0
 	github.com/tj/assert v0.0.0-20190920132354-ee03d75cd160
+	github.com/xeipuuv/gojsonschema v1.2.0
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// groupMarkerPrefix starts the keys of the tags marking the resources missing a required
+// key, resource group queries only matching the tags resources carry
+const groupMarkerPrefix = "aws-tag-report:missing:"
+
+// groups runs the groups subcommand: groups [-dry-run] [-rate n] [-log file] [-emit-script file]
+// [-- report options] search, marking the resources missing each required key with a tag and
+// putting the missing-<key> resource group of the resources so marked
+func groups(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report groups", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var flags taggerFlags
+	flags.register(fs, "changed")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report groups [options] [-- report options] searchString"+
+			"\n\ttags each resource missing a required key of the modern scheme with "+groupMarkerPrefix+"<key>,"+
+			"\n\tremoving the tag once the key is carried, and creates or updates the missing-<key> resource group"+
+			"\n\tof the resources so tagged, logging the result of each change; the report is only written with -output"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var grouping *groupsReporter
+	err := tagScan(w, stderr, fs.Args(), &flags, func(tagger *Tagger) Reporter {
+		grouping = &groupsReporter{w: stderr, tagger: tagger}
+		return grouping
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%d resources changing group, %d failed, %d without an ARN\n",
+		grouping.changes, grouping.tagger.failed, grouping.skipped)
+	if grouping.tagger.failed > 0 {
+		return fmt.Errorf("grouping failed for %d changes", grouping.tagger.failed)
+	}
+	return nil
+}
+
+// groupsReporter tags each resource with the markers of the required keys it misses and
+// removes the markers of the keys it carries, putting the groups once every resource is
+// scanned
+type groupsReporter struct {
+	w       io.Writer
+	tagger  *Tagger
+	changes int
+	skipped int
+}
+
+func (r *groupsReporter) Add(result Result) {
+	if result.Finding == nil {
+		return
+	}
+	resource, tags := result.Resource, result.Tags
+	missing := make(map[string]bool)
+	add := make(map[string]string)
+	for _, key := range result.Finding.Missing {
+		marker := groupMarker(key)
+		missing[marker] = true
+		if _, ok := tags[marker]; !ok {
+			add[marker] = "true"
+		}
+	}
+	var remove []string
+	for key := range tags {
+		if strings.HasPrefix(key, groupMarkerPrefix) && !missing[key] {
+			remove = append(remove, key)
+		}
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return
+	}
+	r.changes++
+	arn := result.Arn
+	if arn == "" {
+		fmt.Fprintf(r.w, "%s %s has no known ARN, skipped\n", resource.Type, resource.Name)
+		r.skipped++
+		return
+	}
+	if len(add) > 0 {
+		r.tagger.tag(arn, add)
+	}
+	if len(remove) > 0 {
+		sort.Strings(remove)
+		r.tagger.untag(arn, remove)
+	}
+}
+
+func (r *groupsReporter) Write() {
+}
+
+// Close puts the group of every key the modern scheme may require, so the groups of the keys
+// no resource misses anymore are emptied rather than left stale
+func (r *groupsReporter) Close() {
+	for _, key := range modern.allKeys() {
+		r.tagger.group(groupName(key), groupQuery(key))
+	}
+}
+
+// groupMarker returns the key of the tag marking the resources missing key
+func groupMarker(key string) string {
+	return groupMarkerPrefix + key
+}
+
+var groupNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// groupName returns the name of the group of the resources missing key, e.g.
+// missing-rlg-business-unit
+func groupName(key string) string {
+	return "missing-" + groupNameInvalid.ReplaceAllString(key, "-")
+}
+
+// groupQuery returns the tag filters query of the resources missing key
+func groupQuery(key string) string {
+	query, err := json.Marshal(map[string]interface{}{
+		"ResourceTypeFilters": []string{"AWS::AllSupported"},
+		"TagFilters":          []map[string]interface{}{{"Key": groupMarker(key), "Values": []string{"true"}}},
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	return string(query)
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// HistoryRun is a run recorded in the -history-table
+type HistoryRun struct {
+	Id        string
+	Timestamp string
+	Account   string
+	Region    string
+	Search    string
+	Resources int
+	Compliant int
+}
+
+// HistoryResource is the compliance of a resource recorded for a run
+type HistoryResource struct {
+	Arn             string
+	Stack           string
+	Type            string
+	Id              string
+	Status          string
+	ClassicCoverage *int
+	ModernCoverage  *int
+}
+
+// getHistoryRuns returns the runs of the history table, by time
+func getHistoryRuns(ctx context.Context, client *dynamodb.Client, table string) []HistoryRun {
+	var runs []HistoryRun
+	var key map[string]dynamodb.AttributeValue
+	for {
+		response, err := client.ScanRequest(&dynamodb.ScanInput{
+			TableName:                 aws.String(table),
+			FilterExpression:          aws.String("arn = :run"),
+			ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":run": stringValue(historyRunItem)},
+			ExclusiveStartKey:         key,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, item := range response.Items {
+			runs = append(runs, HistoryRun{
+				Id:        itemString(item, "runId"),
+				Timestamp: itemString(item, "timestamp"),
+				Account:   itemString(item, "account"),
+				Region:    itemString(item, "region"),
+				Search:    itemString(item, "search"),
+				Resources: itemNumber(item, "resources"),
+				Compliant: itemNumber(item, "compliant"),
+			})
+		}
+		key = response.LastEvaluatedKey
+		if len(key) == 0 {
+			break
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Timestamp < runs[j].Timestamp
+	})
+	return runs
+}
+
+// getHistoryResources returns the resources recorded for a run
+func getHistoryResources(ctx context.Context, client *dynamodb.Client, table string, run string) []HistoryResource {
+	var resources []HistoryResource
+	var key map[string]dynamodb.AttributeValue
+	for {
+		response, err := client.QueryRequest(&dynamodb.QueryInput{
+			TableName:                 aws.String(table),
+			KeyConditionExpression:    aws.String("runId = :run"),
+			ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":run": stringValue(run)},
+			ExclusiveStartKey:         key,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, item := range response.Items {
+			if itemString(item, "arn") == historyRunItem {
+				continue
+			}
+			resources = append(resources, HistoryResource{
+				Arn:             itemString(item, "arn"),
+				Stack:           itemString(item, "stack"),
+				Type:            itemString(item, "type"),
+				Id:              itemString(item, "id"),
+				Status:          itemString(item, "status"),
+				ClassicCoverage: itemCoverage(item, "classicCoverage"),
+				ModernCoverage:  itemCoverage(item, "modernCoverage"),
+			})
+		}
+		key = response.LastEvaluatedKey
+		if len(key) == 0 {
+			break
+		}
+	}
+	return resources
+}
+
+func itemString(item map[string]dynamodb.AttributeValue, name string) string {
+	return aws.StringValue(item[name].S)
+}
+
+func itemNumber(item map[string]dynamodb.AttributeValue, name string) int {
+	n, _ := strconv.Atoi(aws.StringValue(item[name].N))
+	return n
+}
+
+// itemCoverage returns a coverage attribute, nil when absent
+func itemCoverage(item map[string]dynamodb.AttributeValue, name string) *int {
+	n, err := strconv.Atoi(aws.StringValue(item[name].N))
+	if err != nil {
+		return nil
+	}
+	return &n
+}
+
+// history runs the history subcommand: history list|prune [options], listing or pruning the
+// runs recorded with -history-table or the reports uploaded with -s3-uri
+func history(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report history", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	table := fs.String("history-table", "", "DynamoDB table the runs were recorded in with -history-table")
+	uri := fs.String("s3-uri", "", "s3://bucket/prefix/ the reports were uploaded to with -s3-uri")
+	keep := fs.String("keep", "", "prune: age of the most recent runs kept, in days as 90d or as a duration as 720h")
+	dryRun := fs.Bool("dry-run", false, "prune: only list the runs that would be deleted")
+	format := fs.String("format", "table", "list: output format, table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report history list|prune [-history-table name | -s3-uri uri] [options]"+
+			"\n\tlist: lists the runs recorded in the history table, or the partitions of the uploaded reports"+
+			"\n\tprune: deletes the runs, or the partitions, older than -keep"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || (args[0] != "list" && args[0] != "prune") {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	command := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*table == "") == (*uri == "") {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q, expected table or json", *format)
+	}
+	var retention time.Duration
+	if command == "prune" {
+		var err error
+		if retention, err = parseRetention(*keep); err != nil {
+			return err
+		}
+	} else if *keep != "" || *dryRun {
+		return fmt.Errorf("-keep and -dry-run only apply to prune")
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	ctx := context.TODO()
+	if *table != "" {
+		client := dynamodb.New(cfg)
+		runs := getHistoryRuns(ctx, client, *table)
+		if command == "list" {
+			return writeHistoryRuns(w, runs, *format)
+		}
+		pruneHistoryRuns(ctx, stderr, client, *table, runs, time.Now().Add(-retention), *dryRun)
+		return nil
+	}
+
+	bucket, prefix, err := parseS3URI(*uri)
+	if err != nil {
+		return err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client := s3.New(cfg)
+	partitions := getHistoryPartitions(ctx, client, bucket, prefix)
+	if command == "list" {
+		return writeHistoryPartitions(w, partitions, *format)
+	}
+	pruneHistoryPartitions(ctx, stderr, client, bucket, partitions, time.Now().Add(-retention), *dryRun)
+	return nil
+}
+
+// parseRetention parses a -keep age, a number of days as 90d or a duration as 720h
+func parseRetention(keep string) (time.Duration, error) {
+	var retention time.Duration
+	if strings.HasSuffix(keep, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(keep, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid -keep %q, expected days as 90d or a duration as 720h", keep)
+		}
+		retention = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if retention, err = time.ParseDuration(keep); err != nil {
+			return 0, fmt.Errorf("invalid -keep %q, expected days as 90d or a duration as 720h", keep)
+		}
+	}
+	if retention <= 0 {
+		return 0, fmt.Errorf("invalid -keep %q, expected a positive age", keep)
+	}
+	return retention, nil
+}
+
+func writeHistoryRuns(w io.Writer, runs []HistoryRun, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(runs)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TIMESTAMP\tACCOUNT\tREGION\tSEARCH\tRESOURCES\tCOMPLIANT")
+	for _, run := range runs {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%d\n", run.Timestamp, run.Account, run.Region, run.Search, run.Resources, run.Compliant)
+	}
+	return table.Flush()
+}
+
+// pruneHistoryRuns deletes the items of the runs older than the cutoff, the item of the run
+// last so that an interrupted prune still lists it
+func pruneHistoryRuns(ctx context.Context, w io.Writer, client *dynamodb.Client, table string, runs []HistoryRun, cutoff time.Time, dryRun bool) {
+	pruned := 0
+	for _, run := range runs {
+		timestamp, err := time.Parse(time.RFC3339, run.Timestamp)
+		if err != nil || !timestamp.Before(cutoff) {
+			continue
+		}
+		pruned++
+		if dryRun {
+			fmt.Fprintf(w, "would delete run %s\n", run.Id)
+			continue
+		}
+		var requests []dynamodb.WriteRequest
+		for _, arn := range append(getHistoryKeys(ctx, client, table, run.Id), historyRunItem) {
+			requests = append(requests, dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]dynamodb.AttributeValue{"runId": stringValue(run.Id), "arn": stringValue(arn)},
+			}})
+			if len(requests) == 25 {
+				batchWriteItems(ctx, client, table, requests)
+				requests = nil
+			}
+		}
+		if len(requests) > 0 {
+			batchWriteItems(ctx, client, table, requests)
+		}
+		fmt.Fprintf(w, "deleted run %s\n", run.Id)
+	}
+	fmt.Fprintf(w, "%d runs, %d older than %s pruned\n", len(runs), pruned, cutoff.UTC().Format(time.RFC3339))
+}
+
+// getHistoryKeys returns the arn sort keys of the resources recorded for a run
+func getHistoryKeys(ctx context.Context, client *dynamodb.Client, table string, run string) []string {
+	var keys []string
+	var key map[string]dynamodb.AttributeValue
+	for {
+		response, err := client.QueryRequest(&dynamodb.QueryInput{
+			TableName:                 aws.String(table),
+			KeyConditionExpression:    aws.String("runId = :run"),
+			ProjectionExpression:      aws.String("#arn"),
+			ExpressionAttributeNames:  map[string]string{"#arn": "arn"},
+			ExpressionAttributeValues: map[string]dynamodb.AttributeValue{":run": stringValue(run)},
+			ExclusiveStartKey:         key,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, item := range response.Items {
+			if arn := itemString(item, "arn"); arn != historyRunItem {
+				keys = append(keys, arn)
+			}
+		}
+		key = response.LastEvaluatedKey
+		if len(key) == 0 {
+			break
+		}
+	}
+	return keys
+}
+
+// HistoryPartition is the date, account and region partition of the reports uploaded by a run
+type HistoryPartition struct {
+	Prefix  string
+	Date    string
+	Account string
+	Region  string
+	Files   int
+	Size    int64
+	keys    []string
+}
+
+// getHistoryPartitions returns the partitions of the reports uploaded under the prefix, by
+// date, account and region, the other objects being ignored
+func getHistoryPartitions(ctx context.Context, client *s3.Client, bucket string, prefix string) []*HistoryPartition {
+	partitions := make(map[string]*HistoryPartition)
+	var token *string
+	for {
+		response, err := client.ListObjectsV2Request(&s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: token,
+		}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		for _, object := range response.Contents {
+			key := aws.StringValue(object.Key)
+			parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+			if len(parts) != 4 || !strings.HasPrefix(parts[0], "date=") ||
+				!strings.HasPrefix(parts[1], "account=") || !strings.HasPrefix(parts[2], "region=") {
+				continue
+			}
+			partitionPrefix := prefix + strings.Join(parts[:3], "/") + "/"
+			partition, ok := partitions[partitionPrefix]
+			if !ok {
+				partition = &HistoryPartition{
+					Prefix:  partitionPrefix,
+					Date:    strings.TrimPrefix(parts[0], "date="),
+					Account: strings.TrimPrefix(parts[1], "account="),
+					Region:  strings.TrimPrefix(parts[2], "region="),
+				}
+				partitions[partitionPrefix] = partition
+			}
+			partition.Files++
+			partition.Size += aws.Int64Value(object.Size)
+			partition.keys = append(partition.keys, key)
+		}
+		token = response.NextContinuationToken
+		if token == nil {
+			break
+		}
+	}
+	var sorted []*HistoryPartition
+	for _, partition := range partitions {
+		sorted = append(sorted, partition)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Prefix < sorted[j].Prefix
+	})
+	return sorted
+}
+
+func writeHistoryPartitions(w io.Writer, partitions []*HistoryPartition, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(partitions)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "DATE\tACCOUNT\tREGION\tFILES\tBYTES")
+	for _, partition := range partitions {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\n", partition.Date, partition.Account, partition.Region, partition.Files, partition.Size)
+	}
+	return table.Flush()
+}
+
+// pruneHistoryPartitions deletes the reports of the partitions dated before the day of the
+// cutoff, DeleteObjects taking up to 1000 keys at a time
+func pruneHistoryPartitions(ctx context.Context, w io.Writer, client *s3.Client, bucket string, partitions []*HistoryPartition, cutoff time.Time, dryRun bool) {
+	pruned := 0
+	for _, partition := range partitions {
+		if partition.Date >= cutoff.UTC().Format("2006-01-02") {
+			continue
+		}
+		pruned++
+		if dryRun {
+			fmt.Fprintf(w, "would delete s3://%s/%s, %d files\n", bucket, partition.Prefix, partition.Files)
+			continue
+		}
+		for start := 0; start < len(partition.keys); start += 1000 {
+			end := start + 1000
+			if end > len(partition.keys) {
+				end = len(partition.keys)
+			}
+			var objects []s3.ObjectIdentifier
+			for _, key := range partition.keys[start:end] {
+				objects = append(objects, s3.ObjectIdentifier{Key: aws.String(key)})
+			}
+			response, err := client.DeleteObjectsRequest(&s3.DeleteObjectsInput{
+				Bucket: aws.String(bucket),
+				Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			}).Send(ctx)
+			if err != nil {
+				panic(err.Error())
+			}
+			for _, failure := range response.Errors {
+				fmt.Fprintf(w, "unable to delete s3://%s/%s, %s\n", bucket, aws.StringValue(failure.Key), aws.StringValue(failure.Message))
+			}
+		}
+		fmt.Fprintf(w, "deleted s3://%s/%s\n", bucket, partition.Prefix)
+	}
+	fmt.Fprintf(w, "%d partitions, %d dated before %s pruned\n", len(partitions), pruned, cutoff.UTC().Format("2006-01-02"))
+}
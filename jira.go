@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// jiraLabel labels the issues of the report, the owner being labeled too
+	jiraLabel = "aws-tag-report"
+	// jiraMaxRows is how many noncompliant resources an issue lists, descriptions being limited
+	jiraMaxRows = 200
+	// jiraUnowned is the owner of the resources carrying none of the owner keys
+	jiraUnowned = "unowned"
+)
+
+// JiraReport keeps an issue open per owner of noncompliant resources once the run is complete,
+// the owner being the value of the first owner key a resource carries: the issue of an owner
+// is created or its list of resources updated, and it is closed once every resource of the
+// owner is compliant. The credentials are read from the JIRA_USER and JIRA_API_TOKEN
+// environment variables
+type JiraReport struct {
+	url       string
+	project   string
+	issueType string
+	ownerKeys []string
+	search    string
+	client    *http.Client
+	owners    map[string]*jiraOwner
+}
+
+// jiraOwner holds the resources of an owner
+type jiraOwner struct {
+	resources    int
+	noncompliant []jiraResource
+}
+
+// jiraResource is a noncompliant resource and the keys it misses or carries invalid values of
+type jiraResource struct {
+	resource Resource
+	arn      string
+	missing  []string
+	invalid  []string
+}
+
+// jiraIssue is an issue as searched
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Labels []string `json:"labels"`
+	} `json:"fields"`
+}
+
+func NewJiraReporter(jiraURL string, project string, issueType string, ownerKeys []string, search string) *JiraReport {
+	return &JiraReport{
+		url:       strings.TrimSuffix(jiraURL, "/"),
+		project:   project,
+		issueType: issueType,
+		ownerKeys: ownerKeys,
+		search:    search,
+		client:    &http.Client{Timeout: time.Minute},
+		owners:    make(map[string]*jiraOwner),
+	}
+}
+
+func (r *JiraReport) Add(result Result) {
+	// the resources not supporting tags cannot be remediated, those whose tags are unknown
+	// having no known owner
+	if result.Finding == nil {
+		return
+	}
+	resource, tags, finding := result.Resource, result.Tags, result.Finding
+	owner := jiraUnowned
+	for _, key := range r.ownerKeys {
+		if value, ok := lookupTag(tags, key); ok && strings.TrimSpace(value) != "" {
+			owner = strings.TrimSpace(value)
+			break
+		}
+	}
+	summary, ok := r.owners[owner]
+	if !ok {
+		summary = &jiraOwner{}
+		r.owners[owner] = summary
+	}
+	summary.resources++
+	if finding.compliant() {
+		return
+	}
+	summary.noncompliant = append(summary.noncompliant, jiraResource{
+		resource: resource,
+		arn:      result.Arn,
+		missing:  finding.Missing,
+		invalid:  finding.Invalid,
+	})
+}
+
+// Write is a no-op, the issues are updated on Close
+func (r *JiraReport) Write() {
+}
+
+func (r *JiraReport) Close() {
+	issues, err := r.openIssues()
+	if err != nil {
+		panic(err.Error())
+	}
+	var owners []string
+	for owner := range r.owners {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		summary := r.owners[owner]
+		issue, ok := issues[jiraOwnerLabel(owner)]
+		if len(summary.noncompliant) == 0 && ok {
+			err = r.close(issue)
+		} else if len(summary.noncompliant) > 0 && ok {
+			err = r.call(http.MethodPut, "/rest/api/2/issue/"+issue, map[string]interface{}{
+				"fields": map[string]interface{}{"description": r.description(owner, summary)},
+			}, nil)
+		} else if len(summary.noncompliant) > 0 {
+			err = r.call(http.MethodPost, "/rest/api/2/issue", map[string]interface{}{
+				"fields": map[string]interface{}{
+					"project":     map[string]string{"key": r.project},
+					"issuetype":   map[string]string{"name": r.issueType},
+					"summary":     fmt.Sprintf("Tag compliance of the resources of %s", owner),
+					"description": r.description(owner, summary),
+					"labels":      []string{jiraLabel, jiraOwnerLabel(owner)},
+				},
+			}, nil)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("jira issue of %s: %v", owner, err))
+		}
+	}
+}
+
+// description lists the noncompliant resources of the owner in the Jira wiki markup
+func (r *JiraReport) description(owner string, summary *jiraOwner) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of the %d resources of %s matching %s miss required tags or carry invalid values, as of %s.\n\n",
+		len(summary.noncompliant), summary.resources, owner, r.search, time.Now().UTC().Format(time.RFC3339))
+	b.WriteString("||Type||Resource||Stack||Missing Tags||Invalid Tags||\n")
+	for i, resource := range summary.noncompliant {
+		if i == jiraMaxRows {
+			fmt.Fprintf(&b, "\nand %d more resources\n", len(summary.noncompliant)-jiraMaxRows)
+			break
+		}
+		name := resource.arn
+		if name == "" {
+			name = resource.resource.Name
+		}
+		fmt.Fprintf(&b, "|%s|%s|%s|%s|%s|\n", jiraEscape(extractType(resource.resource.Type)), jiraEscape(name),
+			jiraEscape(resource.resource.Stack.Name), jiraEscape(strings.Join(resource.missing, ", ")),
+			jiraEscape(strings.Join(resource.invalid, ", ")))
+	}
+	b.WriteString("\nThis issue is updated by aws-tag-report on each run and closed once every resource is compliant.\n")
+	return b.String()
+}
+
+// openIssues returns the keys of the open issues of the report by owner label
+func (r *JiraReport) openIssues() (map[string]string, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %q AND statusCategory != Done", r.project, jiraLabel)
+	issues := make(map[string]string)
+	for start := 0; ; {
+		var page struct {
+			Total  int         `json:"total"`
+			Issues []jiraIssue `json:"issues"`
+		}
+		query := url.Values{"jql": {jql}, "fields": {"labels"}, "startAt": {fmt.Sprint(start)}, "maxResults": {"100"}}
+		if err := r.call(http.MethodGet, "/rest/api/2/search?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			for _, label := range issue.Fields.Labels {
+				if label != jiraLabel {
+					issues[label] = issue.Key
+				}
+			}
+		}
+		start += len(page.Issues)
+		if len(page.Issues) == 0 || start >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+// close transitions the issue to the first status of the done category
+func (r *JiraReport) close(issue string) error {
+	var transitions struct {
+		Transitions []struct {
+			Id string `json:"id"`
+			To struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + issue + "/transitions"
+	if err := r.call(http.MethodGet, path, nil, &transitions); err != nil {
+		return err
+	}
+	for _, transition := range transitions.Transitions {
+		if transition.To.StatusCategory.Key == "done" {
+			return r.call(http.MethodPost, path, map[string]interface{}{
+				"transition": map[string]string{"id": transition.Id},
+			}, nil)
+		}
+	}
+	return fmt.Errorf("%s has no transition to a done status", issue)
+}
+
+// call sends a request to the Jira REST api, decoding the response into result when set
+func (r *JiraReport) call(method string, path string, body interface{}, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	request, err := http.NewRequest(method, r.url+path, reader)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(os.Getenv("JIRA_USER"), os.Getenv("JIRA_API_TOKEN"))
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("jira responded %s to %s %s", response.Status, method, path)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+var jiraLabelInvalid = regexp.MustCompile(`\s+`)
+
+// jiraOwnerLabel returns the label of the issue of an owner, labels not taking spaces
+func jiraOwnerLabel(owner string) string {
+	return "owner:" + jiraLabelInvalid.ReplaceAllString(owner, "_")
+}
+
+// jiraEscape escapes the characters of text that the Jira wiki markup reads in tables, an
+// empty cell being a space so the table keeps its columns
+func jiraEscape(text string) string {
+	if text == "" {
+		return " "
+	}
+	return strings.NewReplacer("|", "\\|", "{", "\\{", "}", "\\}", "[", "\\[", "]", "\\]").Replace(text)
+}
@@ -0,0 +1,91 @@
+//go:build lambda
+// +build lambda
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+)
+
+// lambdaOutput is the default -output of the handler, written below the only writable
+// directory of the function and uploaded by -s3-uri
+const lambdaOutput = "report-{account}-{region}-{date}"
+
+// LambdaEvent is the payload of the handler, the search and the command line options
+// e.g. {"search": "my-stack-*", "args": ["-format", "jsonl", "-s3-uri", "s3://bucket/reports/"]}
+type LambdaEvent struct {
+	Search string   `json:"search"`
+	Args   []string `json:"args"`
+}
+
+// LambdaResult summarizes the run
+type LambdaResult struct {
+	Account         string `json:"account"`
+	Region          string `json:"region"`
+	Search          string `json:"search"`
+	Reports         string `json:"reports"`
+	Resources       int    `json:"resources"`
+	Compliant       int    `json:"compliant"`
+	NotSupported    int    `json:"notSupported"`
+	Errors          int    `json:"errors"`
+	ClassicCoverage int    `json:"classicCoverage"`
+	ModernCoverage  int    `json:"modernCoverage"`
+}
+
+func init() {
+	lambdaStart = func() {
+		lambda.Start(handleLambda)
+	}
+}
+
+// handleLambda scans the resources of the event, the reports being uploaded to s3
+func handleLambda(ctx context.Context, event LambdaEvent) (*LambdaResult, error) {
+	args := append([]string{"-output", lambdaOutput}, event.Args...)
+	if event.Search != "" {
+		args = append(args, event.Search)
+	}
+	var usage bytes.Buffer
+	options, err := parseOptions(&usage, args)
+	if err == flag.ErrHelp {
+		return nil, fmt.Errorf("%s", usage.String())
+	} else if err != nil {
+		return nil, err
+	}
+	if options.S3URI == "" {
+		return nil, fmt.Errorf("the lambda handler requires -s3-uri, the reports being uploaded to s3")
+	}
+	if options.Output == lambdaOutput {
+		options.Output += "." + options.Format
+	}
+	if err := os.Chdir(os.TempDir()); err != nil {
+		return nil, err
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	totals, err := run(ctx, cfg, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LambdaResult{
+		Account:         getAccount(ctx, cfg),
+		Region:          cfg.Region,
+		Search:          options.Search,
+		Reports:         options.S3URI,
+		Resources:       totals.resources,
+		Compliant:       totals.compliant,
+		NotSupported:    totals.notSupported,
+		Errors:          totals.errors,
+		ClassicCoverage: totals.averageClassic(),
+		ModernCoverage:  totals.averageModern(),
+	}, nil
+}
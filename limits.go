@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// the tag limits of aws resources, the aws: keys not counting against the number of tags
+const (
+	maxTags           = 50
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+	// nearTagLimit is the number of tags from which a resource is close to the limit
+	nearTagLimit = 45
+)
+
+// tagLimitViolations are the tags of a resource breaking the limits of aws, or about to once
+// the required keys it misses are added, tagging failing until its tags are cleaned up
+func tagLimitViolations(tags map[string]string, required []string) []string {
+	var messages []string
+	count := 0
+	for key, value := range tags {
+		if !isReservedKey(key) {
+			count++
+		}
+		if length := utf8.RuneCountInString(key); length > maxTagKeyLength {
+			messages = append(messages, fmt.Sprintf("tag limit: key %s is %d characters long, %d at most", key, length, maxTagKeyLength))
+		}
+		if length := utf8.RuneCountInString(value); length > maxTagValueLength {
+			messages = append(messages, fmt.Sprintf("tag limit: value of %s is %d characters long, %d at most", key, length, maxTagValueLength))
+		}
+	}
+	sort.Strings(messages)
+
+	_, missing := extractKeys(tags, required)
+	for _, key := range missing {
+		if isReservedKey(key) {
+			messages = append(messages, fmt.Sprintf("tag limit: key %s uses the reserved aws: prefix and cannot be added", key))
+		}
+	}
+	if count > maxTags {
+		messages = append(messages, fmt.Sprintf("tag limit: %d tags, %d at most", count, maxTags))
+	} else if count+len(missing) > maxTags {
+		messages = append(messages, fmt.Sprintf("tag limit: %d tags, adding the %d missing keys exceeds %d", count, len(missing), maxTags))
+	} else if count >= nearTagLimit {
+		messages = append(messages, fmt.Sprintf("tag limit: %d tags, close to %d", count, maxTags))
+	}
+	return messages
+}
+
+// isReservedKey tells whether a key uses the aws: prefix reserved to the tags set by aws
+func isReservedKey(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "aws:")
+}
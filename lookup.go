@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"reflect"
+	"runtime"
+	"strings"
 )
 
 type TagsNotSupportedError struct {
@@ -47,29 +49,49 @@ arnF1 := func(service string) func(string) string {
 
 // https://docs.aws.amazon.com/general/latest/gr/aws-arns-and-namespaces.html#arns-syntax
 // arn:partition:service:region:account-id:resource-type/resource-id
-var arnF2 = func(region string, account string, service string, resource string) func(string) string {
+var arnF2 = func(partition string, region string, account string, service string, resource string) func(string) string {
 	return func(id string) string {
-		return fmt.Sprintf("arn:aws:%s:%s:%s:%s/%s", service, region, account, resource, id)
+		return fmt.Sprintf("arn:%s:%s:%s:%s:%s/%s", partition, service, region, account, resource, id)
 	}
 }
 
 // arn:partition:service:region:account-id:resource-type:resource-id
-var arnF3 = func(region string, account string, service string, resource string) func(string) string {
+var arnF3 = func(partition string, region string, account string, service string, resource string) func(string) string {
 	return func(id string) string {
-		return fmt.Sprintf("arn:aws:%s:%s:%s:%s:%s", service, region, account, resource, id)
+		return fmt.Sprintf("arn:%s:%s:%s:%s:%s:%s", partition, service, region, account, resource, id)
 	}
 }
 
+// resourceLookup looks up the tags of a resource by physical id through the api named
+// service:Operation, none for the types which don't support tagging
+type resourceLookup struct {
+	api  string
+	tags func(context.Context, aws.Config, string) (map[string]string, error)
+}
+
 // For resources which don't support tagging
-func nop(resourceType string) func(context.Context, aws.Config, string) (map[string]string, error) {
-	return func(context.Context, aws.Config, string) (map[string]string, error) {
+func nop(resourceType string) resourceLookup {
+	return resourceLookup{tags: func(context.Context, aws.Config, string) (map[string]string, error) {
 		return nil, &TagsNotSupportedError{resourceType}
+	}}
+}
+
+// apiName names the api of an sdk request method as service:Operation, e.g. the
+// lambda.(*Client).ListTagsRequest method value being lambda:ListTags
+func apiName(method interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	service := name[strings.LastIndex(name, "/")+1:]
+	if i := strings.Index(service, "."); i >= 0 {
+		service = service[:i]
 	}
+	operation := strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "Request")
+	return service + ":" + operation
 }
 
 // Will use the tagLook parameter to call each resource API to get the tagging details
 // Parameters for tagLook function will be received as an array of InputParam
-func wrap(tagLookup interface{}, parameters...InputParam) func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+func wrap(tagLookup interface{}, parameters...InputParam) resourceLookup {
 	t, fn := reflect.TypeOf(tagLookup), reflect.ValueOf(tagLookup)
 	if t.Kind() != reflect.Func {
 		panic(fmt.Errorf("wrap called on non-func type, %v", t))
@@ -84,7 +106,7 @@ func wrap(tagLookup interface{}, parameters...InputParam) func(ctx context.Conte
 	for inputType.Kind() == reflect.Ptr {
 		inputType = inputType.Elem()
 	}
-	return func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
+	return resourceLookup{apiName(tagLookup), func(ctx context.Context, config aws.Config, id string) (map[string]string, error) {
 		// create the Input object for each function call
 		// by convention AWS uses an Input type for each operation
 		input := reflect.New(inputType)
@@ -145,16 +167,21 @@ func wrap(tagLookup interface{}, parameters...InputParam) func(ctx context.Conte
 				if ok {
 					return tagsMap, nil
 				}
-				// some API's return an array of objects with Key & Value fields
+				// some API's return an array of objects with Key & Value fields,
+				// or TagKey & TagValue as KMS does
 				var tags map[string]string = nil
 				for _, tagsField := range []string{"Tags","TagSet","TagList"} {
 					fieldValue := outValue.FieldByName(tagsField)
 					if tagsField == field.Name && fieldValue.Kind() == reflect.Slice {
 						tags = make(map[string]string, fieldValue.Len())
+						keyField, valueField := "Key", "Value"
+						if _, ok := fieldValue.Type().Elem().FieldByName("TagKey"); ok {
+							keyField, valueField = "TagKey", "TagValue"
+						}
 						for i := 0; i < fieldValue.Len(); i++ {
 							item := fieldValue.Index(i)
-							key := item.FieldByName("Key").Elem().String()
-							value := item.FieldByName("Value").Elem().String()
+							key := item.FieldByName(keyField).Elem().String()
+							value := item.FieldByName(valueField).Elem().String()
 							tags[key] = value
 						}
 					}
@@ -173,7 +200,7 @@ func wrap(tagLookup interface{}, parameters...InputParam) func(ctx context.Conte
 		// or this response may not even the tags information
 		return nil, fmt.Errorf("tags field not found in %s: %s",
 			outType.Name(), Prettify(outValue.Interface()))
-	}
+	}}
 }
 
 func containsString(col []string, want string) bool {
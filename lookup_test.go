@@ -0,0 +1,261 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/aws/defaults"
+)
+
+// update rewrites the golden files from the current outputs: go test -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// the account and region of the fixtures
+const (
+	goldenAccount = "123456789012"
+	goldenRegion  = "eu-west-1"
+)
+
+// goldenLookup is the request sent and the tags read by the lookup of a resource type
+type goldenLookup struct {
+	Type      string            `json:"type"`
+	Operation string            `json:"operation"`
+	Input     interface{}       `json:"input"`
+	Tags      map[string]string `json:"tags"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// fixtureConfig is a config whose requests are answered by the body of the current fixture
+// rather than sent, the request being recorded
+func fixtureConfig(body *[]byte, request **aws.Request) aws.Config {
+	cfg := defaults.Config()
+	cfg.Region = goldenRegion
+	cfg.Credentials = aws.NewStaticCredentialsProvider("AKID", "SECRET", "")
+	cfg.Retryer = aws.NoOpRetryer{}
+	cfg.Handlers.Send.Clear()
+	cfg.Handlers.Send.PushBack(func(r *aws.Request) {
+		*request = r
+		r.HTTPResponse = &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewReader(*body)),
+		}
+	})
+	return cfg
+}
+
+// fixturePath is the canned response of the api of a resource type, testdata/lookups/
+// AWS_S3_Bucket.xml for AWS::S3::Bucket, in the xml or json of the protocol of the service
+func fixturePath(resourceType string) string {
+	matches, _ := filepath.Glob(filepath.Join("testdata", "lookups", strings.ReplaceAll(resourceType, "::", "_")+".*"))
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0]
+}
+
+// fixtureId is the physical id of the resource of a type, an ARN for the types whose
+// physical id is one
+func fixtureId(resourceType string) string {
+	switch resourceType {
+	case "AWS::SNS::Topic":
+		return "arn:aws:sns:eu-west-1:123456789012:golden-topic"
+	case "AWS::GlobalAccelerator::Accelerator":
+		return "arn:aws:globalaccelerator::123456789012:accelerator/golden"
+	case "AWS::ServiceCatalog::CloudFormationProvisionedProduct":
+		return "pp-golden"
+	case "AWS::CloudFormation::Stack":
+		return "arn:aws:cloudformation:eu-west-1:123456789012:stack/golden-nested/1"
+	}
+	return "golden-" + strings.ToLower(resourceType[strings.LastIndex(resourceType, ":")+1:])
+}
+
+// lookupFixtures runs the lookup of every supported resource type against its fixture, each
+// supported type requiring one
+func lookupFixtures(t *testing.T) []goldenLookup {
+	var body []byte
+	var request *aws.Request
+	cfg := fixtureConfig(&body, &request)
+	lookups := newLookups(cfg, "aws", goldenRegion, goldenAccount)
+
+	var types []string
+	for resourceType, lookup := range lookups {
+		if lookup.api != "" {
+			types = append(types, resourceType)
+		}
+	}
+	sort.Strings(types)
+
+	var results []goldenLookup
+	for _, resourceType := range types {
+		path := fixturePath(resourceType)
+		if path == "" {
+			t.Errorf("%s has no fixture in testdata/lookups", resourceType)
+			continue
+		}
+		var err error
+		if body, err = ioutil.ReadFile(path); err != nil {
+			t.Fatal(err)
+		}
+		request = nil
+		tags, err := lookups[resourceType].tags(context.Background(), cfg, fixtureId(resourceType))
+		result := goldenLookup{Type: resourceType, Tags: tags}
+		if request != nil {
+			result.Operation = request.Operation.Name
+			result.Input = request.Params
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkGolden compares an output with its golden file, or rewrites it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to write it", err)
+	}
+	if !bytes.Equal(got, want) && utf8.Valid(got) {
+		t.Errorf("%s differs from the output, run go test -update if the change is expected\n--- got\n%s", path, got)
+	} else if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the output, run go test -update if the change is expected", path)
+	}
+}
+
+func TestGoldenLookups(t *testing.T) {
+	results := lookupFixtures(t)
+	for _, result := range results {
+		if result.Error != "" {
+			t.Errorf("%s: %s", result.Type, result.Error)
+		}
+	}
+	got, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "lookups.golden.json", append(got, '\n'))
+}
+
+func TestGoldenReports(t *testing.T) {
+	policy, err := loadPolicy(context.Background(), aws.Config{}, "")
+	if err == nil {
+		err = policy.apply(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	arn := newArnResolver("aws", goldenRegion, goldenAccount)
+	output := &Output{Path: filepath.Join(dir, "report.csv")}
+	var jsonReport, xlsxReport, parquetReport bytes.Buffer
+	parquet := NewParquetReporter(&parquetReport, "", goldenAccount, goldenRegion, "golden")
+	parquet.date = "2024-01-01"
+	report := multiReporter{
+		NewReporter(output, CSVDialect{}, false, "", false, nil, false, false, false),
+		NewJSONReporter(&jsonReport, false, nil),
+		NewXLSXReporter(&xlsxReport, false),
+		parquet,
+	}
+	stack := Stack{
+		Name:   "golden-stack",
+		Id:     "arn:aws:cloudformation:eu-west-1:123456789012:stack/golden-stack/1",
+		Origin: "CUSTOM",
+	}
+	for _, result := range lookupFixtures(t) {
+		resource := Resource{Type: result.Type, Name: fixtureId(result.Type), Stack: stack}
+		tags, aliases := applyAliases(result.Tags)
+		resource.Aliases = aliases
+		report.Add(newResult(resource, "golden", tags, arn, goldenAccount, goldenRegion))
+	}
+	report.Add(newUnsupportedResult(Resource{Type: "AWS::IAM::Policy", Name: "golden-policy", Stack: stack}, "golden", nil,
+		arn, goldenAccount, goldenRegion))
+	report.Add(newUnsupportedResult(Resource{Type: "AWS::SQS::Queue", Name: "golden-replaced", Stack: stack}, "golden",
+		&StaleReferenceError{"AWS::SQS::Queue", "golden-replaced", awserr.New("ResourceNotFoundException", "queue not found", nil)},
+		arn, goldenAccount, goldenRegion))
+	skipped := skippedStackResource(Stack{
+		Name:   "golden-denied",
+		Id:     "arn:aws:cloudformation:eu-west-1:123456789012:stack/golden-denied/1",
+		Origin: "CUSTOM",
+	}, awserr.New("AccessDenied", "denied by a service control policy", nil))
+	report.Add(newUnsupportedResult(skipped.Resource(), "golden", skipped.Skipped, arn, goldenAccount, goldenRegion))
+	report.Close()
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	csvReport, err := ioutil.ReadFile(output.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.golden.csv", csvReport)
+	checkGolden(t, "report.golden.json", jsonReport.Bytes())
+	checkWorkbook(t, xlsxReport.Bytes())
+	checkGolden(t, "report.golden.xlsx", xlsxReport.Bytes())
+	// a parquet file starts and ends with its magic number, the footer length before the last one
+	if parquet := parquetReport.Bytes(); !bytes.HasPrefix(parquet, []byte("PAR1")) || !bytes.HasSuffix(parquet, []byte("PAR1")) {
+		t.Errorf("the parquet report is not framed by PAR1")
+	}
+	checkGolden(t, "report.golden.parquet", parquetReport.Bytes())
+}
+
+// checkWorkbook reads the parts of an xlsx report, each sheet being required to be well
+// formed xml
+func checkWorkbook(t *testing.T, workbook []byte) {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(workbook), int64(len(workbook)))
+	if err != nil {
+		t.Fatalf("the xlsx report is not a zip archive: %v", err)
+	}
+	var sheets int
+	for _, part := range archive.File {
+		f, err := part.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := xml.NewDecoder(f)
+		for err == nil {
+			_, err = decoder.Token()
+		}
+		f.Close()
+		if err != io.EOF {
+			t.Errorf("%s of the xlsx report: %v", part.Name, err)
+		}
+		if strings.HasPrefix(part.Name, "xl/worksheets/") {
+			sheets++
+		}
+	}
+	if sheets != 4 {
+		t.Errorf("the xlsx report has %d sheets, expected 4", sheets)
+	}
+}
@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
@@ -14,10 +15,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/firehose"
+	"github.com/aws/aws-sdk-go-v2/service/globalaccelerator"
 	"github.com/aws/aws-sdk-go-v2/service/glue"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
@@ -25,58 +28,197 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 )
 
 var (
 	resourceType = "resource-type"
 )
 
+// subcommand runs a subcommand with its arguments, writing its output to w and its usage and
+// progress to stderr
+type subcommand func(w io.Writer, stderr io.Writer, args []string) error
+
+// subcommands are the subcommands by name, the scan running without one
+var subcommands = map[string]subcommand{
+	"serve": func(w io.Writer, stderr io.Writer, args []string) error {
+		return serve(stderr, args)
+	},
+	"trend":          trend,
+	"unallocated":    unallocated,
+	"apply":          apply,
+	"propagate":      propagate,
+	"untag":          untag,
+	"fix":            fix,
+	"groups":         groups,
+	"list-supported": listSupported,
+	"tag-options":    tagOptions,
+	"history":        history,
+	"diff":           diff,
+}
+
+// runSubcommand runs a subcommand, returning the exit status: 2 when the usage was printed, as
+// for the scan, and 1 when the subcommand failed
+func runSubcommand(command subcommand, args []string) int {
+	if err := command(os.Stdout, os.Stderr, args); err == flag.ErrHelp {
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// lambdaStart serves the Lambda handler instead of the command line, in the builds with
+// the lambda tag
+var lambdaStart func()
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("usage: aws-tag-report searchString > reportFile" +
-			"\n\tsearchString: will select any cloudformation stack with searchString within its name" +
-			"\n\treportFile: file to redirect  csv output")
+	if lambdaStart != nil {
+		lambdaStart()
 		return
 	}
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(runSubcommand(command, os.Args[2:]))
+		}
+	}
+	options, err := parseOptions(os.Stderr, os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	stopProfiling, err := startProfiling(options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	defer stopProfiling()
+	// os.Exit skips the deferred calls
+	exit := func(code int) {
+		stopProfiling()
+		os.Exit(code)
+	}
 
 	ctx := context.TODO()
 	cfg, err := external.LoadDefaultAWSConfig()
 	if err != nil {
 		panic("unable to load SDK config, " + err.Error())
 	}
+	if options.TUI {
+		tui, err := newTUI(os.Stdin, os.Stdout)
+		var totals *stackSummary
+		if err == nil {
+			totals, err = run(ctx, cfg, options, nil, tui)
+		}
+		if err == nil {
+			err = tui.browse()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit(runExitCode(err))
+		}
+		if err := checkCoverage(options, totals); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit(1)
+		}
+		return
+	}
+	if options.Watch == 0 {
+		totals, err := run(ctx, cfg, options, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit(runExitCode(err))
+		}
+		if err := checkCoverage(options, totals); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit(1)
+		}
+		return
+	}
+	state := newWatchState(os.Stderr)
+	for {
+		if err := watch(ctx, cfg, options, state); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			exit(2)
+		}
+		time.Sleep(options.Watch)
+	}
+}
 
-	servicecatalogClient := servicecatalog.New(cfg)
-	lambdaClient := lambda.New(cfg)
-	ssmClient := ssm.New(cfg)
-	s3Client := s3.New(cfg)
-	glueClient := glue.New(cfg)
-	iamClient := iam.New(cfg)
-	snsClient := sns.New(cfg)
-	ec2Client := ec2.New(cfg)
-	dynamodbClient := dynamodb.New(cfg)
-	firehoseClient := firehose.New(cfg)
-	cloudwatchlogsClient := cloudwatchlogs.New(cfg)
-	cloudwatchClient := cloudwatch.New(cfg)
-	cloudwatcheventsClient := cloudwatchevents.New(cfg)
-	configserviceClient := configservice.New(cfg)
-	kmsClient := kms.New(cfg)
+// checkCoverage returns an error when the average coverage of the run is below the
+// -fail-under-modern or -fail-under-classic threshold
+func checkCoverage(options *Options, totals *stackSummary) error {
+	if options.FailUnderModern > 0 && totals.averageModern() < options.FailUnderModern {
+		return fmt.Errorf("modern coverage %d%% is below %d%%", totals.averageModern(), options.FailUnderModern)
+	}
+	if options.FailUnderClassic > 0 && totals.averageClassic() < options.FailUnderClassic {
+		return fmt.Errorf("classic coverage %d%% is below %d%%", totals.averageClassic(), options.FailUnderClassic)
+	}
+	return nil
+}
 
-	region := cfg.Region
-	account := getAccount(ctx, cfg)
-	lookups := map[string]func(context.Context, aws.Config, string) (map[string]string, error) {
+// runExitCode is the exit code of a failed run, 1 for a failed scan and 2 for an invalid
+// configuration
+func runExitCode(err error) int {
+	if _, ok := err.(*ScanError); ok {
+		return 1
+	}
+	return 2
+}
+
+// watch runs a scan of -watch, its failure being reported without stopping the next scans
+func watch(ctx context.Context, cfg aws.Config, options *Options, state *WatchState) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(os.Stderr, "scan failed:", r)
+		}
+	}()
+	_, err = run(ctx, cfg, options, state)
+	if se, ok := err.(*ScanError); ok {
+		fmt.Fprintln(os.Stderr, "scan failed:", se.Error())
+		return nil
+	}
+	return err
+}
+
+// newLookups returns the tag lookup of each supported resource type, and of the types known
+// not to support tags
+func newLookups(cfg aws.Config, partition string, region string, account string) map[string]resourceLookup {
+	servicecatalogClient := servicecatalog.New(serviceConfig(cfg, partition, "servicecatalog"))
+	lambdaClient := lambda.New(serviceConfig(cfg, partition, "lambda"))
+	ssmClient := ssm.New(serviceConfig(cfg, partition, "ssm"))
+	s3Client := s3.New(serviceConfig(cfg, partition, "s3"))
+	glueClient := glue.New(serviceConfig(cfg, partition, "glue"))
+	iamClient := iam.New(serviceConfig(cfg, partition, "iam"))
+	snsClient := sns.New(serviceConfig(cfg, partition, "sns"))
+	ec2Client := ec2.New(serviceConfig(cfg, partition, "ec2"))
+	dynamodbClient := dynamodb.New(serviceConfig(cfg, partition, "dynamodb"))
+	firehoseClient := firehose.New(serviceConfig(cfg, partition, "firehose"))
+	cloudwatchlogsClient := cloudwatchlogs.New(serviceConfig(cfg, partition, "cloudwatchlogs"))
+	cloudwatchClient := cloudwatch.New(serviceConfig(cfg, partition, "cloudwatch"))
+	cloudwatcheventsClient := cloudwatchevents.New(serviceConfig(cfg, partition, "cloudwatchevents"))
+	configserviceClient := configservice.New(serviceConfig(cfg, partition, "configservice"))
+	kmsClient := kms.New(serviceConfig(cfg, partition, "kms"))
+	cloudfrontClient := cloudfront.New(serviceConfig(cfg, partition, "cloudfront"))
+	route53Client := route53.New(serviceConfig(cfg, partition, "route53"))
+	globalacceleratorClient := globalaccelerator.New(serviceConfig(cfg, partition, "globalaccelerator"))
+
+	return map[string]resourceLookup{
 		// Lambda
 		"AWS::Lambda::Function":
 			wrap(lambdaClient.ListTagsRequest,
-				InputParam{"Resource", arnF3(region, account, "lambda", "function")}),
+				InputParam{"Resource", arnF3(partition, serviceRegion(region, "lambda"), account, "lambda", "function")}),
 		// SSM
 		"AWS::SSM::Parameter":
 			wrap(ssmClient.ListTagsForResourceRequest,
 				InputParam{"ResourceId", physicalResourceId},
 				InputParam{"ResourceType", ssm.ResourceTypeForTaggingParameter}),
 		// Service Catalog
-		"AWS::ServiceCatalog::CloudFormationProduct":
-			wrap(servicecatalogClient.DescribeProductRequest,
-				InputParam{"Id", physicalResourceId}),
+		"AWS::ServiceCatalog::CloudFormationProduct": {"servicecatalog:DescribeProductAsAdmin", getProductTags(servicecatalogClient)},
+		"AWS::ServiceCatalog::CloudFormationProvisionedProduct": {"servicecatalog:SearchProvisionedProducts", getProvisionedProductTags(servicecatalogClient)},
 		"AWS::ServiceCatalog::Portfolio":
 			wrap(servicecatalogClient.DescribePortfolioRequest,
 				InputParam{"Id", physicalResourceId}),
@@ -116,17 +258,17 @@ func main() {
 		// Glue
 		"AWS::Glue::Crawler":
 			wrap(glueClient.GetTagsRequest,
-				InputParam{"ResourceArn", arnF2(region, account, "glue", "crawler")}),
+				InputParam{"ResourceArn", arnF2(partition, serviceRegion(region, "glue"), account, "glue", "crawler")}),
 		"AWS::Glue::Job":
 			wrap(glueClient.GetTagsRequest,
-				InputParam{"ResourceArn", arnF2(region, account, "glue", "job")}),
+				InputParam{"ResourceArn", arnF2(partition, serviceRegion(region, "glue"), account, "glue", "job")}),
 		"AWS::Glue::Trigger":
 			wrap(glueClient.GetTagsRequest,
-				InputParam{"ResourceArn", arnF2(region, account, "glue", "trigger")}),
+				InputParam{"ResourceArn", arnF2(partition, serviceRegion(region, "glue"), account, "glue", "trigger")}),
 		// DynamoDB
 		"AWS::DynamoDB::Table":
 			wrap(dynamodbClient.ListTagsOfResourceRequest,
-				InputParam{"ResourceArn", arnF2(region, account, "dynamodb", "table")}),
+				InputParam{"ResourceArn", arnF2(partition, serviceRegion(region, "dynamodb"), account, "dynamodb", "table")}),
 		// Kinesis Firehose
 		"AWS::KinesisFirehose::DeliveryStream":
 			wrap(firehoseClient.ListTagsForDeliveryStreamRequest,
@@ -138,19 +280,32 @@ func main() {
 		// Cloudwatch
 		"AWS::Cloudwatch::Alarm":
 			wrap(cloudwatchClient.ListTagsForResourceRequest,
-				InputParam{"ResourceARN", arnF3(region, account, "cloudwatch", "alarm")}),
+				InputParam{"ResourceARN", arnF3(partition, serviceRegion(region, "cloudwatch"), account, "cloudwatch", "alarm")}),
 		// Events
 		"AWS::Events::Rule":
 			wrap(cloudwatcheventsClient.ListTagsForResourceRequest,
-				InputParam{"ResourceARN", arnF2(region, account, "events", "rule")}),
+				InputParam{"ResourceARN", arnF2(partition, serviceRegion(region, "cloudwatchevents"), account, "events", "rule")}),
 		// Config
 		"AWS::Config::ConfigRule":
 			wrap(configserviceClient.ListTagsForResourceRequest,
-				InputParam{"ResourceArn", arnF2(region, account, "config", "config-rule")}),
+				InputParam{"ResourceArn", arnF2(partition, serviceRegion(region, "configservice"), account, "config", "config-rule")}),
 		// KMS
 		"AWS::KMS::Key":
 			wrap(kmsClient.ListResourceTagsRequest,
 				InputParam{"KeyId", physicalResourceId}),
+		// CloudFront
+		"AWS::CloudFront::Distribution": {"cloudfront:ListTagsForResource",
+			getDistributionTags(cloudfrontClient, func(id string) string {
+				return fmt.Sprintf("arn:%s:cloudfront::%s:distribution/%s", partition, account, id)
+			})},
+		// Route 53
+		"AWS::Route53::HostedZone": {"route53:ListTagsForResource", getHostedZoneTags(route53Client)},
+		// Global Accelerator
+		"AWS::GlobalAccelerator::Accelerator":
+			wrap(globalacceleratorClient.ListTagsForResourceRequest,
+				InputParam{"ResourceArn", physicalResourceId}),
+		// CloudFormation
+		"AWS::CloudFormation::Stack": {"cloudformation:DescribeStacks", getStackTags},
 
 		//////// TAGS NOT SUPPORTED ////////
 		// Lambda
@@ -185,50 +340,263 @@ func main() {
 		// CloudFormation
 		"AWS::CloudFormation::Macro": nop("AWS::CloudFormation::Macro"),
 	}
+}
+
+// run scans the resources and reports them as the options tell, returning the totals of
+// the run; the errors of the configuration are returned, as are the lookups and the glue and
+// quicksight registrations failing with an error to fail on, as a ScanError, while the other
+// errors of the scan panic.
+// The resources unchanged since the previous scan of the watch state, if any, are left out,
+// and the sinks also receive every resource reported.
+func run(ctx context.Context, cfg aws.Config, options *Options, state *WatchState, sinks ...Reporter) (*stackSummary, error) {
+	normalizeKeys = options.NormalizeKeys
+	serviceRegions = options.ServiceRegions
+	fallbackRoleArn = options.FallbackRoleArn
+	errorActions = newErrorActions(options.ErrorActions)
+	arnOverrides = nil
+	includeProducts = options.IncludeProducts
+	// the policy may be fetched from parameter store or appconfig
+	policy, err := loadPolicy(ctx, cfg, options.TagPolicy)
+	if err == nil {
+		err = policy.apply(options.Schemes)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	search := &os.Args[1]
-	report := NewReporter()
+	exemptions, err := loadExemptions(options.Exemptions, os.Stderr, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var tagSchema *TagSchema
+	if options.TagSchema != "" {
+		tagSchema, err = loadTagSchema(options.TagSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	for r, resource := range getStackResources(ctx, cfg, search) {
+	region := cfg.Region
+	account := getAccount(ctx, cfg)
+	partition := options.Partition
+	if partition == "" {
+		partition = getPartition(cfg)
+	}
+	lookups := newLookups(cfg, partition, region, account)
+	arn := newArnResolver(partition, region, account)
+	var regoPolicy *RegoPolicy
+	if options.Rego != "" {
+		regoPolicy, err = loadRegoPolicy(ctx, options.Rego, arn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.CostAllocation {
+		costAllocation = getCostAllocationTags(ctx, cfg)
+	}
+	if options.AccountTags {
+		if accountTags, err = getAccountTags(ctx, serviceConfig(cfg, partition, "organizations"), account); err != nil {
+			return nil, err
+		}
+	}
+	var costs Costs
+	if options.MonthlyCost != "" {
+		costs = getCosts(ctx, cfg, options, account, region)
+	}
+
+	// the report files are uploaded to s3 as they are completed
+	var upload func(path string)
+	var glueTable *GlueTable
+	var s3Upload *S3Upload
+	if options.S3URI != "" {
+		s3Upload, err = newS3Upload(ctx, cfg, options.S3URI, options.S3KMSKey, account, region, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		upload = s3Upload.upload
+		if options.GlueTable != "" {
+			glueTable = newGlueTable(ctx, cfg, options.GlueTable, options, s3Upload)
+		}
+	}
+
+	report := newReporter(options, partition, account, region, s3Upload, newRouter(ctx, cfg, options, os.Stderr))
+	if options.ConfigCheckFile != "" {
+		rules := getRequiredTagsRules(ctx, cfg)
+		warnUncoveredKeys(os.Stderr, rules)
+		output := &Output{Path: expandPath(options.ConfigCheckFile, account, region, time.Now()), Upload: upload}
+		report = multiReporter{report, closingReporter{NewConfigCheckReporter(output.Open(), options.CSVDialect, rules), output}}
+	}
+	if options.HistoryTable != "" {
+		report = multiReporter{report, NewDynamoDBReporter(ctx, cfg, options.HistoryTable, account, region, options.Search)}
+	}
+	if options.EventBus != "" {
+		report = multiReporter{report, NewEventBridgeReporter(ctx, cfg, options.EventBus)}
+	}
+	if options.SecurityHub {
+		report = multiReporter{report, NewSecurityHubReporter(ctx, cfg, partition, account, region)}
+	}
+	if state != nil {
+		report = watchReporter{report, state}
+	}
+	if len(sinks) > 0 {
+		report = append(multiReporter{report}, sinks...)
+	}
+	totals := &stackSummary{}
+	report = multiReporter{report, totalsReporter{totals}, newStackHygiene(os.Stderr)}
+	if print, color := printsSummary(options); print {
+		report = multiReporter{report, NewTerminalSummary(os.Stderr, color)}
+	}
+	// the shared resources are counted once by the totals and the summary as well
+	if options.Dedupe {
+		report = newDedupeReporter(report)
+	}
+
+	// the search of each resource, the rows of the resources found by several searches
+	// being reported once for each
+	var resources []StackResource
+	var searches []string
+	if options.scansProducts() {
+		resources = getProvisionedProductResources(ctx, cfg,
+			options.ProvisionedProduct, options.Product, options.ProductVersion)
+		if options.IncludeStacks {
+			resources = append(stackResources(resources), resources...)
+		}
+		for range resources {
+			searches = append(searches, options.Search)
+		}
+	} else {
+		resources, searches = searchResources(ctx, cfg, options)
+	}
+	if options.IncludeProducts {
+		resources, searches = uniqueProducts(resources, searches)
+	}
+	if options.ArnsFromOutputs {
+		arnOverrides = outputArns(resources)
+	}
+	if options.Drift == "detect" {
+		detectDrift(ctx, cfg, os.Stderr, resources)
+	}
+	if options.CreatedByTrail {
+		creators := newStackCreators(ctx, cfg)
+		for i := range resources {
+			resources[i].Stack.Origin = creators.creator(resources[i].Stack)
+		}
+	}
+
+	// the lookups run ahead of the report, which reads their results in the order of the resources
+	scheduler := newLookupScheduler(options.LookupWorkers, options.ServiceWorkers)
+	for r, resource := range resources {
+		if resource.Skipped != nil || !options.matchesType(*resource.ResourceType) || strings.HasPrefix(*resource.ResourceType, "Custom::") {
+			continue
+		}
+		if lookup, ok := lookups[*resource.ResourceType]; ok {
+			id := *resource.PhysicalResourceId
+			scheduler.add(r, lookupService(lookup), func() (map[string]string, error) {
+				return lookup.tags(ctx, cfg, id)
+			})
+		}
+	}
+	scheduler.start()
+	defer scheduler.stop()
+
+	flushed := time.Now()
+	for r, resource := range resources {
+		reported := resource.Resource()
+		search := searches[r]
+		// the stacks whose resources could not be listed are reported whatever the types
+		if resource.Skipped != nil {
+			fmt.Fprintln(os.Stderr, resource.Skipped.Error())
+			report.Add(newUnsupportedResult(reported, search, resource.Skipped, arn, account, region))
+			continue
+		}
+		if !options.matchesType(*resource.ResourceType) {
+			continue
+		}
+		if costs != nil {
+			reported.MonthlyCost = costs.of(arn(reported.Type, reported.Name), reported.Name)
+		}
+		if options.Drift != "" {
+			reported.DriftStatus = driftStatus(resource)
+		}
 		// custom resources do not support tags
 		if strings.HasPrefix(*resource.ResourceType, "Custom::") {
 			err := TagsNotSupportedError{*resource.ResourceType}
 			fmt.Fprintln(os.Stderr, err.Error())
-			report.AddNotSupported(*resource.ResourceType, *resource.PhysicalResourceId, *resource.StackName, *search)
+			report.Add(newUnsupportedResult(reported, search, nil, arn, account, region))
 			continue
 		}
 		// get the proper tag lookup function
-		if lookup, ok := lookups[*resource.ResourceType]; ok {
-			tags, err := lookup(ctx, cfg, *resource.PhysicalResourceId)
+		if _, ok := lookups[*resource.ResourceType]; ok {
+			tags, err := scheduler.result(r)
 			if err == nil {
-				// tags lookup succeeded
-				report.Add(*resource.ResourceType, *resource.PhysicalResourceId, *resource.StackName, *search, tags)
+				// tags lookup succeeded, legacy keys standing for the current ones
+				tags, reported.Aliases = applyAliases(tags)
+				reported.Exemption = exemptions.exemption(reported, arn(reported.Type, reported.Name), tags)
+				if regoPolicy != nil && reported.Exemption == "" {
+					reported.Violations = regoPolicy.violations(ctx, reported, tags)
+				}
+				if tagSchema != nil && reported.Exemption == "" {
+					reported.Violations = append(reported.Violations, tagSchema.violations(tags)...)
+				}
+				if options.AccountTags && reported.Exemption == "" {
+					reported.Violations = append(reported.Violations, accountTagMismatches(tags, options.AccountTagKeys)...)
+				}
+				if options.TagLimits && reported.Exemption == "" {
+					reported.Violations = append(reported.Violations, tagLimitViolations(tags, modern.required(reported))...)
+				}
+				report.Add(newResult(reported, search, tags, arn, account, region))
 			} else {
-				// some errors should not stop processing resources
-				var ae awserr.Error
-				if ok := errors.As(err, &ae); ok && (
-						ae.Code() == configservice.ErrCodeResourceNotFoundException ||
-						ae.Code() == glue.ErrCodeEntityNotFoundException){
-					fmt.Fprintln(os.Stderr, ae.Error())
-					report.AddNotSupported(*resource.ResourceType, *resource.PhysicalResourceId, *resource.StackName, *search)
-				} else if ne, ok := err.(*TagsNotSupportedError); ok {
+				// the errors registered to skip or warn should not stop processing resources
+				if ne, ok := err.(*TagsNotSupportedError); ok {
 					fmt.Fprintln(os.Stderr, ne.Error())
-					report.AddNotSupported(*resource.ResourceType, *resource.PhysicalResourceId, *resource.StackName, *search)
+					report.Add(newUnsupportedResult(reported, search, nil, arn, account, region))
+				} else if action := errorAction(err); action == errorSkip {
+					continue
+				} else if action == errorWarn {
+					if isNotFound(err) {
+						// the resource was deleted or replaced outside of cloudformation
+						err = &StaleReferenceError{reported.Type, reported.Name, err}
+					}
+					fmt.Fprintln(os.Stderr, err.Error())
+					report.Add(newUnsupportedResult(reported, search, err, arn, account, region))
 				} else {
 					fmt.Fprintln(os.Stderr, reflect.TypeOf(err), Prettify(resource))
-					panic(err.Error())
+					return nil, &ScanError{fmt.Errorf("unable to look up the tags of %s %s, %v", reported.Type, reported.Name, err)}
 				}
 			}
 		} else {
 			err := NotImplementedError{*resource.ResourceType}
 			fmt.Fprintln(os.Stderr, err.Error(), Prettify(resource))
-			panic(err.Error())
+			return nil, &ScanError{&err}
 		}
 
-		if r % 1000 == 0 {
+		if (options.FlushEvery > 0 && r % options.FlushEvery == 0) ||
+			(options.FlushInterval > 0 && time.Since(flushed) >= options.FlushInterval) {
 			report.Write()
+			flushed = time.Now()
 		}
 	}
 
-	report.Write()
+	report.Close()
+	if glueTable != nil {
+		if err := publishError(os.Stderr, "register the glue table", glueTable.register()); err != nil {
+			return nil, err
+		}
+	}
+	if options.QuickSightManifest != "" {
+		err := newQuickSightManifest(s3Upload, options.CSVDialect).write(s3Upload, options.QuickSightManifest)
+		if err := publishError(os.Stderr, "write the quicksight manifest", err); err != nil {
+			return nil, err
+		}
+	}
+	if options.QuickSightDataSet != "" {
+		dataSet := newQuickSightDataSet(ctx, cfg, account, options.QuickSightDataSet, options.QuickSightOwner)
+		err := dataSet.register(options.QuickSightManifest, csvReportHeader(options), options.CSVDialect)
+		if err := publishError(os.Stderr, "register the quicksight data set", err); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
 }
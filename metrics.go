@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricsReport aggregates the coverage per stack and resource type into prometheus metrics,
+// written in the node exporter textfile collector format and/or pushed to a Pushgateway
+type MetricsReport struct {
+	file        string
+	pushgateway string
+	search      string
+	series      map[metricsKey]*metricsSeries
+}
+
+type metricsKey struct {
+	stack        string
+	resourceType string
+}
+
+type metricsSeries struct {
+	resources       int
+	compliant       int
+	notSupported    int
+	errors          int
+	classicCoverage int
+	modernCoverage  int
+}
+
+func NewMetricsReporter(file string, pushgateway string, search string) *MetricsReport {
+	return &MetricsReport{
+		file:        file,
+		pushgateway: pushgateway,
+		search:      search,
+		series:      make(map[metricsKey]*metricsSeries),
+	}
+}
+
+func (r *MetricsReport) Add(result Result) {
+	series := r.get(result.Resource.Stack.Name, result.Resource.Type)
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		series.errors++
+	case finding == nil:
+		series.notSupported++
+	default:
+		series.resources++
+		series.classicCoverage += finding.ClassicCoverage
+		series.modernCoverage += finding.ModernCoverage
+		if finding.compliant() {
+			series.compliant++
+		}
+	}
+}
+
+func (r *MetricsReport) get(stack string, resourceType string) *metricsSeries {
+	key := metricsKey{stack, resourceType}
+	series, ok := r.series[key]
+	if !ok {
+		series = &metricsSeries{}
+		r.series[key] = series
+	}
+	return series
+}
+
+// Write is a no-op, metrics are exposed once complete on Close
+func (r *MetricsReport) Write() {
+}
+
+func (r *MetricsReport) Close() {
+	body := r.exposition()
+	if r.file != "" {
+		if err := writeFileAtomic(r.file, body); err != nil {
+			panic(err.Error())
+		}
+	}
+	if r.pushgateway != "" {
+		if err := r.push(body); err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// exposition renders the metrics in the prometheus text format
+func (r *MetricsReport) exposition() []byte {
+	var keys []metricsKey
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].stack != keys[j].stack {
+			return keys[i].stack < keys[j].stack
+		}
+		return keys[i].resourceType < keys[j].resourceType
+	})
+
+	var b bytes.Buffer
+	metric := func(name string, kind string, help string, value func(key metricsKey, s *metricsSeries) []string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, key := range keys {
+			for _, line := range value(key, r.series[key]) {
+				fmt.Fprintf(&b, "%s%s\n", name, line)
+			}
+		}
+	}
+	labels := func(key metricsKey, extra ...string) string {
+		pairs := []string{"stack", key.stack, "type", key.resourceType}
+		pairs = append(pairs, extra...)
+		var l []string
+		for i := 0; i < len(pairs); i += 2 {
+			l = append(l, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+		}
+		return "{" + strings.Join(l, ",") + "}"
+	}
+
+	metric("tag_coverage_ratio", "gauge", "Average ratio of required tag keys present on the resources.",
+		func(key metricsKey, s *metricsSeries) []string {
+			if s.resources == 0 {
+				return nil
+			}
+			return []string{
+				fmt.Sprintf("%s %g", labels(key, "scheme", "classic"), float64(s.classicCoverage)/float64(100*s.resources)),
+				fmt.Sprintf("%s %g", labels(key, "scheme", "modern"), float64(s.modernCoverage)/float64(100*s.resources)),
+			}
+		})
+	metric("tag_resources", "gauge", "Number of resources whose tags were evaluated.",
+		func(key metricsKey, s *metricsSeries) []string {
+			return []string{fmt.Sprintf("%s %d", labels(key), s.resources)}
+		})
+	metric("tag_compliant_resources", "gauge", "Number of resources carrying every required modern tag key.",
+		func(key metricsKey, s *metricsSeries) []string {
+			return []string{fmt.Sprintf("%s %d", labels(key), s.compliant)}
+		})
+	metric("tag_unsupported_resources_total", "counter", "Number of resources which do not support tags.",
+		func(key metricsKey, s *metricsSeries) []string {
+			return []string{fmt.Sprintf("%s %d", labels(key), s.notSupported)}
+		})
+	metric("tag_error_resources_total", "counter", "Number of resources whose tags could not be looked up.",
+		func(key metricsKey, s *metricsSeries) []string {
+			return []string{fmt.Sprintf("%s %d", labels(key), s.errors)}
+		})
+	fmt.Fprintf(&b, "# HELP tag_report_last_run_timestamp_seconds Time the report completed.\n"+
+		"# TYPE tag_report_last_run_timestamp_seconds gauge\ntag_report_last_run_timestamp_seconds %d\n", time.Now().Unix())
+	return b.Bytes()
+}
+
+// push replaces the metrics of this search in the Pushgateway grouping
+func (r *MetricsReport) push(body []byte) error {
+	endpoint := fmt.Sprintf("%s/metrics/job/aws-tag-report/search/%s",
+		strings.TrimSuffix(r.pushgateway, "/"), url.PathEscape(r.search))
+	request, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway %s responded %s", endpoint, response.Status)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file renamed over path so readers never see partial content
+func writeFileAtomic(path string, body []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,679 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Options holds the command line configuration of a report run
+type Options struct {
+	Search       string
+	Searches     []string
+	IncludeTypes []string
+	ExcludeTypes []string
+	Partition    string
+	// ServiceRegions are the regions or endpoints of the services overridden by -service-region
+	ServiceRegions map[string]string
+	// FallbackRoleArn lists the resources of the stacks the caller may not
+	FallbackRoleArn string
+	// ErrorActions override the actions of the error codes of the lookups
+	ErrorActions map[string]string
+
+	GroupByConstruct bool
+	Format           string
+	// Formats are the formats of -format, Format being the first
+	Formats          []string
+	ParquetDir       string
+	SQLiteFile       string
+	CSVDialect       CSVDialect
+	Output           string
+	RotateRows       int
+	FlushEvery       int
+	FlushInterval    time.Duration
+	LookupWorkers    int
+	ServiceWorkers   int
+	SearchWorkers    int
+	PprofAddr        string
+	CPUProfile       string
+	MemProfile       string
+	SplitBy          string
+	RouteBy          string
+	RouteTo          string
+	RouteFrom        string
+	SummaryFile      string
+	MissingTagsFile  string
+	ConfigCheckFile  string
+	BadgesDir        string
+	BadgesBy         string
+	ValueKeys        []string
+	GroupBy          string
+	Sort             bool
+	OnlyNoncompliant bool
+	MinCoverage      int
+	Baseline         string
+	Template         string
+	Dedupe           bool
+	IncludeStacks    bool
+	ArnsFromOutputs  bool
+	IncludeProducts  bool
+	TagPolicy        string
+	Schemes          []string
+	Rego             string
+	TagSchema        string
+	TagLimits        bool
+	SuggestTags      bool
+	Exemptions       string
+	NormalizeKeys    bool
+	CostAllocation   bool
+	AccountTags      bool
+	AccountTagKeys   []string
+	CreatedByTrail   bool
+	MonthlyCost      string
+	Drift            string
+	CURTable         string
+	AthenaOutput     string
+	S3URI            string
+	S3KMSKey         string
+	HistoryTable     string
+	EventBus         string
+	SecurityHub      bool
+	GlueTable        string
+	TagValues        string
+	MetricsFile      string
+	Pushgateway      string
+	SlackWebhook     string
+	WebhookURL       string
+	WebhookHeaders   []string
+	WebhookBatch     int
+	JiraURL          string
+	JiraProject      string
+	JiraIssueType    string
+	JiraOwnerKeys    []string
+	Watch            time.Duration
+	TUI              bool
+	PrintSummary     string
+	FailUnderModern  int
+	FailUnderClassic int
+
+	// QuickSight manifest and dataset over the uploaded reports
+	QuickSightManifest string
+	QuickSightDataSet  string
+	QuickSightOwner    string
+
+	// Service Catalog selection, used instead of Search when set
+	ProvisionedProduct string
+	Product            string
+	ProductVersion     string
+
+	// flushed is set when -flush-every or -flush-interval is given, on the command line or
+	// as a default, rather than left to its default
+	flushed bool
+}
+
+// formats are the supported report formats
+var formats = []string{"csv", "json", "jsonl", "xlsx", "html", "markdown", "parquet", "sqlite", "junit", "sarif", "tageditor", "summary", "missing-tags", "census", "values", "migration", "propagation", "backstage", "servicenow"}
+
+// listFlag collects comma separated values, the flag may also be repeated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintln(w, "usage: aws-tag-report [options] searchString... > reportFile"+
+			"\n       aws-tag-report [options] -provisioned-product|-product|-product-version id > reportFile"+
+			"\n       aws-tag-report serve [-listen addr] [-cache-ttl duration] [-- options]"+
+			"\n       aws-tag-report diff [-format csv|json] oldReport newReport"+
+			"\n       aws-tag-report trend [-history-table name | -dir path] [-format csv|html] [-days n]"+
+			"\n       aws-tag-report history list|prune [-history-table name | -s3-uri uri] [-keep 90d] [-dry-run]"+
+			"\n       aws-tag-report unallocated -cur-table database.table [-athena-output s3-uri] [-days n] [-keys keys]"+
+			"\n       aws-tag-report apply [-dry-run] [-rate n] [-log file] [-emit-script file] remediationFile"+
+			"\n       aws-tag-report propagate [-dry-run] [-rate n] [-log file] [-emit-script file] [-- options] searchString"+
+			"\n       aws-tag-report fix [-dry-run] [-rate n] [-log file] [-emit-script file] [-- options] searchString"+
+			"\n       aws-tag-report untag [-keys keys] [-dry-run] [-rate n] [-log file] [-emit-script file] [-- options] searchString"+
+			"\n       aws-tag-report groups [-dry-run] [-rate n] [-log file] [-emit-script file] [-- options] searchString"+
+			"\n       aws-tag-report list-supported [-format table|json]"+
+			"\n       aws-tag-report tag-options [-portfolio id] [-tag-policy file] [-format table|json]"+
+			"\n\tsearchString: will select any cloudformation stack with searchString within its name, several"+
+			"\n\t              searchStrings listing their stacks concurrently, each the search of the rows of its stacks"+
+			"\n\treportFile: file to redirect  csv output"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+}
+
+// parseOptions reads the options from the command line arguments (without the program name)
+func parseOptions(w io.Writer, args []string) (*Options, error) {
+	var options Options
+	fs := flag.NewFlagSet("aws-tag-report", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = usage(w, fs)
+	fs.Var((*listFlag)(&options.IncludeTypes), "include-types",
+		"comma separated glob patterns of resource types to report, e.g. \"AWS::EC2::*\"")
+	fs.Var((*listFlag)(&options.ExcludeTypes), "exclude-types",
+		"comma separated glob patterns of resource types to skip, applied after -include-types")
+	fs.StringVar(&options.Partition, "partition", "",
+		"aws partition used to build resource ARNs (aws, aws-cn, aws-us-gov), resolved from the region by default")
+	fs.Var((*serviceRegionFlag)(&options.ServiceRegions), "service-region",
+		"region or endpoint of the api of a service as service=region or service=https://endpoint, the service named as\n"+
+			"by list-supported, e.g. globalaccelerator=us-west-2; the global services target their region by default")
+	fs.StringVar(&options.FallbackRoleArn, "fallback-role-arn", "",
+		"role assumed to list the resources of the stacks the caller is denied, the stacks still denied being reported\n"+
+			"as SKIPPED_PERMISSION rather than stopping the scan")
+	fs.Var((*errorActionFlag)(&options.ErrorActions), "error-action",
+		"action on the lookups failing with an error code as code=skip, code=warn or code=fail, repeated for each code;\n"+
+			"skip leaves the resource out of the report, warn reports it as an error and fail stops the scan; the NotFound\n"+
+			"and AccessDenied codes warn by default, the others fail")
+	fs.StringVar(&options.Format, "format", "csv",
+		"report format, one of "+strings.Join(formats, ", ")+"; several comma separated formats are written together\n"+
+			"to the -output files named by its {format} placeholder or else by the format as extension")
+	fs.StringVar(&options.TagPolicy, "tag-policy", "",
+		"yaml or json file defining the required tag schemes, or ssm:<parameter name> or "+
+			"appconfig:<application>/<environment>/<profile> to fetch it at runtime, defaults to the embedded policy.yaml")
+	fs.Var((*listFlag)(&options.Schemes), "schemes",
+		"tag policy schemes to report the coverage of, defaults to every scheme")
+	fs.StringVar(&options.Rego, "rego", "",
+		"rego module whose data.tagreport.deny rules are evaluated against the tags of each resource, reported as policy violations")
+	fs.StringVar(&options.TagSchema, "tag-schema", "",
+		"JSON Schema file the tags of each resource, as a json object, are validated against, reported as policy violations")
+	fs.BoolVar(&options.TagLimits, "tag-limits", false,
+		fmt.Sprintf("report as policy violations the resources with %d tags or more, close to the limit of %d, those the\n"+
+			"missing keys would take over it, and the keys over %d or values over %d characters long, which tagging fails on",
+			nearTagLimit, maxTags, maxTagKeyLength, maxTagValueLength))
+	fs.BoolVar(&options.SuggestTags, "suggest-tags", false,
+		"add to the csv report a Suggested Tags column, the json object of the values suggested for the keys each resource\n"+
+			"misses from its stack and the other resources of the stack, which the apply subcommand reads; the rows are\n"+
+			"held until the scan is complete")
+	fs.StringVar(&options.Exemptions, "exemptions", "",
+		"yaml file of the stacks and resources exempt from the tag policy, with a reason and expiry date")
+	fs.BoolVar(&options.NormalizeKeys, "normalize-keys", false,
+		"match the required tag keys regardless of case, reporting the keys differing in case as case mismatches")
+	fs.BoolVar(&options.CostAllocation, "cost-allocation", false,
+		"add to the missing-tags and census reports whether each tag key is activated for cost allocation in billing")
+	fs.BoolVar(&options.AccountTags, "account-tags", false,
+		"read the Organizations tags of the account, from the management account or a delegated administrator, suggesting\n"+
+			"their values for the keys the resources miss and reporting as policy violations the -account-tag-keys values\n"+
+			"of the resources differing from the account's")
+	fs.Var((*listFlag)(&options.AccountTagKeys), "account-tag-keys",
+		"tag keys the resources must share the value of with their account, defaults to rlg:business-unit,rlg:environment")
+	fs.BoolVar(&options.CreatedByTrail, "created-by-cloudtrail", false,
+		"report as Created By the ARN of the principal that created each stack, from its CloudTrail CreateStack event,\n"+
+			"keeping SERVICE_CATALOG, PIPELINE or CUSTOM for the stacks created more than 90 days ago")
+	fs.StringVar(&options.MonthlyCost, "monthly-cost", "",
+		"add the cost of each resource over the last 30 days to the csv, json and jsonl reports, from ce, the resource level\n"+
+			"data of the cost explorer, which must be opted in to and covers 14 days scaled to 30, or cur, the -cur-table")
+	fs.StringVar(&options.Drift, "drift", "",
+		"add the drift status of each resource to the csv, json and jsonl reports, IN_SYNC, MODIFIED, DELETED or\n"+
+			"NOT_CHECKED, as of the last drift detection of its stack with last, or of a drift detection run first with detect")
+	fs.StringVar(&options.CURTable, "cur-table", "",
+		"database.table of the Cost and Usage Report in the Glue Data Catalog, queried with Athena")
+	fs.StringVar(&options.AthenaOutput, "athena-output", "",
+		"s3://bucket/prefix/ Athena writes the query results to, defaults to the output location of the primary workgroup")
+	fs.Var((*listFlag)(&options.ValueKeys), "value-keys",
+		"tag keys whose values the values report inventories, defaults to the modern keys")
+	fs.StringVar(&options.Output, "output", "",
+		"write the report to this file instead of stdout, gzip compressed when ending with .gz;\n"+
+			"{account}, {region} and {date} are replaced, e.g. report-{account}-{region}-{date}.csv")
+	fs.StringVar(&options.Output, "o", "", "shorthand for -output")
+	fs.StringVar(&options.S3URI, "s3-uri", "",
+		"also upload the report files to s3://bucket/prefix/, partitioned as date=YYYY-MM-DD/account=ID/region=NAME/")
+	fs.StringVar(&options.S3KMSKey, "s3-kms-key", "",
+		"KMS key id or ARN the uploaded reports are encrypted with (SSE-KMS)")
+	fs.StringVar(&options.GlueTable, "glue-table", "",
+		"create or update this database.table of the Glue Data Catalog over the csv or parquet reports uploaded\n"+
+			"by -s3-uri, adding the partition of the run, so that Athena queries them")
+	fs.StringVar(&options.QuickSightManifest, "quicksight-manifest", "",
+		"write the QuickSight manifest of the csv reports uploaded by -s3-uri to this file or s3://bucket/key")
+	fs.StringVar(&options.QuickSightDataSet, "quicksight-dataset", "",
+		"also create or update, and so refresh, the QuickSight SPICE dataset of this id over the s3 -quicksight-manifest")
+	fs.StringVar(&options.QuickSightOwner, "quicksight-owner", "",
+		"ARN of the QuickSight user or group granted the dataset and its data source when created")
+	fs.StringVar(&options.HistoryTable, "history-table", "",
+		"also record the compliance of every resource in this DynamoDB table, whose keys are the runId partition key\n"+
+			"and the arn sort key, to follow the coverage over time")
+	fs.StringVar(&options.EventBus, "event-bus", "",
+		"also put a TagComplianceFinding event per noncompliant resource onto this EventBridge bus name or ARN")
+	fs.BoolVar(&options.SecurityHub, "security-hub", false,
+		"also import the tag compliance of each resource into Security Hub as findings, in the account and region scanned")
+	fs.IntVar(&options.RotateRows, "rotate-rows", 0,
+		"split csv and jsonl reports into numbered files of this many rows, requires -output")
+	fs.StringVar(&options.BadgesDir, "badges-dir", "",
+		"also write to this directory the svg badges of the modern and classic coverage of each -badges-by group and of\n"+
+			"every resource, as <group>-modern.svg, along with their shields.io endpoint json, as <group>-modern.json")
+	fs.StringVar(&options.BadgesBy, "badges-by", "environment",
+		"group the badges by environment, stack or the value of a tag as tag:<key>, e.g. tag:rlg:product")
+	fs.IntVar(&options.FlushEvery, "flush-every", 1000,
+		"write the rows buffered by the report every this many resources, 0 to only write them at the end")
+	fs.DurationVar(&options.FlushInterval, "flush-interval", 0,
+		"also write the rows buffered by the report at this interval, e.g. 30s")
+	fs.IntVar(&options.LookupWorkers, "lookup-concurrency", 4,
+		"number of tag lookups run at once, taking turns between the services, 1 to look the resources up one at a time")
+	fs.IntVar(&options.ServiceWorkers, "service-concurrency", 2,
+		"number of tag lookups of a same service run at once, a throttled service being paused before its lookups are retried")
+	fs.IntVar(&options.SearchWorkers, "search-concurrency", 4,
+		"number of searches whose stacks are listed at once when several search strings are given")
+	fs.StringVar(&options.PprofAddr, "pprof-addr", "",
+		"serve the pprof endpoints on this address during the scan, e.g. localhost:6060")
+	fs.StringVar(&options.CPUProfile, "cpuprofile", "", "write the cpu profile of the scan to this file")
+	fs.StringVar(&options.MemProfile, "memprofile", "", "write the memory profile at the end of the scan to this file")
+	fs.StringVar(&options.SplitBy, "split-by", "",
+		"write a report file per search or stack, named by the {search} or {stack} placeholder of -output")
+	fs.StringVar(&options.RouteBy, "route-by", "",
+		"write a report file per owner, the value of a tag as tag:<key>, e.g. tag:rlg:contact, named by the {owner}\n"+
+			"placeholder of -output, the resources without the tag being unowned")
+	fs.StringVar(&options.RouteTo, "route-to", "file",
+		"deliver the report file of each -route-by owner: file only writes it, email sends it to the owner through\n"+
+			"SES from -route-from, slack shares it in the Slack direct messages of the owner, a user id or email address,\n"+
+			"with the SLACK_BOT_TOKEN bot token")
+	fs.StringVar(&options.RouteFrom, "route-from", "",
+		"sender address of the -route-to email messages, verified in SES")
+	delimiter := fs.String("csv-delimiter", ",",
+		"csv field delimiter, a single character or \"tab\"")
+	fs.BoolVar(&options.CSVDialect.BOM, "csv-bom", false,
+		"start csv output with a UTF-8 byte order mark")
+	fs.BoolVar(&options.CSVDialect.CRLF, "csv-crlf", false,
+		"end csv lines with \\r\\n")
+	fs.StringVar(&options.ParquetDir, "parquet-dir", "",
+		"write the parquet report below this directory, partitioned by account=/region=/date=")
+	fs.StringVar(&options.SQLiteFile, "sqlite-file", "aws-tag-report.db",
+		"sqlite database the sqlite report appends the run to")
+	fs.StringVar(&options.SummaryFile, "summary-file", "",
+		"also write the per stack and per resource type rollup to this csv file, with the -output placeholders")
+	fs.StringVar(&options.MissingTagsFile, "missing-tags-file", "",
+		"also write how many resources miss each required tag to this csv file, with the -output placeholders")
+	fs.StringVar(&options.ConfigCheckFile, "config-check-file", "",
+		"also write to this csv file the resources on which the AWS Config required-tags rules disagree with the report, "+
+			"warning about the required tags no rule checks, with the -output placeholders")
+	fs.StringVar(&options.MetricsFile, "metrics-file", "",
+		"also write prometheus metrics to this file, in the node exporter textfile collector format")
+	fs.StringVar(&options.Pushgateway, "pushgateway", "",
+		"also push prometheus metrics to this Pushgateway url")
+	fs.DurationVar(&options.Watch, "watch", 0,
+		"scan again at this interval, e.g. 15m, the scans after the first only reporting the resources that changed")
+	fs.BoolVar(&options.TUI, "tui", false,
+		"show the progress and the coverage of each stack in the terminal, then browse the results, requires -output")
+	fs.StringVar(&options.PrintSummary, "print-summary", "auto",
+		"print a table of the coverage of each stack on stderr once scanned, colored in a terminal unless NO_COLOR is set:\n"+
+			"auto when stderr is a terminal and without -tui, always or never")
+	fs.IntVar(&options.FailUnderModern, "fail-under-modern", 0,
+		"exit with status 1 when the average modern coverage of the resources is below this percentage")
+	fs.IntVar(&options.FailUnderClassic, "fail-under-classic", 0,
+		"exit with status 1 when the average classic coverage of the resources is below this percentage")
+	fs.StringVar(&options.SlackWebhook, "slack-webhook", "",
+		"also post a summary to this Slack incoming webhook url: the top offending stacks, the biggest coverage drops\n"+
+			"since the -baseline and a link to the reports uploaded by -s3-uri")
+	fs.StringVar(&options.WebhookURL, "webhook-url", "",
+		"also post the resources, as a json array of the records of the json report, to this https url")
+	fs.Var((*headerFlag)(&options.WebhookHeaders), "webhook-header",
+		"header of the -webhook-url requests as \"Name: value\", e.g. \"Authorization: Bearer ${TOKEN}\" expanding the\n"+
+			"TOKEN environment variable, repeat the flag for each header")
+	fs.IntVar(&options.WebhookBatch, "webhook-batch", 0,
+		"post the resources to -webhook-url by batches of this many as they are scanned, instead of all at once")
+	fs.StringVar(&options.JiraURL, "jira-url", "",
+		"also keep a Jira issue open per owner listing its noncompliant resources, closing it once they are compliant,\n"+
+			"at this Jira url with the JIRA_USER and JIRA_API_TOKEN environment variables as credentials")
+	fs.StringVar(&options.JiraProject, "jira-project", "",
+		"key of the project of the -jira-url issues")
+	fs.StringVar(&options.JiraIssueType, "jira-issue-type", "Task",
+		"type of the -jira-url issues")
+	fs.Var((*listFlag)(&options.JiraOwnerKeys), "jira-owner-keys",
+		"tag keys naming the owner of a resource, the first one carried being used, defaults to rlg:techdata-team,rlg:contact")
+	fs.StringVar(&options.TagValues, "include-tag-values", "",
+		"add the complete tags of each resource to the csv report, as a json column or as a column per tag key: json or columns")
+	fs.BoolVar(&options.Sort, "sort", true,
+		"sort the resources of the csv, json, xlsx and parquet reports by stack, type and name so an unchanged account\n"+
+			"gives identical reports, unless -flush-every or -flush-interval is given; the streamed formats and the\n"+
+			"other outputs, as -webhook-url, get the resources as they are scanned, as -sort=false writes them all")
+	fs.BoolVar(&options.OnlyNoncompliant, "only-noncompliant", false,
+		"only report the resources missing modern tags, the summaries and metrics still cover every resource")
+	minCoverage := fs.String("min-coverage", "",
+		"with -only-noncompliant, only report the resources whose modern coverage is below this percentage, e.g. 80%")
+	fs.StringVar(&options.Baseline, "baseline", "",
+		"previous csv, json or jsonl report to mark each resource NEW, IMPROVED, REGRESSED or UNCHANGED against")
+	fs.BoolVar(&options.Dedupe, "dedupe", false,
+		"report the resources shared by several stacks once, along with every stack they are part of")
+	fs.BoolVar(&options.IncludeStacks, "include-stacks", false,
+		"also report each stack as an AWS::CloudFormation::Stack resource, its own tags driving their propagation\n"+
+			"to its resources and cost allocation")
+	fs.BoolVar(&options.IncludeProducts, "include-products", false,
+		"also report the Service Catalog provisioned products scanned and their products as resources of their own,\n"+
+			"the products along with the values of their TagOptions")
+	fs.BoolVar(&options.ArnsFromOutputs, "arns-from-outputs", false,
+		"read the ARNs of the resources from the outputs and exports of their stacks, an output key of the logical id\n"+
+			"followed by Arn, e.g. QueueArn, taking over the ARN built from the physical id")
+	fs.StringVar(&options.Template, "template", "",
+		"render the report through this go text/template instead of the format")
+	fs.StringVar(&options.GroupBy, "group-by", "",
+		"report the coverage rolled up by stack, type or the value of a tag as tag:<key>, e.g. tag:rlg:business-unit")
+	fs.BoolVar(&options.GroupByConstruct, "group-by-construct", false,
+		"group the report rows by their CDK construct path")
+	fs.StringVar(&options.ProvisionedProduct, "provisioned-product", "",
+		"scan the service catalog provisioned product with this name or id")
+	fs.StringVar(&options.Product, "product", "",
+		"scan every provisioned product launched from this service catalog product id")
+	fs.StringVar(&options.ProductVersion, "product-version", "",
+		"scan every provisioned product launched from this product version (provisioning artifact id)")
+	config := fs.String("config", "",
+		"yaml file of the option defaults instead of ~/.aws-tag-report.yaml and ./aws-tag-report.yaml, mapping the\n"+
+			"option names to their value, e.g. format: json; the AWS_TAG_REPORT_<OPTION> environment variables override\n"+
+			"the files, e.g. AWS_TAG_REPORT_FORMAT, and the command line overrides both; AWS_TAG_REPORT_CONFIG names the file too")
+
+	err := fs.Parse(args)
+	if err == nil {
+		err = applyDefaults(fs, *config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "flush-every" || f.Name == "flush-interval" {
+			options.flushed = true
+		}
+	})
+	if fs.NArg() > 0 && options.scansProducts() {
+		return nil, fmt.Errorf("a searchString and -provisioned-product, -product or -product-version are mutually exclusive")
+	} else if fs.NArg() > 0 {
+		// several searches stand together for the search of the titles and the history
+		options.Searches = fs.Args()
+		options.Search = strings.Join(options.Searches, ",")
+	} else if options.scansProducts() {
+		// the selected id stands in for the search term in the report
+		for _, id := range []string{options.ProvisionedProduct, options.Product, options.ProductVersion} {
+			if id != "" {
+				options.Search = id
+				break
+			}
+		}
+	} else {
+		fs.Usage()
+		return nil, flag.ErrHelp
+	}
+
+	if options.CSVDialect.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		return nil, err
+	}
+	options.MinCoverage = 100
+	if *minCoverage != "" && !options.OnlyNoncompliant {
+		return nil, fmt.Errorf("-min-coverage requires -only-noncompliant")
+	} else if *minCoverage != "" {
+		if options.MinCoverage, err = parsePercentage(*minCoverage); err != nil {
+			return nil, err
+		}
+	}
+	options.Formats = strings.Split(options.Format, ",")
+	options.Format = options.Formats[0]
+	for i, format := range options.Formats {
+		if !containsString(formats, format) {
+			return nil, fmt.Errorf("unknown report format %q, expected one of %s", format, strings.Join(formats, ", "))
+		} else if containsString(options.Formats[:i], format) {
+			return nil, fmt.Errorf("report format %q is listed twice", format)
+		}
+	}
+	if len(options.Formats) > 1 && options.Output == "" {
+		return nil, fmt.Errorf("several report formats require -output, a file being written per format")
+	} else if len(options.Formats) > 1 && (containsString(options.Formats, "sqlite") || options.ParquetDir != "") {
+		return nil, fmt.Errorf("the sqlite format and -parquet-dir are written alone, not with other formats")
+	} else if len(options.Formats) > 1 && (options.GroupBy != "" || options.Template != "") {
+		return nil, fmt.Errorf("-group-by and -template do not apply to several report formats")
+	} else if len(options.Formats) > 1 && (options.GlueTable != "" || options.QuickSightManifest != "") {
+		return nil, fmt.Errorf("-glue-table and -quicksight-manifest require the report to be the only file uploaded, " +
+			"Athena and QuickSight reading every file below -s3-uri")
+	}
+	if options.RotateRows < 0 {
+		return nil, fmt.Errorf("invalid -rotate-rows %d", options.RotateRows)
+	} else if options.RotateRows > 0 && options.Output == "" {
+		return nil, fmt.Errorf("-rotate-rows requires -output")
+	} else if options.RotateRows > 0 && !onlyFormats(options.Formats, "csv", "jsonl") {
+		return nil, fmt.Errorf("-rotate-rows is only supported by the csv and jsonl formats")
+	}
+	if options.BadgesBy != "environment" && options.BadgesBy != "stack" && routeKey(options.BadgesBy) == "" {
+		return nil, fmt.Errorf("invalid -badges-by %q, expected environment, stack or tag:<key>", options.BadgesBy)
+	}
+	if options.FlushEvery < 0 {
+		return nil, fmt.Errorf("invalid -flush-every %d", options.FlushEvery)
+	} else if options.FlushInterval < 0 {
+		return nil, fmt.Errorf("invalid -flush-interval %s, expected a positive interval", options.FlushInterval)
+	}
+	if options.LookupWorkers < 1 {
+		return nil, fmt.Errorf("invalid -lookup-concurrency %d, expected at least 1", options.LookupWorkers)
+	} else if options.ServiceWorkers < 1 {
+		return nil, fmt.Errorf("invalid -service-concurrency %d, expected at least 1", options.ServiceWorkers)
+	} else if options.SearchWorkers < 1 {
+		return nil, fmt.Errorf("invalid -search-concurrency %d, expected at least 1", options.SearchWorkers)
+	}
+	if options.SplitBy != "" && options.SplitBy != "search" && options.SplitBy != "stack" {
+		return nil, fmt.Errorf("invalid -split-by %q, expected search or stack", options.SplitBy)
+	} else if options.SplitBy != "" && options.Output == "" {
+		return nil, fmt.Errorf("-split-by requires -output")
+	} else if options.SplitBy != "" && (options.Format == "sqlite" || options.ParquetDir != "") {
+		return nil, fmt.Errorf("-split-by does not apply to the sqlite format or -parquet-dir")
+	}
+	if options.RouteBy != "" && routeKey(options.RouteBy) == "" {
+		return nil, fmt.Errorf("invalid -route-by %q, expected tag:<key>", options.RouteBy)
+	} else if options.RouteBy != "" && options.Output == "" {
+		return nil, fmt.Errorf("-route-by requires -output")
+	} else if options.RouteBy != "" && options.SplitBy != "" {
+		return nil, fmt.Errorf("-route-by and -split-by are mutually exclusive")
+	} else if options.RouteBy != "" && (options.Format == "sqlite" || options.ParquetDir != "") {
+		return nil, fmt.Errorf("-route-by does not apply to the sqlite format or -parquet-dir")
+	}
+	if options.RouteTo != "file" && options.RouteTo != "email" && options.RouteTo != "slack" {
+		return nil, fmt.Errorf("invalid -route-to %q, expected file, email or slack", options.RouteTo)
+	} else if options.RouteTo != "file" && options.RouteBy == "" {
+		return nil, fmt.Errorf("-route-to requires -route-by")
+	} else if options.RouteTo == "email" && options.RouteFrom == "" {
+		return nil, fmt.Errorf("-route-to email requires -route-from")
+	} else if options.RouteTo != "email" && options.RouteFrom != "" {
+		return nil, fmt.Errorf("-route-from only applies to -route-to email")
+	} else if options.RouteTo == "slack" && os.Getenv("SLACK_BOT_TOKEN") == "" {
+		return nil, fmt.Errorf("-route-to slack requires the SLACK_BOT_TOKEN environment variable")
+	}
+	if options.S3URI != "" && options.Output == "" {
+		return nil, fmt.Errorf("-s3-uri requires -output, the files written being uploaded")
+	} else if options.S3URI != "" && (options.Format == "sqlite" || options.ParquetDir != "") {
+		return nil, fmt.Errorf("-s3-uri does not apply to the sqlite format or -parquet-dir")
+	} else if options.S3URI != "" {
+		if _, _, err := parseS3URI(options.S3URI); err != nil {
+			return nil, err
+		}
+	} else if options.S3KMSKey != "" {
+		return nil, fmt.Errorf("-s3-kms-key requires -s3-uri")
+	}
+	if options.Watch < 0 {
+		return nil, fmt.Errorf("invalid -watch %s, expected a positive interval", options.Watch)
+	}
+	if options.WebhookURL != "" {
+		if err := parseWebhookURL(options.WebhookURL); err != nil {
+			return nil, err
+		}
+	} else if len(options.WebhookHeaders) > 0 || options.WebhookBatch != 0 {
+		return nil, fmt.Errorf("-webhook-header and -webhook-batch require -webhook-url")
+	}
+	if options.WebhookBatch < 0 {
+		return nil, fmt.Errorf("invalid -webhook-batch %d", options.WebhookBatch)
+	}
+	if options.JiraURL != "" && options.JiraProject == "" {
+		return nil, fmt.Errorf("-jira-url requires -jira-project")
+	} else if options.JiraURL != "" && (os.Getenv("JIRA_USER") == "" || os.Getenv("JIRA_API_TOKEN") == "") {
+		return nil, fmt.Errorf("-jira-url requires the JIRA_USER and JIRA_API_TOKEN environment variables")
+	} else if options.JiraURL == "" && (options.JiraProject != "" || len(options.JiraOwnerKeys) > 0) {
+		return nil, fmt.Errorf("-jira-project and -jira-owner-keys require -jira-url")
+	}
+	if len(options.AccountTagKeys) > 0 && !options.AccountTags {
+		return nil, fmt.Errorf("-account-tag-keys requires -account-tags")
+	} else if len(options.AccountTagKeys) == 0 {
+		options.AccountTagKeys = []string{"rlg:business-unit", "rlg:environment"}
+	}
+	if len(options.JiraOwnerKeys) == 0 {
+		options.JiraOwnerKeys = []string{"rlg:techdata-team", "rlg:contact"}
+	}
+	if options.PrintSummary != "auto" && options.PrintSummary != "always" && options.PrintSummary != "never" {
+		return nil, fmt.Errorf("invalid -print-summary %q, expected auto, always or never", options.PrintSummary)
+	}
+	if options.FailUnderModern < 0 || options.FailUnderModern > 100 {
+		return nil, fmt.Errorf("invalid -fail-under-modern %d, expected a percentage", options.FailUnderModern)
+	} else if options.FailUnderClassic < 0 || options.FailUnderClassic > 100 {
+		return nil, fmt.Errorf("invalid -fail-under-classic %d, expected a percentage", options.FailUnderClassic)
+	} else if (options.FailUnderModern > 0 || options.FailUnderClassic > 0) && options.Watch > 0 {
+		return nil, fmt.Errorf("-fail-under-modern and -fail-under-classic do not apply to -watch")
+	}
+	if options.TUI && options.Output == "" && options.Format != "sqlite" && options.ParquetDir == "" {
+		return nil, fmt.Errorf("-tui requires -output, the terminal showing the results")
+	} else if options.TUI && options.Watch > 0 {
+		return nil, fmt.Errorf("-tui does not apply to -watch")
+	}
+	if options.GlueTable != "" && options.S3URI == "" {
+		return nil, fmt.Errorf("-glue-table requires -s3-uri")
+	} else if database, _ := splitGlueTable(options.GlueTable); options.GlueTable != "" && database == "" {
+		return nil, fmt.Errorf("invalid -glue-table %q, expected database.table", options.GlueTable)
+	} else if options.GlueTable != "" && ((options.Format != "csv" && options.Format != "parquet") ||
+		options.GroupBy != "" || options.Template != "" || options.TagValues == "columns") {
+		return nil, fmt.Errorf("-glue-table only applies to the csv and parquet formats, without -group-by, -template or -include-tag-values columns")
+	}
+	if options.QuickSightManifest != "" && options.S3URI == "" {
+		return nil, fmt.Errorf("-quicksight-manifest requires -s3-uri")
+	} else if options.QuickSightManifest != "" && (options.Format != "csv" ||
+		options.GroupBy != "" || options.Template != "" || options.TagValues == "columns") {
+		return nil, fmt.Errorf("-quicksight-manifest only applies to the csv format, without -group-by, -template or -include-tag-values columns")
+	} else if strings.HasPrefix(options.QuickSightManifest, "s3://") {
+		bucket, key, err := parseS3URI(options.QuickSightManifest)
+		root, prefix, _ := parseS3URI(options.S3URI)
+		if err != nil || key == "" || strings.HasSuffix(key, "/") {
+			return nil, fmt.Errorf("invalid -quicksight-manifest %q, expected s3://bucket/key", options.QuickSightManifest)
+		} else if bucket == root && (prefix == "" || strings.HasPrefix(key+"/", strings.TrimSuffix(prefix, "/")+"/")) {
+			return nil, fmt.Errorf("-quicksight-manifest must be outside of -s3-uri, QuickSight importing every file below it")
+		}
+	} else if options.QuickSightDataSet != "" {
+		return nil, fmt.Errorf("-quicksight-dataset requires an s3://bucket/key -quicksight-manifest")
+	}
+	if options.QuickSightOwner != "" && options.QuickSightDataSet == "" {
+		return nil, fmt.Errorf("-quicksight-owner requires -quicksight-dataset")
+	}
+	if (options.GlueTable != "" || options.QuickSightManifest != "") &&
+		(options.SummaryFile != "" || options.MissingTagsFile != "" || options.ConfigCheckFile != "") {
+		return nil, fmt.Errorf("-glue-table and -quicksight-manifest require the report to be the only file uploaded, " +
+			"Athena and QuickSight reading every file below -s3-uri")
+	}
+	if options.MonthlyCost != "" && options.MonthlyCost != "ce" && options.MonthlyCost != "cur" {
+		return nil, fmt.Errorf("invalid -monthly-cost %q, expected ce or cur", options.MonthlyCost)
+	} else if options.MonthlyCost != "" && (!onlyFormats(options.Formats, "csv", "json", "jsonl") ||
+		options.GroupBy != "" || options.Template != "") {
+		return nil, fmt.Errorf("-monthly-cost only applies to the csv, json and jsonl formats, without -group-by or -template")
+	} else if options.MonthlyCost == "cur" && options.CURTable == "" {
+		return nil, fmt.Errorf("-monthly-cost cur requires -cur-table")
+	} else if options.CURTable != "" && options.MonthlyCost != "cur" {
+		return nil, fmt.Errorf("-cur-table requires -monthly-cost cur")
+	} else if database, _ := splitGlueTable(options.CURTable); options.CURTable != "" && database == "" {
+		return nil, fmt.Errorf("invalid -cur-table %q, expected database.table", options.CURTable)
+	}
+	if options.Drift != "" && options.Drift != "last" && options.Drift != "detect" {
+		return nil, fmt.Errorf("invalid -drift %q, expected last or detect", options.Drift)
+	} else if options.Drift != "" && (!onlyFormats(options.Formats, "csv", "json", "jsonl") ||
+		options.GroupBy != "" || options.Template != "") {
+		return nil, fmt.Errorf("-drift only applies to the csv, json and jsonl formats, without -group-by or -template")
+	}
+	if options.AthenaOutput != "" && options.CURTable == "" {
+		return nil, fmt.Errorf("-athena-output requires -cur-table")
+	} else if options.AthenaOutput != "" {
+		if _, _, err := parseS3URI(options.AthenaOutput); err != nil {
+			return nil, err
+		}
+	}
+	if options.TagValues != "" && options.TagValues != "json" && options.TagValues != "columns" {
+		return nil, fmt.Errorf("invalid -include-tag-values %q, expected json or columns", options.TagValues)
+	} else if options.TagValues != "" && !onlyFormats(options.Formats, "csv") {
+		return nil, fmt.Errorf("-include-tag-values only applies to the csv format, the json, jsonl, parquet and sqlite formats always carry the tag values")
+	}
+	if options.SuggestTags && (!onlyFormats(options.Formats, "csv") || options.GroupBy != "" || options.Template != "") {
+		return nil, fmt.Errorf("-suggest-tags only applies to the csv format, without -group-by or -template")
+	}
+	if options.GroupBy != "" && options.GroupBy != "stack" && options.GroupBy != "type" &&
+		(!strings.HasPrefix(options.GroupBy, "tag:") || options.GroupBy == "tag:") {
+		return nil, fmt.Errorf("invalid -group-by %q, expected stack, type or tag:<key>", options.GroupBy)
+	} else if options.GroupBy != "" && options.Format != "csv" {
+		return nil, fmt.Errorf("-group-by only applies to the csv format")
+	} else if options.GroupBy != "" && options.Template != "" {
+		return nil, fmt.Errorf("-group-by and -template are mutually exclusive")
+	}
+	for _, pattern := range append(options.IncludeTypes, options.ExcludeTypes...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid resource type pattern %q: %v", pattern, err)
+		}
+	}
+	return &options, nil
+}
+
+// onlyFormats tells whether every format is one of the allowed formats
+func onlyFormats(formats []string, allowed ...string) bool {
+	for _, format := range formats {
+		if !containsString(allowed, format) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePercentage parses a percentage between 0 and 100, the percent sign being optional
+func parsePercentage(percentage string) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSuffix(percentage, "%"))
+	if err != nil || value < 0 || value > 100 {
+		return 0, fmt.Errorf("invalid percentage %q, expected a number between 0 and 100", percentage)
+	}
+	return value, nil
+}
+
+// parseDelimiter validates a csv delimiter, "tab" standing for the tab character
+func parseDelimiter(delimiter string) (rune, error) {
+	if delimiter == "tab" || delimiter == "\\t" {
+		return '\t', nil
+	}
+	runes := []rune(delimiter)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' || runes[0] == utf8.RuneError {
+		return 0, fmt.Errorf("invalid csv delimiter %q", delimiter)
+	}
+	return runes[0], nil
+}
+
+// matchesType reports whether resourceType is selected by the include/exclude patterns,
+// an empty include list selects every type
+func (o *Options) matchesType(resourceType string) bool {
+	included := len(o.IncludeTypes) == 0
+	for _, pattern := range o.IncludeTypes {
+		if ok, _ := path.Match(pattern, resourceType); ok {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range o.ExcludeTypes {
+		if ok, _ := path.Match(pattern, resourceType); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// scansProducts reports whether the service catalog selection replaces the stack search
+func (o *Options) scansProducts() bool {
+	return o.ProvisionedProduct != "" || o.Product != "" || o.ProductVersion != ""
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output is the destination of a report: stdout, or a file which is gzip compressed when
+// its name ends with .gz. When RotateRows is set the rows are split into numbered parts,
+// report.csv.gz becoming report-001.csv.gz, report-002.csv.gz...
+// A file is written to a temporary file next to it, renamed once complete so it is never
+// read half written
+type Output struct {
+	Path       string
+	RotateRows int
+	// Upload is handed each file of the output once complete
+	Upload func(path string)
+
+	part        int
+	current     io.WriteCloser
+	currentPath string
+	// tempPath is the temporary file of the current part, empty when written in place
+	tempPath string
+}
+
+// Open starts the next part of the output, closing the previous one
+func (o *Output) Open() io.Writer {
+	if err := o.Close(); err != nil {
+		panic(err.Error())
+	}
+	o.part++
+	if o.Path == "" {
+		o.current, o.currentPath = nopCloser{os.Stdout}, ""
+		return o.current
+	}
+
+	path := o.Path
+	if o.RotateRows > 0 {
+		path = partPath(path, o.part)
+	}
+	o.tempPath = tempPath(path)
+	target := path
+	if o.tempPath != "" {
+		target = o.tempPath
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		panic(err.Error())
+	}
+	o.current, o.currentPath = f, path
+	if strings.HasSuffix(path, ".gz") {
+		o.current = &gzipFile{gzip.NewWriter(f), f}
+	}
+	return o.current
+}
+
+// Close completes the current part
+func (o *Output) Close() error {
+	if o.current == nil {
+		return nil
+	}
+	err := o.current.Close()
+	o.current = nil
+	if o.tempPath != "" {
+		if err == nil {
+			err = os.Rename(o.tempPath, o.currentPath)
+		} else {
+			os.Remove(o.tempPath)
+		}
+		o.tempPath = ""
+	}
+	if err == nil && o.Upload != nil && o.currentPath != "" {
+		o.Upload(o.currentPath)
+	}
+	return err
+}
+
+// tempPath names the temporary file a report file is written to, hidden next to it so the
+// rename stays on the same file system; devices such as /dev/null are written in place
+func tempPath(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		return ""
+	}
+	dir, name := filepath.Split(path)
+	return filepath.Join(dir, "."+name+".tmp")
+}
+
+// expandPath fills the {account}, {region} and {date} placeholders of an output path
+func expandPath(path string, account string, region string, now time.Time) string {
+	return strings.NewReplacer(
+		"{account}", sanitizeFileName(account),
+		"{region}", sanitizeFileName(region),
+		"{date}", now.Format("2006-01-02"),
+	).Replace(path)
+}
+
+// partPath numbers a file name ahead of its extension
+func partPath(path string, part int) string {
+	return insertSuffix(path, fmt.Sprintf("-%03d", part))
+}
+
+// splitPath names the file of a slice of a split report, replacing the {stack} or {search}
+// placeholder of the path or else appending the slice ahead of the extension
+func splitPath(path string, by string, key string) string {
+	placeholder := "{" + by + "}"
+	if strings.Contains(path, placeholder) {
+		return strings.Replace(path, placeholder, sanitizeFileName(key), -1)
+	}
+	return insertSuffix(path, "-"+sanitizeFileName(key))
+}
+
+// formatPath names the file of a format when writing several, replacing the {format}
+// placeholder of the path or else its extension, ahead of a .gz suffix
+func formatPath(path string, format string) string {
+	if strings.Contains(path, "{format}") {
+		return strings.Replace(path, "{format}", format, -1)
+	}
+	base, gz := path, ""
+	if strings.HasSuffix(base, ".gz") {
+		base, gz = strings.TrimSuffix(base, ".gz"), ".gz"
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "." + format + gz
+}
+
+// insertSuffix adds to a file name ahead of its extension, including a .gz suffix
+func insertSuffix(path string, suffix string) string {
+	base, gz := path, ""
+	if strings.HasSuffix(base, ".gz") {
+		base, gz = strings.TrimSuffix(base, ".gz"), ".gz"
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + suffix + ext + gz
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// gzipFile closes both the compressor and the underlying file
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.Writer.Close(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+)
+
+// arnOverrides are the ARNs of the resources by physical id read from the stack outputs
+// with -arns-from-outputs, the ARN resolvers using them before building an ARN
+var arnOverrides map[string]string
+
+// outputArnSuffixes end the output keys naming the ARN of a resource after its logical id,
+// e.g. QueueArn for the resource Queue
+var outputArnSuffixes = []string{"Arn", "ARN"}
+
+// outputArns reads the ARNs of the resources from the outputs of their stacks, by the
+// convention of an output key of the logical id followed by Arn; an export name ending with
+// such a key after a colon or a dash, as stack-QueueArn or stack:QueueArn, also counts for
+// the stacks exporting their outputs under another key
+func outputArns(resources []StackResource) map[string]string {
+	arns := make(map[string]string)
+	for _, resource := range resources {
+		if resource.Skipped != nil || resource.PhysicalResourceId == nil || resource.LogicalResourceId == nil {
+			continue
+		}
+		if arn := outputArn(resource.Stack, *resource.LogicalResourceId); arn != "" {
+			arns[*resource.PhysicalResourceId] = arn
+		}
+	}
+	return arns
+}
+
+// outputArn is the ARN of the resource of a logical id among the outputs of its stack, if any
+func outputArn(stack Stack, logicalId string) string {
+	for _, suffix := range outputArnSuffixes {
+		key := logicalId + suffix
+		if value := stack.Outputs[key]; strings.HasPrefix(value, "arn:") {
+			return value
+		}
+		for name, value := range stack.Exports {
+			if (strings.HasSuffix(name, "-"+key) || strings.HasSuffix(name, ":"+key)) && strings.HasPrefix(value, "arn:") {
+				return value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// A minimal parquet writer: a single row group of flat BYTE_ARRAY (utf8) and INT32 columns,
+// each stored as one PLAIN encoded, uncompressed data page.
+// https://github.com/apache/parquet-format
+
+const (
+	parquetInt32     = 1
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8 = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn holds the values of a column, nil values are only allowed in optional columns
+type parquetColumn struct {
+	name     string
+	kind     int
+	optional bool
+	values   []interface{}
+}
+
+func newStringColumn(name string) *parquetColumn {
+	return &parquetColumn{name: name, kind: parquetByteArray}
+}
+
+func newInt32Column(name string, optional bool) *parquetColumn {
+	return &parquetColumn{name: name, kind: parquetInt32, optional: optional}
+}
+
+// page encodes the column values as a data page, definition levels first for optional columns
+func (c *parquetColumn) page() []byte {
+	var page bytes.Buffer
+	if c.optional {
+		levels := make([]bool, len(c.values))
+		for i, v := range c.values {
+			levels[i] = v != nil
+		}
+		encoded := bitPackedRun(levels)
+		binary.Write(&page, binary.LittleEndian, uint32(len(encoded)))
+		page.Write(encoded)
+	}
+	for _, v := range c.values {
+		switch v := v.(type) {
+		case string:
+			binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+			page.WriteString(v)
+		case int:
+			binary.Write(&page, binary.LittleEndian, int32(v))
+		}
+	}
+	return page.Bytes()
+}
+
+// bitPackedRun encodes bit width 1 levels with the RLE/bit-packing hybrid as a single bit-packed run
+func bitPackedRun(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	var b bytes.Buffer
+	b.Write(appendUvarint(nil, uint64(groups<<1|1)))
+	packed := make([]byte, groups)
+	for i, set := range levels {
+		if set {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	b.Write(packed)
+	return b.Bytes()
+}
+
+// writeParquet writes the columns, all of the same length, as a parquet file
+func writeParquet(w io.Writer, columns []*parquetColumn) error {
+	rows := 0
+	if len(columns) > 0 {
+		rows = len(columns[0].values)
+	}
+
+	var file bytes.Buffer
+	file.Write(parquetMagic)
+
+	chunks := make([]thrift, len(columns))
+	total := 0
+	for i, column := range columns {
+		offset := file.Len()
+		data := column.page()
+		header := thrift{}.
+			i32(1, 0). // DATA_PAGE
+			i32(2, int32(len(data))).
+			i32(3, int32(len(data))).
+			structure(5, thrift{}.
+				i32(1, int32(len(column.values))).
+				i32(2, parquetPlain).
+				i32(3, parquetRLE).
+				i32(4, parquetRLE))
+		file.Write(header.bytes())
+		file.Write(data)
+		size := int64(file.Len() - offset)
+		total += int(size)
+
+		chunks[i] = thrift{}.
+			i64(2, int64(offset)).
+			structure(3, thrift{}.
+				i32(1, int32(column.kind)).
+				i32List(2, parquetPlain, parquetRLE).
+				stringList(3, column.name).
+				i32(4, 0). // UNCOMPRESSED
+				i64(5, int64(len(column.values))).
+				i64(6, size).
+				i64(7, size).
+				i64(9, int64(offset)))
+	}
+
+	schema := []thrift{thrift{}.str(4, "schema").i32(5, int32(len(columns)))}
+	for _, column := range columns {
+		repetition := int32(parquetRequired)
+		if column.optional {
+			repetition = parquetOptional
+		}
+		element := thrift{}.i32(1, int32(column.kind)).i32(3, repetition).str(4, column.name)
+		if column.kind == parquetByteArray {
+			element = element.i32(6, parquetUTF8)
+		}
+		schema = append(schema, element)
+	}
+
+	metadata := thrift{}.
+		i32(1, 1).
+		structList(2, schema).
+		i64(3, int64(rows)).
+		structList(4, []thrift{thrift{}.
+			structList(1, chunks).
+			i64(2, int64(total)).
+			i64(3, int64(rows))}).
+		str(6, "aws-tag-report").
+		bytes()
+	file.Write(metadata)
+	binary.Write(&file, binary.LittleEndian, uint32(len(metadata)))
+	file.Write(parquetMagic)
+
+	_, err := file.WriteTo(w)
+	return err
+}
+
+// thrift builds a struct with the thrift compact protocol, fields must be added in increasing id order
+type thrift struct {
+	buf  []byte
+	last int16
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (t thrift) field(id int16, kind byte) thrift {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|kind)
+	} else {
+		t.buf = append(t.buf, kind)
+		t.buf = appendVarint(t.buf, int64(id))
+	}
+	t.last = id
+	return t
+}
+
+func (t thrift) i32(id int16, v int32) thrift {
+	t = t.field(id, thriftI32)
+	t.buf = appendVarint(t.buf, int64(v))
+	return t
+}
+
+func (t thrift) i64(id int16, v int64) thrift {
+	t = t.field(id, thriftI64)
+	t.buf = appendVarint(t.buf, v)
+	return t
+}
+
+func (t thrift) str(id int16, v string) thrift {
+	t = t.field(id, thriftBinary)
+	t.buf = appendString(t.buf, v)
+	return t
+}
+
+func (t thrift) structure(id int16, v thrift) thrift {
+	t = t.field(id, thriftStruct)
+	t.buf = append(t.buf, v.bytes()...)
+	return t
+}
+
+func (t thrift) i32List(id int16, values ...int32) thrift {
+	t = t.field(id, thriftList)
+	t.buf = appendListHeader(t.buf, len(values), thriftI32)
+	for _, v := range values {
+		t.buf = appendVarint(t.buf, int64(v))
+	}
+	return t
+}
+
+func (t thrift) stringList(id int16, values ...string) thrift {
+	t = t.field(id, thriftList)
+	t.buf = appendListHeader(t.buf, len(values), thriftBinary)
+	for _, v := range values {
+		t.buf = appendString(t.buf, v)
+	}
+	return t
+}
+
+func (t thrift) structList(id int16, values []thrift) thrift {
+	t = t.field(id, thriftList)
+	t.buf = appendListHeader(t.buf, len(values), thriftStruct)
+	for _, v := range values {
+		t.buf = append(t.buf, v.bytes()...)
+	}
+	return t
+}
+
+// bytes terminates the struct with a stop field
+func (t thrift) bytes() []byte {
+	return append(append([]byte{}, t.buf...), 0)
+}
+
+func appendListHeader(buf []byte, size int, kind byte) []byte {
+	if size < 15 {
+		return append(buf, byte(size)<<4|kind)
+	}
+	buf = append(buf, 0xf0|kind)
+	return appendUvarint(buf, uint64(size))
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendVarint appends a zigzag encoded varint
+func appendVarint(buf []byte, v int64) []byte {
+	return appendUvarint(buf, uint64(v<<1^v>>63))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(buf, b[:binary.PutUvarint(b[:], v)]...)
+}
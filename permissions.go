@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/aws/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// statusSkippedPermission marks the stacks whose resources the caller may not list
+const statusSkippedPermission = "SKIPPED_PERMISSION"
+
+// fallbackRoleArn is the role of -fallback-role-arn listing the resources of the stacks
+// the caller may not, none when empty
+var fallbackRoleArn string
+
+// accessDeniedErrorCodes are the error codes of the calls denied by iam or the service
+// control policies
+var accessDeniedErrorCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedOperation": true,
+}
+
+// isAccessDenied tells whether a call was denied
+func isAccessDenied(err error) bool {
+	var ae awserr.Error
+	return errors.As(err, &ae) && accessDeniedErrorCodes[ae.Code()]
+}
+
+// StackSkippedError is the error of a stack whose resources could not be listed, e.g. as
+// the service control policies of another team deny it, the stack being reported as
+// SKIPPED_PERMISSION rather than stopping the scan
+type StackSkippedError struct {
+	Stack string
+	Err   error
+}
+
+func (e *StackSkippedError) Error() string {
+	return fmt.Sprintf("%s: unable to list the resources of stack %s, %v", statusSkippedPermission, e.Stack, e.Err)
+}
+
+// isStackSkipped tells whether a resource stands for a stack skipped for lack of permission
+func isStackSkipped(err error) bool {
+	var skipped *StackSkippedError
+	return errors.As(err, &skipped)
+}
+
+// fallbackConfig is the config of the clients assuming the -fallback-role-arn role
+func fallbackConfig(config aws.Config, role string) aws.Config {
+	config = config.Copy()
+	config.Credentials = stscreds.NewAssumeRoleProvider(sts.New(config), role)
+	return config
+}
+
+// skippedStackResource stands for the resources of a stack which could not be listed, as a
+// resource of the stack itself
+func skippedStackResource(stack Stack, err error) StackResource {
+	return StackResource{
+		StackResource: cloudformation.StackResource{
+			LogicalResourceId:  aws.String(stack.Name),
+			PhysicalResourceId: aws.String(stack.Id),
+			ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+			StackId:            aws.String(stack.Id),
+			StackName:          aws.String(stack.Name),
+		},
+		Stack:   stack,
+		Skipped: &StackSkippedError{stack.Name, err},
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/appconfig"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed policy.yaml
+var defaultPolicy []byte
+
+// Policy defines the tag keys the resources are required to carry, read from a yaml
+// or json file so every organization can define its own
+type Policy struct {
+	Schemes []Scheme `yaml:"schemes"`
+	// Migration maps the classic keys to their modern equivalent
+	Migration map[string]string `yaml:"migration"`
+	// EnvironmentTags are the stack tags naming the environment of a stack
+	EnvironmentTags []string      `yaml:"environmentTags"`
+	Environments    []Environment `yaml:"environments"`
+	// ExemptionTag opts resources out of the policy, rlg:tag-exempt by default
+	ExemptionTag string `yaml:"exemptionTag"`
+	// Aliases maps legacy tag keys to the key they stand for, e.g. Env to Environment
+	Aliases map[string]string `yaml:"aliases"`
+	// Values maps tag keys to the values they accept, a key with any other value is
+	// reported as invalid rather than missing
+	Values map[string]*ValueRule `yaml:"values"`
+	// Catalog names the tag keys the backstage and servicenow formats map to the catalog
+	Catalog CatalogKeys `yaml:"catalog"`
+}
+
+// ValueRule constrains the values of a tag key to a regular expression and/or a list
+// of allowed values
+type ValueRule struct {
+	Pattern string   `yaml:"pattern"`
+	Allowed []string `yaml:"allowed"`
+	pattern *regexp.Regexp
+}
+
+// valid tells whether the value is accepted by the rule, the pattern having to match
+// the whole value
+func (v *ValueRule) valid(value string) bool {
+	if len(v.Allowed) > 0 && !containsString(v.Allowed, value) {
+		return false
+	}
+	return v.pattern == nil || v.pattern.MatchString(value)
+}
+
+// the value rules of the tag policy by key
+var valueRules map[string]*ValueRule
+
+// the aliases of the tag policy, from legacy keys to the keys they stand for
+var tagAliases map[string]string
+
+// applyAliases returns the tags with the value of each alias carried over to the key it
+// stands for, unless already tagged, along with the aliases in use as alias=key
+func applyAliases(tags map[string]string) (map[string]string, []string) {
+	if len(tagAliases) == 0 {
+		return tags, nil
+	}
+	var used []string
+	aliased := make(map[string]string, len(tags))
+	for key, value := range tags {
+		aliased[key] = value
+	}
+	for _, alias := range sortedAliases() {
+		value, ok := lookupTag(tags, alias)
+		if !ok {
+			continue
+		}
+		key := tagAliases[alias]
+		if _, tagged := lookupTag(aliased, key); !tagged {
+			aliased[key] = value
+		}
+		used = append(used, alias+"="+key)
+	}
+	return aliased, used
+}
+
+func sortedAliases() []string {
+	var aliases []string
+	for alias := range tagAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// invalidKeys returns the required keys whose values are not accepted by their rule
+func invalidKeys(tags map[string]string, required []string) []string {
+	var invalid []string
+	for _, key := range required {
+		value, ok := lookupTag(tags, key)
+		if rule := valueRules[key]; ok && rule != nil && !rule.valid(value) {
+			invalid = append(invalid, key)
+		}
+	}
+	return invalid
+}
+
+// Environment is recognized by the environment tag of a stack, or else by its name, so
+// the schemes can require fewer keys in sandboxes than in production
+type Environment struct {
+	Name string `yaml:"name"`
+	// Values are the environment tag values of the environment, its name by default
+	Values []string `yaml:"values"`
+	// Stacks are the stack name patterns of the environment, e.g. sandbox-*
+	Stacks []string `yaml:"stacks"`
+}
+
+// matches tells whether a stack is in the environment, from the value of its environment
+// tag when it has one and its name otherwise
+func (e Environment) matches(stackName string, tagValue string) bool {
+	if tagValue != "" {
+		values := e.Values
+		if len(values) == 0 {
+			values = []string{e.Name}
+		}
+		for _, value := range values {
+			if strings.EqualFold(value, tagValue) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pattern := range e.Stacks {
+		if matched, _ := path.Match(pattern, stackName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// the environments of the tag policy, and the stack tags naming them
+var environments []Environment
+var environmentTags []string
+
+// stackEnvironment names the environment of a stack, empty when it is in none
+func stackEnvironment(stackName string, tags map[string]string) string {
+	var tagValue string
+	for _, key := range environmentTags {
+		if tagValue = tags[key]; tagValue != "" {
+			break
+		}
+	}
+	for _, environment := range environments {
+		if environment.matches(stackName, tagValue) {
+			return environment.Name
+		}
+	}
+	return ""
+}
+
+// Scheme is a tagging standard, a named set of required keys
+type Scheme struct {
+	Name string   `yaml:"name"`
+	Keys []string `yaml:"keys"`
+	// Types maps resource type patterns, e.g. AWS::S3::Bucket or AWS::RDS::*, to the
+	// keys only required on those types, such as a data classification on data stores
+	Types map[string][]string `yaml:"types"`
+	// Environments maps environment names to the only keys required in them, replacing
+	// the keys above
+	Environments map[string][]string `yaml:"environments"`
+}
+
+// required returns the keys the scheme requires on the resource, none when exempt
+func (s Scheme) required(resource Resource) []string {
+	if resource.Exemption != "" {
+		return nil
+	}
+	if keys, ok := s.Environments[resource.Stack.Environment]; ok {
+		return keys
+	}
+	keys := append([]string{}, s.Keys...)
+	for _, pattern := range sortedKeys(s.Types) {
+		if matched, _ := path.Match(pattern, resource.Type); matched {
+			keys = appendMissing(keys, s.Types[pattern])
+		}
+	}
+	return keys
+}
+
+// allKeys returns every key the scheme may require, whatever the resource type
+func (s Scheme) allKeys() []string {
+	keys := append([]string{}, s.Keys...)
+	for _, pattern := range sortedKeys(s.Types) {
+		keys = appendMissing(keys, s.Types[pattern])
+	}
+	for _, name := range sortedKeys(s.Environments) {
+		keys = appendMissing(keys, s.Environments[name])
+	}
+	return keys
+}
+
+// loadPolicy reads the policy at path, the embedded default policy when path is empty
+func loadPolicy(ctx context.Context, config aws.Config, path string) (*Policy, error) {
+	body := defaultPolicy
+	if path != "" {
+		var err error
+		if body, err = readPolicy(ctx, config, path); err != nil {
+			return nil, err
+		}
+	}
+	policy, err := parsePolicy(body)
+	if err != nil && path != "" {
+		return nil, fmt.Errorf("tag policy %s: %v", path, err)
+	}
+	return policy, err
+}
+
+// readPolicy reads a policy file, or fetches the policy at runtime so a central team can
+// update it for everyone: from the ssm:<name> parameter or the configuration profile of
+// appconfig:<application>/<environment>/<profile>
+func readPolicy(ctx context.Context, config aws.Config, path string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(path, "ssm:"):
+		request := ssm.New(config).GetParameterRequest(&ssm.GetParameterInput{
+			Name:           aws.String(strings.TrimPrefix(path, "ssm:")),
+			WithDecryption: aws.Bool(true),
+		})
+		response, err := request.Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(aws.StringValue(response.Parameter.Value)), nil
+	case strings.HasPrefix(path, "appconfig:"):
+		parts := strings.Split(strings.TrimPrefix(path, "appconfig:"), "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("tag policy %s: expected appconfig:<application>/<environment>/<profile>", path)
+		}
+		request := appconfig.New(config).GetConfigurationRequest(&appconfig.GetConfigurationInput{
+			Application:   aws.String(parts[0]),
+			Environment:   aws.String(parts[1]),
+			Configuration: aws.String(parts[2]),
+			ClientId:      aws.String("aws-tag-report"),
+		})
+		response, err := request.Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return response.Content, nil
+	default:
+		return ioutil.ReadFile(path)
+	}
+}
+
+// parsePolicy decodes a yaml policy, json being a subset of yaml
+func parsePolicy(body []byte) (*Policy, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, err
+	}
+	for _, scheme := range policy.Schemes {
+		if scheme.Name == "" || len(scheme.Keys) == 0 {
+			return nil, fmt.Errorf("every scheme requires a name and at least one key")
+		}
+		for pattern := range scheme.Types {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("the %s scheme has an invalid resource type pattern %q", scheme.Name, pattern)
+			}
+		}
+		for name := range scheme.Environments {
+			if policy.environment(name) == nil {
+				return nil, fmt.Errorf("the %s scheme requires keys in the unknown environment %q", scheme.Name, name)
+			}
+		}
+	}
+	for _, environment := range policy.Environments {
+		if environment.Name == "" {
+			return nil, fmt.Errorf("every environment requires a name")
+		}
+		for _, pattern := range environment.Stacks {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("the %s environment has an invalid stack name pattern %q", environment.Name, pattern)
+			}
+		}
+	}
+	for key, rule := range policy.Values {
+		if rule == nil || rule.Pattern == "" && len(rule.Allowed) == 0 {
+			return nil, fmt.Errorf("the values of %s require a pattern or allowed values", key)
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile("^(?:" + rule.Pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("the values of %s have an invalid pattern: %v", key, err)
+			}
+			rule.pattern = pattern
+		}
+	}
+	if len(policy.EnvironmentTags) == 0 {
+		policy.EnvironmentTags = []string{"rlg:environment", "Environment"}
+	}
+	for _, name := range []string{"classic", "modern"} {
+		if policy.scheme(name) == nil {
+			return nil, fmt.Errorf("the %s scheme is required", name)
+		}
+	}
+	return &policy, nil
+}
+
+func (p *Policy) scheme(name string) *Scheme {
+	for i := range p.Schemes {
+		if p.Schemes[i].Name == name {
+			return &p.Schemes[i]
+		}
+	}
+	return nil
+}
+
+func (p *Policy) environment(name string) *Environment {
+	for i := range p.Environments {
+		if p.Environments[i].Name == name {
+			return &p.Environments[i]
+		}
+	}
+	return nil
+}
+
+// title is the scheme name as a column title
+func (s Scheme) title() string {
+	if s.Name == "" {
+		return ""
+	}
+	return strings.ToUpper(s.Name[:1]) + s.Name[1:]
+}
+
+// appendMissing appends the keys not already in keys
+func appendMissing(keys []string, more []string) []string {
+	for _, key := range more {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply makes the policy the one the resources are reported against, reporting the
+// coverage of the selected schemes, every scheme when none is selected
+func (p *Policy) apply(selected []string) error {
+	schemes = p.Schemes
+	if len(selected) > 0 {
+		schemes = nil
+		for _, name := range selected {
+			scheme := p.scheme(name)
+			if scheme == nil {
+				return fmt.Errorf("unknown tag scheme %q", name)
+			}
+			schemes = append(schemes, *scheme)
+		}
+	}
+	classic = *p.scheme("classic")
+	modern = *p.scheme("modern")
+	classicToModern = p.Migration
+	environments, environmentTags = p.Environments, p.EnvironmentTags
+	valueRules = p.Values
+	tagAliases = p.Aliases
+	if p.ExemptionTag != "" {
+		exemptionTag = p.ExemptionTag
+	}
+	catalogKeys.merge(p.Catalog)
+	return nil
+}
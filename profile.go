@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sync"
+)
+
+// startProfiling serves the pprof endpoints on -pprof-addr and starts the -cpuprofile, returning
+// the function stopping the cpu profile and writing the -memprofile; an interrupted scan is
+// stopped the same way so the profiles of a long scan are not lost
+func startProfiling(options *Options) (func(), error) {
+	if options.PprofAddr != "" {
+		listener, err := net.Listen("tcp", options.PprofAddr)
+		if err != nil {
+			return nil, fmt.Errorf("-pprof-addr: %v", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		fmt.Fprintf(os.Stderr, "serving pprof on http://%s/debug/pprof/\n", listener.Addr())
+		go http.Serve(listener, mux)
+	}
+
+	var cpu *os.File
+	if options.CPUProfile != "" {
+		f, err := os.Create(options.CPUProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpu = f
+	}
+	if cpu == nil && options.MemProfile == "" {
+		return func() {}, nil
+	}
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			if cpu != nil {
+				runtimepprof.StopCPUProfile()
+				if err := cpu.Close(); err != nil {
+					fmt.Fprintln(os.Stderr, "-cpuprofile:", err.Error())
+				}
+			}
+			if options.MemProfile != "" {
+				if err := writeHeapProfile(options.MemProfile); err != nil {
+					fmt.Fprintln(os.Stderr, "-memprofile:", err.Error())
+				}
+			}
+		})
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		stop()
+		os.Exit(130)
+	}()
+	return stop, nil
+}
+
+// writeHeapProfile writes the profile of the memory in use once garbage collected
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+)
+
+// propagate runs the propagate subcommand: propagate [-dry-run] [-rate n] [-log file]
+// [-emit-script file] [-- report options] search, adding to the resources of the stacks the required keys
+// they miss which their stack carries
+func propagate(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report propagate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var flags taggerFlags
+	flags.register(fs, "added")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report propagate [options] [-- report options] searchString"+
+			"\n\tadds to each resource the required tags it misses which its stack carries, logging the result of"+
+			"\n\teach resource; the report is only written with -output"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var propagation *propagateReporter
+	err := tagScan(w, stderr, fs.Args(), &flags, func(tagger *Tagger) Reporter {
+		propagation = &propagateReporter{w: stderr, tagger: tagger}
+		return propagation
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%d resources missing tags their stack carries, %d failed, %d without an ARN\n",
+		propagation.changes, propagation.tagger.failed, propagation.skipped)
+	if propagation.tagger.failed > 0 {
+		return fmt.Errorf("tagging failed for %d resources", propagation.tagger.failed)
+	}
+	return nil
+}
+
+// tagScan scans the resources as the report options of args tell, the resources being handed
+// to the reporter newReporter creates to change their tags; the report is only written with -output
+func tagScan(w io.Writer, stderr io.Writer, args []string, flags *taggerFlags,
+	newReporter func(tagger *Tagger) Reporter) error {
+	if err := flags.validate(); err != nil {
+		return err
+	}
+	options, err := parseOptions(stderr, args)
+	if err != nil {
+		return err
+	}
+	if options.Watch > 0 || options.TUI {
+		return fmt.Errorf("-watch and -tui do not apply when changing tags")
+	}
+	if options.Output == "" && options.Format != "sqlite" && options.ParquetDir == "" {
+		options.Output = os.DevNull
+	}
+
+	ctx := context.TODO()
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	tagger, closeTagger, err := flags.open(ctx, cfg, w, options.CSVDialect)
+	if err != nil {
+		return err
+	}
+	defer closeTagger()
+
+	_, err = run(ctx, cfg, options, nil, newReporter(tagger))
+	return err
+}
+
+// propagateReporter tags each resource with the required keys it misses which its stack
+// carries, the types whose ARN is unknown being skipped
+type propagateReporter struct {
+	w       io.Writer
+	tagger  *Tagger
+	changes int
+	skipped int
+}
+
+func (r *propagateReporter) Add(result Result) {
+	if result.Finding == nil {
+		return
+	}
+	resource := result.Resource
+	add := make(map[string]string)
+	for _, key := range result.Finding.Missing {
+		if value, ok := resource.Stack.Tags[key]; ok {
+			add[key] = value
+		}
+	}
+	if len(add) == 0 {
+		return
+	}
+	r.changes++
+	arn := result.Arn
+	if arn == "" {
+		fmt.Fprintf(r.w, "%s %s has no known ARN, skipped\n", resource.Type, resource.Name)
+		r.skipped++
+		return
+	}
+	r.tagger.tag(arn, add)
+}
+
+func (r *propagateReporter) Write() {
+}
+
+func (r *propagateReporter) Close() {
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/quicksight"
+)
+
+// QuickSightManifest is the S3 manifest QuickSight imports the csv reports uploaded to s3 with
+// https://docs.aws.amazon.com/quicksight/latest/user/supported-manifest-file-format.html
+type QuickSightManifest struct {
+	FileLocations        []QuickSightFileLocation `json:"fileLocations"`
+	GlobalUploadSettings QuickSightUploadSettings `json:"globalUploadSettings"`
+}
+
+type QuickSightFileLocation struct {
+	URIPrefixes []string `json:"URIPrefixes"`
+}
+
+type QuickSightUploadSettings struct {
+	Format         string `json:"format"`
+	Delimiter      string `json:"delimiter"`
+	TextQualifier  string `json:"textqualifier"`
+	ContainsHeader string `json:"containsHeader"`
+}
+
+// quickSightDataSourceActions and quickSightDataSetActions are granted to the owner
+var quickSightDataSourceActions = []string{
+	"quicksight:DescribeDataSource", "quicksight:DescribeDataSourcePermissions", "quicksight:PassDataSource",
+	"quicksight:UpdateDataSource", "quicksight:DeleteDataSource", "quicksight:UpdateDataSourcePermissions",
+}
+var quickSightDataSetActions = []string{
+	"quicksight:DescribeDataSet", "quicksight:DescribeDataSetPermissions", "quicksight:PassDataSet",
+	"quicksight:DescribeIngestion", "quicksight:ListIngestions", "quicksight:CreateIngestion", "quicksight:CancelIngestion",
+	"quicksight:UpdateDataSet", "quicksight:DeleteDataSet", "quicksight:UpdateDataSetPermissions",
+}
+
+// newQuickSightManifest describes the reports of every run uploaded below the root of the upload
+func newQuickSightManifest(upload *S3Upload, dialect CSVDialect) QuickSightManifest {
+	delimiter := ","
+	if dialect.Delimiter != 0 {
+		delimiter = string(dialect.Delimiter)
+	}
+	return QuickSightManifest{
+		FileLocations: []QuickSightFileLocation{{URIPrefixes: []string{fmt.Sprintf("s3://%s/%s", upload.bucket, upload.root)}}},
+		GlobalUploadSettings: QuickSightUploadSettings{
+			Format:         "CSV",
+			Delimiter:      delimiter,
+			TextQualifier:  "\"",
+			ContainsHeader: "true",
+		},
+	}
+}
+
+// write writes the manifest to an s3://bucket/key location, or to a local file otherwise
+func (m QuickSightManifest) write(upload *S3Upload, location string) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(location, "s3://") {
+		return ioutil.WriteFile(location, body, 0644)
+	}
+	bucket, key, _ := parseS3URI(location)
+	return upload.put(bucket, key, bytes.NewReader(body))
+}
+
+// QuickSightDataSet creates or updates a SPICE dataset over the manifest of the reports,
+// along with its S3 data source, its columns following those of the csv report
+type QuickSightDataSet struct {
+	ctx     context.Context
+	client  *quicksight.Client
+	account string
+	id      string
+	// owner is the principal granted the permissions on new data sources and datasets
+	owner string
+}
+
+func newQuickSightDataSet(ctx context.Context, config aws.Config, account string, id string, owner string) *QuickSightDataSet {
+	return &QuickSightDataSet{
+		ctx:     ctx,
+		client:  quicksight.New(config),
+		account: account,
+		id:      id,
+		owner:   owner,
+	}
+}
+
+// register creates or updates the data source of the manifest at s3://bucket/key then the
+// dataset, which refreshes it
+func (d *QuickSightDataSet) register(manifest string, header []string, dialect CSVDialect) error {
+	bucket, key, _ := parseS3URI(manifest)
+	sourceId := d.id + "-source"
+	parameters := &quicksight.DataSourceParameters{
+		S3Parameters: &quicksight.S3Parameters{
+			ManifestFileLocation: &quicksight.ManifestFileLocation{Bucket: aws.String(bucket), Key: aws.String(key)},
+		},
+	}
+	source, err := d.client.CreateDataSourceRequest(&quicksight.CreateDataSourceInput{
+		AwsAccountId:         aws.String(d.account),
+		DataSourceId:         aws.String(sourceId),
+		Name:                 aws.String(sourceId),
+		Type:                 quicksight.DataSourceTypeS3,
+		DataSourceParameters: parameters,
+		Permissions:          d.permissions(quickSightDataSourceActions),
+	}).Send(d.ctx)
+	var sourceArn *string
+	var ae awserr.Error
+	if errors.As(err, &ae) && ae.Code() == quicksight.ErrCodeResourceExistsException {
+		updated, err := d.client.UpdateDataSourceRequest(&quicksight.UpdateDataSourceInput{
+			AwsAccountId:         aws.String(d.account),
+			DataSourceId:         aws.String(sourceId),
+			Name:                 aws.String(sourceId),
+			DataSourceParameters: parameters,
+		}).Send(d.ctx)
+		if err != nil {
+			return fmt.Errorf("quicksight data source %s: %v", sourceId, err)
+		}
+		sourceArn = updated.Arn
+	} else if err != nil {
+		return fmt.Errorf("quicksight data source %s: %v", sourceId, err)
+	} else {
+		sourceArn = source.Arn
+	}
+
+	physical, logical := d.tables(aws.StringValue(sourceArn), header, dialect)
+	_, err = d.client.CreateDataSetRequest(&quicksight.CreateDataSetInput{
+		AwsAccountId:     aws.String(d.account),
+		DataSetId:        aws.String(d.id),
+		Name:             aws.String(d.id),
+		ImportMode:       quicksight.DataSetImportModeSpice,
+		PhysicalTableMap: physical,
+		LogicalTableMap:  logical,
+		Permissions:      d.permissions(quickSightDataSetActions),
+	}).Send(d.ctx)
+	if errors.As(err, &ae) && ae.Code() == quicksight.ErrCodeResourceExistsException {
+		_, err = d.client.UpdateDataSetRequest(&quicksight.UpdateDataSetInput{
+			AwsAccountId:     aws.String(d.account),
+			DataSetId:        aws.String(d.id),
+			Name:             aws.String(d.id),
+			ImportMode:       quicksight.DataSetImportModeSpice,
+			PhysicalTableMap: physical,
+			LogicalTableMap:  logical,
+		}).Send(d.ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("quicksight dataset %s: %v", d.id, err)
+	}
+	return nil
+}
+
+// tables maps the header of the csv report to the string columns of the s3 source, the
+// coverage columns being cast to integers
+func (d *QuickSightDataSet) tables(sourceArn string, header []string, dialect CSVDialect) (map[string]quicksight.PhysicalTable, map[string]quicksight.LogicalTable) {
+	delimiter := ","
+	if dialect.Delimiter != 0 {
+		delimiter = string(dialect.Delimiter)
+	}
+	var columns []quicksight.InputColumn
+	var casts []quicksight.TransformOperation
+	for _, title := range header {
+		columns = append(columns, quicksight.InputColumn{Name: aws.String(title), Type: quicksight.InputColumnDataTypeString})
+		if strings.HasSuffix(title, " Coverage") {
+			casts = append(casts, quicksight.TransformOperation{CastColumnTypeOperation: &quicksight.CastColumnTypeOperation{
+				ColumnName:    aws.String(title),
+				NewColumnType: quicksight.ColumnDataTypeInteger,
+			}})
+		}
+	}
+	physical := map[string]quicksight.PhysicalTable{
+		"reports": {S3Source: &quicksight.S3Source{
+			DataSourceArn: aws.String(sourceArn),
+			InputColumns:  columns,
+			UploadSettings: &quicksight.UploadSettings{
+				Format:         quicksight.FileFormatCsv,
+				Delimiter:      aws.String(delimiter),
+				TextQualifier:  quicksight.TextQualifierDoubleQuote,
+				ContainsHeader: aws.Bool(true),
+			},
+		}},
+	}
+	logical := map[string]quicksight.LogicalTable{
+		"report": {
+			Alias:          aws.String(d.id),
+			DataTransforms: casts,
+			Source:         &quicksight.LogicalTableSource{PhysicalTableId: aws.String("reports")},
+		},
+	}
+	return physical, logical
+}
+
+func (d *QuickSightDataSet) permissions(actions []string) []quicksight.ResourcePermission {
+	if d.owner == "" {
+		return nil
+	}
+	return []quicksight.ResourcePermission{{Principal: aws.String(d.owner), Actions: actions}}
+}
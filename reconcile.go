@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+)
+
+// anyKey labels the cost of the resources missing at least one of the keys
+const anyKey = "(any)"
+
+// unallocatedRow is the cost of the resources of an account missing a tag key
+type unallocatedRow struct {
+	Account     string
+	Key         string
+	Cost        float64
+	Unallocated float64
+}
+
+// unallocated runs the unallocated subcommand: unallocated -cur-table database.table [options],
+// the spend of the resources of the Cost and Usage Report missing cost allocation tags, per
+// account and per missing key
+func unallocated(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report unallocated", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	table := fs.String("cur-table", "", "database.table of the Cost and Usage Report in the Glue Data Catalog, queried with Athena")
+	output := fs.String("athena-output", "", "s3://bucket/prefix/ Athena writes the query results to, defaults to the output location of the primary workgroup")
+	days := fs.Int("days", 30, "number of days of spend")
+	var keys []string
+	fs.Var((*listFlag)(&keys), "keys", "tag keys to reconcile, defaults to the modern keys of the tag policy")
+	tagPolicy := fs.String("tag-policy", "", "yaml or json file, ssm:<parameter name> or appconfig:<application>/<environment>/<profile> "+
+		"of the tag policy, defaults to the embedded policy.yaml")
+	delimiter := fs.String("csv-delimiter", ",", "csv field delimiter, a single character or \"tab\"")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report unallocated -cur-table database.table [options]"+
+			"\n\tlists per account the spend of the resources missing each tag key, and missing any of them"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *table == "" {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	database, name := splitGlueTable(*table)
+	if database == "" {
+		return fmt.Errorf("invalid -cur-table %q, expected database.table", *table)
+	} else if *days < 1 {
+		return fmt.Errorf("invalid -days %d", *days)
+	} else if *output != "" {
+		if _, _, err := parseS3URI(*output); err != nil {
+			return err
+		}
+	}
+	var dialect CSVDialect
+	var err error
+	if dialect.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		return err
+	}
+
+	ctx := context.TODO()
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	if len(keys) == 0 {
+		policy, err := loadPolicy(ctx, cfg, *tagPolicy)
+		if err == nil {
+			err = policy.apply(nil)
+		}
+		if err != nil {
+			return err
+		}
+		keys = modern.allKeys()
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no tag keys to reconcile")
+	}
+
+	// the keys activated for cost allocation are columns of the report, the spend being
+	// unallocated for the others
+	response, err := glue.New(cfg).GetTableRequest(&glue.GetTableInput{
+		DatabaseName: aws.String(database),
+		Name:         aws.String(name),
+	}).Send(ctx)
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]bool)
+	for _, column := range response.Table.StorageDescriptor.Columns {
+		columns[aws.StringValue(column.Name)] = true
+	}
+	var conditions []string
+	for _, key := range keys {
+		column := curTagColumn(key)
+		if !columns[column] {
+			fmt.Fprintf(stderr, "%s is not activated for cost allocation, the %s column is missing\n", key, column)
+			conditions = append(conditions, "TRUE")
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("coalesce(%s, '') = ''", column))
+	}
+
+	rows := unallocatedCosts(athenaQuery(ctx, cfg, database, unallocatedQuery(database, name, conditions, *days), *output), keys)
+
+	var cost, missing float64
+	for _, row := range rows {
+		if row.Key == anyKey {
+			cost += row.Cost
+			missing += row.Unallocated
+		}
+	}
+	fmt.Fprintf(stderr, "%.2f of %.2f unallocated over %d days, the resources missing at least one key\n", missing, cost, *days)
+
+	writer := newCSVWriter(w, dialect)
+	writer.Write([]string{"Account", "Tag Key", "Cost", "Unallocated Cost", "Unallocated Percentage"})
+	for _, row := range rows {
+		share := 0.0
+		if row.Cost > 0 {
+			share = 100 * row.Unallocated / row.Cost
+		}
+		writer.Write([]string{row.Account, row.Key, strconv.FormatFloat(row.Cost, 'f', 2, 64),
+			strconv.FormatFloat(row.Unallocated, 'f', 2, 64), strconv.FormatFloat(share, 'f', 1, 64) + "%"})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// curTagColumn is the column of the Cost and Usage Report holding the values of a user
+// defined tag key, as its Athena integration names it
+func curTagColumn(key string) string {
+	return "resource_tags_user_" + strings.Trim(nonIdentifier.ReplaceAllString(strings.ToLower(key), "_"), "_")
+}
+
+// unallocatedQuery sums per account the cost of the resources, then the cost of those
+// matching each condition, then the cost of those matching any of them
+func unallocatedQuery(database string, table string, conditions []string, days int) string {
+	columns := []string{"line_item_usage_account_id", "SUM(line_item_unblended_cost)"}
+	for _, condition := range conditions {
+		columns = append(columns, fmt.Sprintf("SUM(CASE WHEN %s THEN line_item_unblended_cost ELSE 0 END)", condition))
+	}
+	columns = append(columns, fmt.Sprintf("SUM(CASE WHEN %s THEN line_item_unblended_cost ELSE 0 END)",
+		strings.Join(conditions, " OR ")))
+	return fmt.Sprintf(`SELECT %s
+FROM "%s"."%s"
+WHERE line_item_usage_start_date >= date_add('day', -%d, current_timestamp)
+  AND line_item_resource_id <> ''
+GROUP BY line_item_usage_account_id`, strings.Join(columns, ",\n  "), database, table, days)
+}
+
+// unallocatedCosts reads the results of the query, by account then in the order of the keys
+func unallocatedCosts(results [][]string, keys []string) []unallocatedRow {
+	var rows []unallocatedRow
+	for _, result := range results {
+		if len(result) != len(keys)+3 {
+			continue
+		}
+		cost, _ := strconv.ParseFloat(result[1], 64)
+		for i, key := range append(append([]string{}, keys...), anyKey) {
+			missing, _ := strconv.ParseFloat(result[i+2], 64)
+			rows = append(rows, unallocatedRow{result[0], key, roundCost(cost), roundCost(missing)})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Account < rows[j].Account
+	})
+	return rows
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// globalServiceRegions are the regions serving the apis of the global services, by partition
+var globalServiceRegions = map[string]map[string]string{
+	"cloudfront":        {"aws": "us-east-1", "aws-cn": "cn-northwest-1"},
+	"globalaccelerator": {"aws": "us-west-2"},
+	"iam":               {"aws": "us-east-1", "aws-cn": "cn-north-1", "aws-us-gov": "us-gov-west-1"},
+	"organizations":     {"aws": "us-east-1", "aws-cn": "cn-northwest-1", "aws-us-gov": "us-gov-west-1"},
+	"route53":           {"aws": "us-east-1", "aws-cn": "cn-northwest-1", "aws-us-gov": "us-gov-west-1"},
+}
+
+// serviceRegions are the regions or endpoints of the services overridden by -service-region
+var serviceRegions map[string]string
+
+// serviceRegionFlag collects the overrides of services as service=region or
+// service=https://endpoint, the flag being repeated for each service
+type serviceRegionFlag map[string]string
+
+func (f *serviceRegionFlag) String() string {
+	var overrides []string
+	for service, override := range *f {
+		overrides = append(overrides, service+"="+override)
+	}
+	sort.Strings(overrides)
+	return strings.Join(overrides, ",")
+}
+
+func (f *serviceRegionFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return fmt.Errorf("expected service=region or service=https://endpoint")
+	}
+	service, override := value[:i], value[i+1:]
+	if isEndpoint(override) {
+		if u, err := url.Parse(override); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %q", override)
+		}
+	}
+	if *f == nil {
+		*f = make(serviceRegionFlag)
+	}
+	(*f)[service] = override
+	return nil
+}
+
+// isEndpoint tells whether an override is an endpoint url rather than a region
+func isEndpoint(override string) bool {
+	return strings.HasPrefix(override, "https://") || strings.HasPrefix(override, "http://")
+}
+
+// serviceConfig is the config of the clients of a service: targeting the region or endpoint
+// of its -service-region override, or else the region serving a global service
+func serviceConfig(cfg aws.Config, partition string, service string) aws.Config {
+	override, ok := serviceRegions[service]
+	if !ok {
+		override = globalServiceRegions[service][partition]
+	}
+	if override == "" {
+		return cfg
+	}
+	cfg = cfg.Copy()
+	if isEndpoint(override) {
+		cfg.EndpointResolver = aws.ResolveWithEndpointURL(override)
+	} else {
+		cfg.Region = override
+	}
+	return cfg
+}
+
+// serviceRegion is the region of the ARNs of a regional service: the region of its
+// -service-region override, or else the region scanned
+func serviceRegion(region string, service string) string {
+	if override := serviceRegions[service]; override != "" && !isEndpoint(override) {
+		return override
+	}
+	return region
+}
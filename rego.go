@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// regoQuery collects the messages of the deny rules of the tagreport package
+const regoQuery = "data.tagreport.deny"
+
+// RegoPolicy evaluates a rego module against the tags of each resource, for the
+// organizational policies a list of required keys cannot express, e.g.
+//
+//	package tagreport
+//
+//	deny[msg] {
+//		input.tags["rlg:classification"] == "restricted"
+//		not contains(object.get(input.tags, "rlg:compliance", ""), "pci")
+//		msg := "restricted data must be pci compliant"
+//	}
+type RegoPolicy struct {
+	query rego.PreparedEvalQuery
+	arn   arnResolver
+}
+
+// regoInput is the document the deny rules are evaluated against
+type regoInput struct {
+	Type          string            `json:"type"`
+	Name          string            `json:"name"`
+	Arn           string            `json:"arn"`
+	ConstructPath string            `json:"constructPath"`
+	Stack         string            `json:"stack"`
+	Environment   string            `json:"environment"`
+	Tags          map[string]string `json:"tags"`
+}
+
+func loadRegoPolicy(ctx context.Context, path string, arn arnResolver) (*RegoPolicy, error) {
+	module, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	query, err := rego.New(
+		rego.Query(regoQuery),
+		rego.Module(path, string(module)),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("rego policy %s: %v", path, err)
+	}
+	return &RegoPolicy{query: query, arn: arn}, nil
+}
+
+// violations returns the messages of the deny rules the resource breaks
+func (p *RegoPolicy) violations(ctx context.Context, resource Resource, tags map[string]string) []string {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	results, err := p.query.Eval(ctx, rego.EvalInput(regoInput{
+		Type:          resource.Type,
+		Name:          resource.Name,
+		Arn:           p.arn(resource.Type, resource.Name),
+		ConstructPath: resource.ConstructPath,
+		Stack:         resource.Stack.Name,
+		Environment:   resource.Stack.Environment,
+		Tags:          tags,
+	}))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	var messages []string
+	for _, result := range results {
+		for _, expression := range result.Expressions {
+			denied, ok := expression.Value.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, message := range denied {
+				if text, ok := message.(string); ok {
+					messages = append(messages, text)
+				} else {
+					messages = append(messages, fmt.Sprint(message))
+				}
+			}
+		}
+	}
+	sort.Strings(messages)
+	return messages
+}
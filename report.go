@@ -2,68 +2,539 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
+// Resource identifies a scanned resource within its stack
+type Resource struct {
+	Type string
+	// Name is the physical id of the resource
+	Name          string
+	ConstructPath string
+	Stack         Stack
+	// Stacks lists every stack sharing the resource when deduplicating
+	Stacks []string
+	// LastUpdated is when cloudformation last changed the resource
+	LastUpdated time.Time
+	// Violations are the messages of the policy rules the tags of the resource break
+	Violations []string
+	// Aliases are the legacy tag keys the resource still carries, as alias=key
+	Aliases []string
+	// Exemption is the justification of the exemption of the resource from the tag
+	// policy, an exempt resource requiring no tags
+	Exemption string
+	// MonthlyCost is the cost of the resource over the last 30 days, nil unless looked up
+	MonthlyCost *float64
+	// DriftStatus is the drift status of the resource, empty unless looked up
+	DriftStatus string
+}
+
+// Reporter renders the tag details of each scanned resource in some output format
+type Reporter interface {
+	// Add records the result of a resource, whose Err tells when its tags could not be
+	// looked up and whose Finding is nil unless it supports tags
+	Add(result Result)
+	// Write flushes the resources added so far
+	Write()
+	// Close completes the report
+	Close()
+}
+
+// newReporter creates the Reporter for each selected output format, along with the
+// summaries and metrics when requested, sorting the resources unless disabled; the files
+// are uploaded to s3 as they are completed when s3Upload is set
+func newReporter(options *Options, partition string, account string, region string, s3Upload *S3Upload, router *Router) Reporter {
+	path := expandPath(options.Output, account, region, time.Now())
+	var upload func(path string)
+	if s3Upload != nil {
+		upload = s3Upload.upload
+	}
+	var baseline *Baseline
+	if options.Baseline != "" {
+		var err error
+		if baseline, err = loadBaseline(options.Baseline, options.CSVDialect); err != nil {
+			panic(err.Error())
+		}
+	}
+	var report Reporter
+	var formatReports multiReporter
+	for _, format := range options.Formats {
+		format, path := format, path
+		if len(options.Formats) > 1 {
+			path = formatPath(path, format)
+		}
+		if options.RouteBy != "" {
+			report = newSplitReporter(options.RouteBy, func(key string) Reporter {
+				output := &Output{Path: splitPath(path, "owner", key), RotateRows: options.RotateRows, Upload: router.upload(key, upload)}
+				return sortedFormat(options, format, closingReporter{newFormatReporter(options, format, output, baseline, partition, account, region), output})
+			})
+		} else if options.SplitBy != "" {
+			report = newSplitReporter(options.SplitBy, func(key string) Reporter {
+				output := &Output{Path: splitPath(path, options.SplitBy, key), RotateRows: options.RotateRows, Upload: upload}
+				return sortedFormat(options, format, closingReporter{newFormatReporter(options, format, output, baseline, partition, account, region), output})
+			})
+		} else {
+			output := &Output{Path: path, RotateRows: options.RotateRows, Upload: upload}
+			report = sortedFormat(options, format, closingReporter{newFormatReporter(options, format, output, baseline, partition, account, region), output})
+		}
+		formatReports = append(formatReports, report)
+	}
+	if len(formatReports) > 1 {
+		report = formatReports
+	}
+	if options.OnlyNoncompliant {
+		report = noncompliantReporter{report, options.MinCoverage}
+	}
+	reports := multiReporter{report}
+	if options.SummaryFile != "" {
+		output := &Output{Path: expandPath(options.SummaryFile, account, region, time.Now()), Upload: upload}
+		reports = append(reports, closingReporter{NewSummaryReporter(output.Open(), options.CSVDialect), output})
+	}
+	if options.MissingTagsFile != "" {
+		output := &Output{Path: expandPath(options.MissingTagsFile, account, region, time.Now()), Upload: upload}
+		reports = append(reports, closingReporter{NewMissingTagsReporter(output.Open(), options.CSVDialect), output})
+	}
+	if options.BadgesDir != "" {
+		reports = append(reports, NewBadgesReporter(options.BadgesDir, options.BadgesBy, upload))
+	}
+	if baseline != nil {
+		reports = append(reports, newChangeSummary(os.Stderr, baseline))
+	}
+	if options.MetricsFile != "" || options.Pushgateway != "" {
+		reports = append(reports, NewMetricsReporter(options.MetricsFile, options.Pushgateway, options.Search))
+	}
+	if options.SlackWebhook != "" {
+		link := ""
+		if s3Upload != nil {
+			link = s3Upload.consoleURL()
+		}
+		reports = append(reports, NewSlackReporter(options.SlackWebhook, options.Search, account, region, link, baseline))
+	}
+	if options.JiraURL != "" {
+		reports = append(reports, NewJiraReporter(options.JiraURL, options.JiraProject, options.JiraIssueType,
+			options.JiraOwnerKeys, options.Search))
+	}
+	if options.WebhookURL != "" {
+		reports = append(reports, NewWebhookReporter(options.WebhookURL, options.WebhookHeaders, options.WebhookBatch, baseline))
+	}
+	if len(reports) > 1 {
+		report = reports
+	}
+	return report
+}
+
+// newFormatReporter creates the Reporter of a format writing to output
+func newFormatReporter(options *Options, format string, output *Output, baseline *Baseline, partition string, account string, region string) Reporter {
+	if options.GroupBy != "" {
+		return NewGroupReporter(output.Open(), options.CSVDialect, options.GroupBy)
+	} else if options.Template != "" {
+		return NewTemplateReporter(output.Open(), options.Template, partition, account, region, options.Search)
+	}
+	switch format {
+	case "json":
+		return NewJSONReporter(output.Open(), options.GroupByConstruct, baseline)
+	case "jsonl":
+		return NewJSONLinesReporter(output, options.GroupByConstruct, baseline)
+	case "xlsx":
+		return NewXLSXReporter(output.Open(), options.GroupByConstruct)
+	case "html":
+		return NewHTMLReporter(output.Open(), options.Search, options.GroupByConstruct)
+	case "markdown":
+		return NewMarkdownReporter(output.Open(), options.Search, options.GroupByConstruct)
+	case "parquet":
+		var w io.Writer
+		if options.ParquetDir == "" {
+			w = output.Open()
+		}
+		return NewParquetReporter(w, options.ParquetDir, account, region, options.Search)
+	case "sqlite":
+		return NewSQLiteReporter(options.SQLiteFile, account, region, options.Search)
+	case "junit":
+		return NewJUnitReporter(output.Open(), options.Search)
+	case "sarif":
+		return NewSARIFReporter(output.Open())
+	case "tageditor":
+		return NewTagEditorReporter(output.Open(), options.CSVDialect, region)
+	case "summary":
+		return NewSummaryReporter(output.Open(), options.CSVDialect)
+	case "missing-tags":
+		return NewMissingTagsReporter(output.Open(), options.CSVDialect)
+	case "census":
+		return NewCensusReporter(output.Open(), options.CSVDialect)
+	case "migration":
+		return NewMigrationReporter(output.Open(), options.CSVDialect)
+	case "propagation":
+		return NewPropagationReporter(output.Open(), options.CSVDialect)
+	case "backstage":
+		return NewBackstageReporter(output.Open())
+	case "servicenow":
+		return NewServiceNowReporter(output.Open(), options.CSVDialect)
+	case "values":
+		return NewValuesReporter(output.Open(), options.CSVDialect, options.ValueKeys)
+	default:
+		return NewReporter(output, options.CSVDialect, options.GroupByConstruct, options.TagValues, options.Dedupe, baseline,
+			options.MonthlyCost != "", options.Drift != "", options.SuggestTags)
+	}
+}
+
+// closingReporter closes the output once the report is complete
+type closingReporter struct {
+	Reporter
+	output *Output
+}
+
+func (c closingReporter) Close() {
+	c.Reporter.Close()
+	if err := c.output.Close(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// multiReporter forwards every resource to each of its reporters
+type multiReporter []Reporter
+
+func (m multiReporter) Add(result Result) {
+	for _, r := range m {
+		r.Add(result)
+	}
+}
+
+func (m multiReporter) Write() {
+	for _, r := range m {
+		r.Write()
+	}
+}
+
+func (m multiReporter) Close() {
+	for _, r := range m {
+		r.Close()
+	}
+}
+
+// Report writes the resources as csv, starting a new part of the output with its
+// own header whenever the rows to rotate at are reached
 type Report struct {
-	w *csv.Writer
+	w       *csv.Writer
+	output  *Output
+	dialect CSVDialect
+	count   int
+	// tagValues adds the complete tags of each resource, as a json column or as a column
+	// per tag key which are only known, and the header written, on Close
+	tagValues string
+	header    []string
+	keys      map[string]bool
+	// when grouping by construct, or adding a column per tag key, the rows are held until Close
+	groupByConstruct bool
+	rows             []reportRow
+	// dedupe adds every stack sharing the resource
+	dedupe bool
+	// baseline adds how each resource changed since a previous report
+	baseline *Baseline
+	// cost adds the monthly cost of each resource
+	cost bool
+	// drift adds the drift status of each resource
+	drift bool
+	// suggester adds the values suggested for the keys each resource misses, the rows being
+	// held until Close as the suggestions need every resource of the stack
+	suggester *tagSuggester
 }
 
-var header = []string {"Type", "Resource Name", "Tags", "Missing Tags", "Created By", "Classic Coverage",
-	"Modern Coverage",}
-var classic = []string {"Name","BU","Product","Repository","TeamID","Environment"}
-var modern = []string {"Name","rlg:business-unit","rlg:product","rlg:application","rlg:repository","rlg:techdata-team",
-	"rlg:contact","rlg:environment","rlg:classification","rlg:compliance"}
+type reportRow struct {
+	cells  []string
+	result Result
+}
 
-func NewReporter() *Report {
+// reportHeader is the header of the report, with a coverage column per selected scheme
+func reportHeader() []string {
+	header := []string {"Type", "Resource Name", "Construct Path", "Tags", "Missing Tags", "Invalid Tags", "Case Mismatches",
+		"Aliases", "Policy Violations", "Exemption", "Created By"}
+	for _, scheme := range schemes {
+		header = append(header, scheme.title()+" Coverage")
+	}
+	return append(header, "ARN", "Region", "Account", "Stack Name", "Stack Id",
+		"Last Updated", "Stack Created", "Stack Last Updated")
+}
+
+// csvReportHeader returns the header of the csv report written with the options, but for
+// the tag value columns, which depend on the resources
+func csvReportHeader(options *Options) []string {
+	header := reportHeader()
+	if options.Dedupe {
+		header = append(header, "Stacks")
+	}
+	if options.Baseline != "" {
+		header = append(header, "Change")
+	}
+	if options.MonthlyCost != "" {
+		header = append(header, "Monthly Cost")
+	}
+	if options.Drift != "" {
+		header = append(header, "Drift Status")
+	}
+	if options.TagValues == "json" {
+		header = append(header, "Tag Values")
+	}
+	if options.SuggestTags {
+		header = append(header, "Suggested Tags")
+	}
+	return header
+}
+
+// the classic and modern schemes of the tag policy
+var classic, modern Scheme
+
+// schemes are the schemes of the tag policy whose coverage is reported
+var schemes []Scheme
+
+// CSVDialect tunes the csv output to its consumer, e.g. european excel expects
+// semicolon delimited files with a byte order mark
+type CSVDialect struct {
+	Delimiter rune
+	BOM       bool
+	CRLF      bool
+}
+
+// newCSVWriter creates a csv writer for the dialect, writing the byte order mark right away
+func newCSVWriter(w io.Writer, dialect CSVDialect) *csv.Writer {
+	if dialect.BOM {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			panic(err.Error())
+		}
+	}
+	writer := csv.NewWriter(w)
+	if dialect.Delimiter != 0 {
+		writer.Comma = dialect.Delimiter
+	}
+	writer.UseCRLF = dialect.CRLF
+	return writer
+}
+
+func NewReporter(output *Output, dialect CSVDialect, groupByConstruct bool, tagValues string, dedupe bool, baseline *Baseline,
+	cost bool, drift bool, suggest bool) *Report {
 	var report = &Report{
-		w: csv.NewWriter(os.Stdout),
+		output:           output,
+		dialect:          dialect,
+		tagValues:        tagValues,
+		header:           reportHeader(),
+		keys:             make(map[string]bool),
+		groupByConstruct: groupByConstruct,
+		dedupe:           dedupe,
+		baseline:         baseline,
+		cost:             cost,
+		drift:            drift,
+	}
+	if suggest {
+		report.suggester = newTagSuggester()
+	}
+	if dedupe {
+		report.header = append(report.header, "Stacks")
+	}
+	if baseline != nil {
+		report.header = append(report.header, "Change")
 	}
-	err := report.w.Write(header)
+	if cost {
+		report.header = append(report.header, "Monthly Cost")
+	}
+	if drift {
+		report.header = append(report.header, "Drift Status")
+	}
+	switch tagValues {
+	case "json":
+		report.header = append(report.header, "Tag Values")
+	case "columns":
+		return report
+	}
+	if suggest {
+		report.header = append(report.header, "Suggested Tags")
+	}
+	report.open()
+	return report
+}
+
+// open starts a part of the output with the header
+func (r *Report) open() {
+	r.w = newCSVWriter(r.output.Open(), r.dialect)
+	err := r.w.Write(r.header)
 	if err != nil {
 		panic(err)
 	}
-	return report
 }
 
-func (r Report) Add(resourceType string, name string, stack string, search string, tags map[string]string) {
-	hasModern, missModern := extractKeys(tags, modern)
-	hasClassic, _ := extractKeys(tags, classic)
+// Add writes the row of a result, the finding cells left empty and the coverage not
+// applicable for the resources not supporting tags
+func (r *Report) Add(result Result) {
+	resource := result.Resource
+	row := []string {
+		extractType(resource.Type),
+		resource.Name,
+		resource.ConstructPath,
+	}
+	var modernCoverage *int
+	if finding := result.Finding; finding != nil {
+		modernCoverage = &finding.ModernCoverage
+		row = append(row,
+			strings.Join(finding.Present, ","),
+			strings.Join(finding.Missing, ","),
+			strings.Join(finding.Invalid, ","),
+			strings.Join(finding.CaseMismatches, ","),
+			strings.Join(resource.Aliases, ","),
+			strings.Join(resource.Violations, "; "),
+			resource.Exemption,
+		)
+	} else {
+		row = append(row, "", "", "", "", "", "", "")
+	}
+	row = append(row, resource.Stack.Origin)
+	for _, scheme := range schemes {
+		if result.Finding != nil {
+			row = append(row, fmt.Sprintf("%d%%", result.Finding.Coverage[scheme.Name]))
+		} else if status := errorStatus(result.Err); status != "" {
+			row = append(row, status)
+		} else {
+			row = append(row, "N/A")
+		}
+	}
+	row = append(row, r.details(result)...)
+	r.write(append(row, r.extra(result, modernCoverage)...), result)
+}
 
-	err := r.w.Write([]string {
-		extractType(resourceType),
-		name,
-		strings.Join(hasModern, ","),
-		strings.Join(missModern, ","),
-		extractOrigin(stack, search),
-		fmt.Sprintf("%d%%", 100*len(hasClassic)/len(classic)),
-		fmt.Sprintf("%d%%", 100*len(hasModern)/len(modern)),
-	})
+// details are the cells identifying the resource and its stack
+func (r *Report) details(result Result) []string {
+	resource := result.Resource
+	return []string{
+		result.Arn,
+		result.Region,
+		result.Account,
+		resource.Stack.Name,
+		resource.Stack.Id,
+		formatTime(resource.LastUpdated),
+		formatTime(resource.Stack.CreationTime),
+		formatTime(resource.Stack.LastUpdatedTime),
+	}
+}
 
+// extra are the optional cells listing the stacks sharing the resource, telling how the
+// resource changed since the baseline, what it costs and whether it drifted
+func (r *Report) extra(result Result, coverage *int) []string {
+	resource := result.Resource
+	var cells []string
+	if r.dedupe {
+		cells = append(cells, strings.Join(resource.Stacks, ","))
+	}
+	if r.baseline != nil {
+		key := baselineKey(result.Arn, resource.Stack.Name, resource.Name)
+		cells = append(cells, r.baseline.change(key, coverage))
+	}
+	if r.cost {
+		cells = append(cells, formatCost(resource.MonthlyCost))
+	}
+	if r.drift {
+		cells = append(cells, resource.DriftStatus)
+	}
+	return cells
+}
+
+func (r *Report) write(row []string, result Result) {
+	tags := result.Tags
+	if r.suggester != nil {
+		r.suggester.observe(result.Resource, tags)
+	}
+	switch r.tagValues {
+	case "json":
+		encoded := ""
+		if tags != nil {
+			body, err := json.Marshal(tags)
+			if err != nil {
+				panic(err.Error())
+			}
+			encoded = string(body)
+		}
+		row = append(row, encoded)
+	case "columns":
+		for key := range tags {
+			r.keys[key] = true
+		}
+		r.rows = append(r.rows, reportRow{row, result})
+		return
+	}
+	if r.groupByConstruct || r.suggester != nil {
+		r.rows = append(r.rows, reportRow{row, result})
+		return
+	}
+	r.writeRow(row)
+}
+
+// suggestedTags is the json object of the values suggested for the keys a supported resource
+// misses, empty without suggestions
+func (r *Report) suggestedTags(result Result) string {
+	if !result.Supported {
+		return ""
+	}
+	suggestions := r.suggester.suggestions(result)
+	if len(suggestions) == 0 {
+		return ""
+	}
+	body, err := json.Marshal(suggestions)
 	if err != nil {
 		panic(err.Error())
 	}
+	return string(body)
 }
 
-func (r Report) AddNotSupported(resourceType string, name string, stack string, search string) {
-	err := r.w.Write([]string {
-		extractType(resourceType),
-		name,
-		"",
-		"",
-		extractOrigin(stack, search),
-		"N/A",
-		"N/A",
-	})
-
+func (r *Report) writeRow(row []string) {
+	if r.output.RotateRows > 0 && r.count > 0 && r.count%r.output.RotateRows == 0 {
+		r.Write()
+		r.open()
+	}
+	err := r.w.Write(row)
 	if err != nil {
 		panic(err.Error())
 	}
+	r.count++
+}
+
+// Close writes any held rows, grouped by construct path and along with their suggested tags,
+// and flushes the report
+func (r *Report) Close() {
+	if r.groupByConstruct {
+		sort.SliceStable(r.rows, func(i, j int) bool {
+			return r.rows[i].cells[2] < r.rows[j].cells[2]
+		})
+	}
+	var keys []string
+	if r.tagValues == "columns" {
+		keys = tagColumns(r.keys)
+		for _, key := range keys {
+			r.header = append(r.header, "Tag: "+key)
+		}
+		if r.suggester != nil {
+			r.header = append(r.header, "Suggested Tags")
+		}
+		r.open()
+	}
+	for _, row := range r.rows {
+		for _, key := range keys {
+			row.cells = append(row.cells, row.result.Tags[key])
+		}
+		if r.suggester != nil {
+			row.cells = append(row.cells, r.suggestedTags(row.result))
+		}
+		r.writeRow(row.cells)
+	}
+	r.rows = nil
+	r.Write()
 }
 
-func (r Report) Write() {
+func (r *Report) Write() {
+	if r.w == nil {
+		return
+	}
 	r.w.Flush()
 	err := r.w.Error()
 	if err != nil {
@@ -71,6 +542,27 @@ func (r Report) Write() {
 	}
 }
 
+// formatTime formats a timestamp as RFC 3339 in UTC, unknown times left empty
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// tagColumns orders the tag keys found for a column per key, the required modern keys first
+func tagColumns(found map[string]bool) []string {
+	keys := modern.allKeys()
+	var others []string
+	for key := range found {
+		if !containsString(keys, key) {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
 func extractType(resourceType string) string {
 	split := strings.Split(resourceType, "::")
 	if len(split) > 2 {
@@ -80,19 +572,72 @@ func extractType(resourceType string) string {
 	}
 }
 
-func extractOrigin(stack string, search string) string {
-	if strings.Contains(stack, search) {
-		return "PIPELINE"
-	} else {
-		return "CUSTOM"
+// normalizeKeys matches the tag keys regardless of case, the keys carried with another
+// case than the required keys being reported as case mismatches rather than missing
+var normalizeKeys bool
+
+// lookupTag returns the value of a tag key, regardless of case with -normalize-keys
+func lookupTag(tags map[string]string, key string) (string, bool) {
+	if value, ok := tags[key]; ok || !normalizeKeys {
+		return value, ok
 	}
+	for _, found := range sortedTagKeys(tags) {
+		if strings.EqualFold(found, key) {
+			return tags[found], true
+		}
+	}
+	return "", false
+}
+
+// caseMismatches returns the tag keys carried with another case than the required keys
+func caseMismatches(tags map[string]string, required []string) []string {
+	if !normalizeKeys {
+		return nil
+	}
+	var mismatches []string
+	for _, key := range required {
+		if _, ok := tags[key]; ok {
+			continue
+		}
+		for _, found := range sortedTagKeys(tags) {
+			if strings.EqualFold(found, key) {
+				mismatches = append(mismatches, found)
+				break
+			}
+		}
+	}
+	return mismatches
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// coverage is the percentage of the required keys carried with a valid value
+func coverage(tags map[string]string, required []string) int {
+	if len(required) == 0 {
+		return 100
+	}
+	has, _ := extractKeys(tags, required)
+	return 100 * (len(has) - len(invalidKeys(tags, required))) / len(required)
+}
+
+// compliant tells whether every required key is carried with a valid value
+func compliant(tags map[string]string, required []string) bool {
+	_, miss := extractKeys(tags, required)
+	return len(miss) == 0 && len(invalidKeys(tags, required)) == 0
 }
 
 func extractKeys(sample map[string]string, required []string) ([]string, []string) {
 	var has []string
 	var miss []string
 	for _, key := range required {
-		if _, ok := sample[key]; ok {
+		if _, ok := lookupTag(sample, key); ok {
 			has = append(has, key)
 		} else {
 			miss = append(miss, key)
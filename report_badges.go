@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// badgeAll is the group of the badges of every resource
+const badgeAll = "all"
+
+// BadgesReport writes shields.io style svg badges of the modern and classic coverage of each
+// environment, stack or value of a tag:<key>, and of every resource, along with a shields.io
+// endpoint json file of each badge, for the teams to embed their score in their readme
+type BadgesReport struct {
+	dir    string
+	by     string
+	upload func(path string)
+	groups stackSummaries
+}
+
+// shieldsEndpoint is the json read by the shields.io endpoint badge
+type shieldsEndpoint struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+func NewBadgesReporter(dir string, by string, upload func(path string)) *BadgesReport {
+	return &BadgesReport{
+		dir:    dir,
+		by:     by,
+		upload: upload,
+		groups: make(stackSummaries),
+	}
+}
+
+// group is the group of the badges of a resource, the resources without an environment or
+// the tag falling in routeUnowned
+func (r *BadgesReport) group(resource Resource, tags map[string]string) string {
+	switch r.by {
+	case "stack":
+		return resource.Stack.Name
+	case "environment":
+		if resource.Stack.Environment != "" {
+			return resource.Stack.Environment
+		}
+	default:
+		if value, ok := lookupTag(tags, routeKey(r.by)); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return routeUnowned
+}
+
+func (r *BadgesReport) Add(result Result) {
+	// the coverage being that of the resources supporting tags
+	if result.Finding == nil {
+		return
+	}
+	finding := result.Finding
+	r.groups.add(r.group(result.Resource, result.Tags), finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+}
+
+// Write is a no-op, the badges are written on Close
+func (r *BadgesReport) Write() {
+}
+
+func (r *BadgesReport) Close() {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		panic(err.Error())
+	}
+	for _, name := range r.groups.names() {
+		r.writeBadges(name, r.groups[name])
+	}
+	r.writeBadges(badgeAll, r.groups.total())
+}
+
+// writeBadges writes the svg and json files of the modern and classic coverage of a group
+func (r *BadgesReport) writeBadges(name string, summary *stackSummary) {
+	for _, badge := range []struct {
+		scheme   string
+		coverage int
+	}{
+		{"modern", summary.averageModern()},
+		{"classic", summary.averageClassic()},
+	} {
+		endpoint := shieldsEndpoint{
+			SchemaVersion: 1,
+			Label:         badge.scheme + " tags",
+			Message:       fmt.Sprintf("%d%%", badge.coverage),
+			Color:         badgeColor(badge.coverage),
+		}
+		base := filepath.Join(r.dir, sanitizeFileName(name)+"-"+badge.scheme)
+		r.writeFile(base+".svg", func(w io.Writer) error {
+			_, err := io.WriteString(w, badgeSVG(endpoint.Label, endpoint.Message, badgeColors[endpoint.Color]))
+			return err
+		})
+		r.writeFile(base+".json", func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(endpoint)
+		})
+	}
+}
+
+func (r *BadgesReport) writeFile(path string, write func(w io.Writer) error) {
+	output := &Output{Path: path, Upload: r.upload}
+	if err := write(output.Open()); err != nil {
+		panic(err.Error())
+	}
+	if err := output.Close(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// badgeColors are the fill colors of the shields.io named colors of the badges
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"yellow":      "#dfb317",
+	"red":         "#e05d44",
+}
+
+// badgeColor is the shields.io color of a coverage, by the thresholds of the terminal summary
+func badgeColor(coverage int) string {
+	if coverage >= coverageGood {
+		return "brightgreen"
+	} else if coverage >= coverageFair {
+		return "yellow"
+	}
+	return "red"
+}
+
+// badgeSVG renders a flat shields.io style badge, the width of the text being estimated from
+// the average width of the characters of 11px Verdana
+func badgeSVG(label string, message string, color string) string {
+	textWidth := func(text string) int {
+		return len([]rune(text))*7 + 10
+	}
+	labelWidth, messageWidth := textWidth(label), textWidth(message)
+	width := labelWidth + messageWidth
+	label, message = html.EscapeString(label), html.EscapeString(message)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/>`+
+		`<rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text></g></svg>`+"\n",
+		width, labelWidth, messageWidth, label, message, color, labelWidth/2, labelWidth+messageWidth/2)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogKeys are the tag keys mapped to the catalog entities of the resources
+type CatalogKeys struct {
+	Application string `yaml:"application"`
+	Repository  string `yaml:"repository"`
+	Owner       string `yaml:"owner"`
+	Environment string `yaml:"environment"`
+}
+
+// catalogKeys are the catalog keys of the tag policy
+var catalogKeys = CatalogKeys{
+	Application: "rlg:application",
+	Repository:  "rlg:repository",
+	Owner:       "rlg:techdata-team",
+	Environment: "rlg:environment",
+}
+
+// merge overrides the keys set in keys
+func (c *CatalogKeys) merge(keys CatalogKeys) {
+	if keys.Application != "" {
+		c.Application = keys.Application
+	}
+	if keys.Repository != "" {
+		c.Repository = keys.Repository
+	}
+	if keys.Owner != "" {
+		c.Owner = keys.Owner
+	}
+	if keys.Environment != "" {
+		c.Environment = keys.Environment
+	}
+}
+
+// catalogValues returns the application, repository, owner and environment of a resource,
+// the environment of its stack standing for a missing environment tag
+func catalogValues(resource Resource, tags map[string]string) (string, string, string, string) {
+	application, _ := lookupTag(tags, catalogKeys.Application)
+	repository, _ := lookupTag(tags, catalogKeys.Repository)
+	owner, _ := lookupTag(tags, catalogKeys.Owner)
+	environment, _ := lookupTag(tags, catalogKeys.Environment)
+	if environment == "" {
+		environment = resource.Stack.Environment
+	}
+	return strings.TrimSpace(application), strings.TrimSpace(repository), strings.TrimSpace(owner), strings.TrimSpace(environment)
+}
+
+// backstageEntity is a Resource entity of the Backstage software catalog
+type backstageEntity struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   backstageMetadata `yaml:"metadata"`
+	Spec       backstageSpec     `yaml:"spec"`
+}
+
+type backstageMetadata struct {
+	Name        string            `yaml:"name"`
+	Title       string            `yaml:"title,omitempty"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type backstageSpec struct {
+	Type         string   `yaml:"type"`
+	Owner        string   `yaml:"owner"`
+	System       string   `yaml:"system,omitempty"`
+	DependencyOf []string `yaml:"dependencyOf,omitempty"`
+}
+
+// BackstageReport writes a catalog-info yaml document per resource, a Backstage Resource
+// entity owned by the group of its owner tag and a dependency of the component of its
+// application tag, its repository, stack and tag compliance being annotations
+type BackstageReport struct {
+	w       io.Writer
+	encoder *yaml.Encoder
+}
+
+func NewBackstageReporter(w io.Writer) *BackstageReport {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return &BackstageReport{
+		w:       w,
+		encoder: encoder,
+	}
+}
+
+func (r *BackstageReport) Add(result Result) {
+	// the catalog only holds taggable resources, whose entity refs are unknown without their tags
+	if result.Finding == nil {
+		return
+	}
+	resource, tags, finding := result.Resource, result.Tags, result.Finding
+	application, repository, owner, environment := catalogValues(resource, tags)
+	arn := result.Arn
+
+	entity := backstageEntity{
+		APIVersion: "backstage.io/v1alpha1",
+		Kind:       "Resource",
+		Metadata: backstageMetadata{
+			Name:  backstageName(extractType(resource.Type) + "-" + resource.Name),
+			Title: resource.Name,
+			Annotations: map[string]string{
+				"aws.amazon.com/account-id":           result.Account,
+				"aws.amazon.com/region":               result.Region,
+				"aws.amazon.com/cloudformation-stack": resource.Stack.Name,
+				"aws-tag-report/modern-coverage":      fmt.Sprint(finding.ModernCoverage),
+			},
+		},
+		Spec: backstageSpec{
+			Type:  backstageName(strings.ToLower(strings.Replace(resource.Type, "::", "-", -1))),
+			Owner: "unknown",
+		},
+	}
+	annotations := entity.Metadata.Annotations
+	if arn != "" {
+		annotations["aws.amazon.com/arn"] = arn
+	}
+	if environment != "" {
+		annotations["aws-tag-report/environment"] = environment
+	}
+	if len(finding.Missing) > 0 {
+		annotations["aws-tag-report/missing-tags"] = strings.Join(finding.Missing, ",")
+	}
+	if repository != "" {
+		annotations["backstage.io/source-location"] = "url:" + repositoryURL(repository)
+		if slug := repositorySlug(repository); slug != "" {
+			annotations["github.com/project-slug"] = slug
+		}
+	}
+	if owner != "" {
+		entity.Spec.Owner = "group:default/" + backstageName(owner)
+	}
+	if application != "" {
+		entity.Spec.DependencyOf = []string{"component:default/" + backstageName(application)}
+	}
+	if err := r.encoder.Encode(entity); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *BackstageReport) Write() {
+}
+
+func (r *BackstageReport) Close() {
+	if err := r.encoder.Close(); err != nil {
+		panic(err.Error())
+	}
+}
+
+var backstageNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// backstageName makes a valid entity name of text: at most 63 letters, digits, -, _ or .,
+// a long name being shortened with a hash of the whole text to stay unique
+func backstageName(text string) string {
+	name := strings.Trim(backstageNameInvalid.ReplaceAllString(text, "-"), "-_.")
+	if len(name) <= 63 {
+		return name
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(text))
+	return fmt.Sprintf("%s-%08x", strings.Trim(name[:54], "-_."), hash.Sum32())
+}
+
+// repositoryURL returns the url of a repository tag, an owner/name value standing for a
+// github repository
+func repositoryURL(repository string) string {
+	if strings.Contains(repository, "://") {
+		return repository
+	}
+	return "https://github.com/" + strings.TrimPrefix(repository, "github.com/")
+}
+
+var githubSlug = regexp.MustCompile(`^(?:https?://)?(?:www\.)?(?:github\.com/)?([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+
+// repositorySlug returns the owner/name of a github repository tag, empty for other hosts
+func repositorySlug(repository string) string {
+	if strings.Contains(repository, "://") && !strings.Contains(repository, "github.com/") {
+		return ""
+	}
+	if match := githubSlug.FindStringSubmatch(repository); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// ServiceNowReport writes as csv an import set of configuration items for the ServiceNow
+// CMDB, a row per resource with its application, repository, support group and environment
+// along with its tag compliance, the columns being mapped by a transform map
+type ServiceNowReport struct {
+	w *csv.Writer
+}
+
+func NewServiceNowReporter(w io.Writer, dialect CSVDialect) *ServiceNowReport {
+	report := &ServiceNowReport{
+		w: newCSVWriter(w, dialect),
+	}
+	err := report.w.Write([]string{"name", "object_id", "resource_type", "account_id", "region", "stack",
+		"application", "repository", "support_group", "environment", "tag_compliance", "missing_tags", "tags"})
+	if err != nil {
+		panic(err.Error())
+	}
+	return report
+}
+
+func (r *ServiceNowReport) Add(result Result) {
+	// the tag compliance of the resources not supporting tags is meaningless, and unknown
+	// without the tags
+	if result.Finding == nil {
+		return
+	}
+	resource, tags, finding := result.Resource, result.Tags, result.Finding
+	application, repository, owner, environment := catalogValues(resource, tags)
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		panic(err.Error())
+	}
+	err = r.w.Write([]string{
+		resource.Name,
+		result.Arn,
+		resource.Type,
+		result.Account,
+		result.Region,
+		resource.Stack.Name,
+		application,
+		repository,
+		owner,
+		environment,
+		fmt.Sprint(finding.ModernCoverage),
+		strings.Join(finding.Missing, ","),
+		string(encoded),
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *ServiceNowReport) Write() {
+	r.w.Flush()
+	err := r.w.Error()
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *ServiceNowReport) Close() {
+	r.Write()
+}
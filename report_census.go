@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CensusReport inventories as csv every distinct tag key found on the resources with the
+// number of resources using it and the scheme it belongs to, revealing the rogue and
+// legacy keys to consolidate into the modern scheme
+type CensusReport struct {
+	w         io.Writer
+	dialect   CSVDialect
+	resources int
+	keys      map[string]int
+}
+
+func NewCensusReporter(w io.Writer, dialect CSVDialect) *CensusReport {
+	return &CensusReport{
+		w:       w,
+		dialect: dialect,
+		keys:    make(map[string]int),
+	}
+}
+
+func (r *CensusReport) Add(result Result) {
+	// resources without tags have no keys, nor do those whose tags are unknown
+	if result.Finding == nil {
+		return
+	}
+	tags := result.Tags
+	r.resources++
+	for key := range tags {
+		r.keys[key]++
+	}
+}
+
+// Write is a no-op, the census is written on Close
+func (r *CensusReport) Write() {
+}
+
+func (r *CensusReport) Close() {
+	var keys []string
+	for key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if r.keys[keys[i]] != r.keys[keys[j]] {
+			return r.keys[keys[i]] > r.keys[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	w := newCSVWriter(r.w, r.dialect)
+	header := []string{"Tag", "Resources", "Percentage", "Scheme"}
+	if costAllocation != nil {
+		header = append(header, "Cost Allocation")
+	}
+	err := w.Write(header)
+	for _, key := range keys {
+		if err != nil {
+			break
+		}
+		row := []string{
+			key,
+			fmt.Sprint(r.keys[key]),
+			fmt.Sprintf("%d%%", 100*r.keys[key]/r.resources),
+			tagScheme(key),
+		}
+		if costAllocation != nil {
+			row = append(row, costAllocationStatus(key))
+		}
+		err = w.Write(row)
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// tagScheme names the schemes requiring a tag key, unknown keys being unmanaged
+func tagScheme(key string) string {
+	inClassic, inModern := containsString(classic.allKeys(), key), containsString(modern.allKeys(), key)
+	switch {
+	case inClassic && inModern:
+		return "classic, modern"
+	case inClassic:
+		return "classic"
+	case inModern:
+		return "modern"
+	default:
+		return "unmanaged"
+	}
+}
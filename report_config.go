@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+)
+
+// ConfigCheckReport writes as csv the resources on which the required-tags rules of AWS
+// Config and the report disagree, to keep both compliance views consistent
+type ConfigCheckReport struct {
+	w     *csv.Writer
+	rules []RequiredTagsRule
+}
+
+func NewConfigCheckReporter(w io.Writer, dialect CSVDialect, rules []RequiredTagsRule) *ConfigCheckReport {
+	r := &ConfigCheckReport{
+		w:     newCSVWriter(w, dialect),
+		rules: rules,
+	}
+	r.write([]string{"Type", "Resource Name", "ARN", "Stack Name", "Config Rule", "Rule Keys",
+		"Config Compliance", "Report Compliance", "Missing Tags"})
+	return r
+}
+
+// uncoveredKeys returns the required modern keys no required-tags rule checks
+func uncoveredKeys(rules []RequiredTagsRule) []string {
+	var uncovered []string
+	for _, key := range modern.allKeys() {
+		covered := false
+		for _, rule := range rules {
+			covered = covered || containsString(rule.Keys, key)
+		}
+		if !covered {
+			uncovered = append(uncovered, key)
+		}
+	}
+	return uncovered
+}
+
+func (r *ConfigCheckReport) Add(result Result) {
+	// Config does not evaluate the tags of the resources not supporting tags either, and
+	// nothing is known of the resources whose tags are unknown
+	if result.Finding == nil {
+		return
+	}
+	resource, finding := result.Resource, result.Finding
+	isCompliant := finding.compliant()
+	reported := configservice.ComplianceTypeNonCompliant
+	if isCompliant {
+		reported = configservice.ComplianceTypeCompliant
+	}
+
+	for _, rule := range r.rules {
+		evaluated, ok := rule.Compliance[requiredTagsKey(resource.Type, resource.Name)]
+		// rules report not applicable or insufficient data outside of their scope
+		if !ok || (evaluated != configservice.ComplianceTypeCompliant && evaluated != configservice.ComplianceTypeNonCompliant) {
+			continue
+		}
+		if evaluated == reported {
+			continue
+		}
+		r.write([]string{
+			extractType(resource.Type),
+			resource.Name,
+			result.Arn,
+			resource.Stack.Name,
+			rule.Name,
+			strings.Join(rule.Keys, ","),
+			string(evaluated),
+			string(reported),
+			strings.Join(finding.Missing, ","),
+		})
+	}
+}
+
+func (r *ConfigCheckReport) write(row []string) {
+	if err := r.w.Write(row); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *ConfigCheckReport) Write() {
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *ConfigCheckReport) Close() {
+	r.Write()
+}
+
+// warnUncoveredKeys warns about the required keys no Config rule checks yet
+func warnUncoveredKeys(w io.Writer, rules []RequiredTagsRule) {
+	for _, key := range uncoveredKeys(rules) {
+		fmt.Fprintf(w, "the required tag %s is not checked by any AWS Config required-tags rule\n", key)
+	}
+}
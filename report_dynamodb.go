@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// historyRunItem is the sort key of the item describing a run as a whole
+const historyRunItem = "#run"
+
+// DynamoDBReport persists the compliance of every resource of each run to a DynamoDB
+// table keyed by the runId partition key and the arn sort key, both strings, so coverage
+// can be followed over time without keeping the report files. Each run also has an
+// item whose arn is #run, with its time, search, account, region and totals.
+type DynamoDBReport struct {
+	ctx     context.Context
+	client  *dynamodb.Client
+	table   string
+	run     string
+	started time.Time
+	search  string
+	account string
+	region  string
+	// the items are written in batches, keyed by ARN so a batch holds no duplicates
+	pending   map[string]map[string]dynamodb.AttributeValue
+	resources int
+	compliant int
+}
+
+func NewDynamoDBReporter(ctx context.Context, config aws.Config, table string, account string, region string, search string) *DynamoDBReport {
+	started := time.Now().UTC()
+	return &DynamoDBReport{
+		ctx:     ctx,
+		client:  dynamodb.New(config),
+		table:   table,
+		run:     historyRunId(started, account, region),
+		started: started,
+		search:  search,
+		account: account,
+		region:  region,
+		pending: make(map[string]map[string]dynamodb.AttributeValue),
+	}
+}
+
+// historyRunId identifies a run, sorting by time
+func historyRunId(started time.Time, account string, region string) string {
+	return fmt.Sprintf("%s/%s/%s", started.Format(time.RFC3339), account, region)
+}
+
+func (r *DynamoDBReport) Add(result Result) {
+	if result.Err != nil {
+		status := "ERROR"
+		if errorStatus(result.Err) != "" {
+			status = errorStatus(result.Err)
+		}
+		item := r.item(result, status)
+		item["error"] = stringValue(result.Err.Error())
+		r.put(item)
+		return
+	}
+	if result.Finding == nil {
+		r.put(r.item(result, "NOT_SUPPORTED"))
+		return
+	}
+	finding := result.Finding
+	item := r.item(result, "OK")
+	item["classicCoverage"] = numberValue(finding.ClassicCoverage)
+	item["modernCoverage"] = numberValue(finding.ModernCoverage)
+	item["missingTags"] = listValue(finding.Missing)
+	r.resources++
+	if finding.compliant() {
+		r.compliant++
+	}
+	r.put(item)
+}
+
+func (r *DynamoDBReport) item(result Result, status string) map[string]dynamodb.AttributeValue {
+	resource := result.Resource
+	return map[string]dynamodb.AttributeValue{
+		"runId":     stringValue(r.run),
+		"arn":       stringValue(result.Arn),
+		"timestamp": stringValue(r.started.Format(time.RFC3339)),
+		"account":   stringValue(result.Account),
+		"region":    stringValue(result.Region),
+		"search":    stringValue(result.Search),
+		"stack":     stringValue(resource.Stack.Name),
+		"type":      stringValue(resource.Type),
+		"id":        stringValue(resource.Name),
+		"status":    stringValue(status),
+	}
+}
+
+func (r *DynamoDBReport) put(item map[string]dynamodb.AttributeValue) {
+	r.pending[aws.StringValue(item["arn"].S)] = item
+	if len(r.pending) == 25 {
+		r.Write()
+	}
+}
+
+// Write stores the items added so far, BatchWriteItem taking up to 25 items at a time
+func (r *DynamoDBReport) Write() {
+	var requests []dynamodb.WriteRequest
+	for _, item := range r.pending {
+		requests = append(requests, dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+		if len(requests) == 25 {
+			r.batchWrite(requests)
+			requests = nil
+		}
+	}
+	if len(requests) > 0 {
+		r.batchWrite(requests)
+	}
+	r.pending = make(map[string]map[string]dynamodb.AttributeValue)
+}
+
+func (r *DynamoDBReport) batchWrite(requests []dynamodb.WriteRequest) {
+	batchWriteItems(r.ctx, r.client, r.table, requests)
+}
+
+// batchWriteItems writes up to 25 requests, retrying the ones left unprocessed
+func batchWriteItems(ctx context.Context, client *dynamodb.Client, table string, requests []dynamodb.WriteRequest) {
+	items := map[string][]dynamodb.WriteRequest{table: requests}
+	for attempt := 0; len(items[table]) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * 100 * time.Millisecond)
+		}
+		response, err := client.BatchWriteItemRequest(&dynamodb.BatchWriteItemInput{RequestItems: items}).Send(ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		items = response.UnprocessedItems
+	}
+}
+
+// Close stores the remaining items and the item of the run
+func (r *DynamoDBReport) Close() {
+	r.Write()
+	r.pending[historyRunItem] = map[string]dynamodb.AttributeValue{
+		"runId":     stringValue(r.run),
+		"arn":       stringValue(historyRunItem),
+		"timestamp": stringValue(r.started.Format(time.RFC3339)),
+		"account":   stringValue(r.account),
+		"region":    stringValue(r.region),
+		"search":    stringValue(r.search),
+		"resources": numberValue(r.resources),
+		"compliant": numberValue(r.compliant),
+	}
+	r.Write()
+}
+
+func stringValue(s string) dynamodb.AttributeValue {
+	return dynamodb.AttributeValue{S: aws.String(s)}
+}
+
+func numberValue(n int) dynamodb.AttributeValue {
+	return dynamodb.AttributeValue{N: aws.String(fmt.Sprint(n))}
+}
+
+func listValue(values []string) dynamodb.AttributeValue {
+	list := []dynamodb.AttributeValue{}
+	for _, value := range values {
+		list = append(list, stringValue(value))
+	}
+	return dynamodb.AttributeValue{L: list}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+const (
+	eventSource     = "aws-tag-report"
+	eventDetailType = "TagComplianceFinding"
+)
+
+// EventBridgeReport puts an event per noncompliant resource onto an event bus, detailed
+// like the resources of the json report, for automation to react to the findings
+type EventBridgeReport struct {
+	ctx     context.Context
+	client  *eventbridge.Client
+	bus     string
+	pending []eventbridge.PutEventsRequestEntry
+}
+
+func NewEventBridgeReporter(ctx context.Context, config aws.Config, bus string) *EventBridgeReport {
+	return &EventBridgeReport{
+		ctx:    ctx,
+		client: eventbridge.New(config),
+		bus:    bus,
+	}
+}
+
+// Add puts an event when the resource misses required tags, has invalid values or
+// violates the policy
+func (r *EventBridgeReport) Add(result Result) {
+	if result.Finding == nil || (result.Finding.compliant() && len(result.Resource.Violations) == 0) {
+		return
+	}
+	arn := result.Arn
+	detail, err := json.Marshal(newJSONRecord(result))
+	if err != nil {
+		panic(err.Error())
+	}
+	entry := eventbridge.PutEventsRequestEntry{
+		EventBusName: aws.String(r.bus),
+		Source:       aws.String(eventSource),
+		DetailType:   aws.String(eventDetailType),
+		Detail:       aws.String(string(detail)),
+		Time:         aws.Time(time.Now()),
+	}
+	if arn != "" {
+		entry.Resources = []string{arn}
+	}
+	r.pending = append(r.pending, entry)
+	if len(r.pending) == 10 {
+		r.Write()
+	}
+}
+
+// Write puts the pending events, PutEvents taking up to 10 entries at a time
+func (r *EventBridgeReport) Write() {
+	for len(r.pending) > 0 {
+		n := len(r.pending)
+		if n > 10 {
+			n = 10
+		}
+		r.put(r.pending[:n])
+		r.pending = r.pending[n:]
+	}
+}
+
+// put puts the entries, retrying the failed ones
+func (r *EventBridgeReport) put(entries []eventbridge.PutEventsRequestEntry) {
+	for attempt := 0; len(entries) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * 100 * time.Millisecond)
+		}
+		response, err := r.client.PutEventsRequest(&eventbridge.PutEventsInput{Entries: entries}).Send(r.ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		var failed []eventbridge.PutEventsRequestEntry
+		var message string
+		for i, result := range response.Entries {
+			if result.ErrorCode != nil {
+				failed = append(failed, entries[i])
+				message = aws.StringValue(result.ErrorMessage)
+			}
+		}
+		if attempt == 4 && len(failed) > 0 {
+			panic(fmt.Sprintf("putting %d events onto %s failed: %s", len(failed), r.bus, message))
+		}
+		entries = failed
+	}
+}
+
+func (r *EventBridgeReport) Close() {
+	r.Write()
+}
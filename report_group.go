@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// untaggedGroup groups the resources without the tag grouped by
+const untaggedGroup = "(not tagged)"
+
+// GroupReport writes as csv the coverage rolled up by stack, resource type or the value of
+// a tag, e.g. by business unit, the least compliant groups first
+type GroupReport struct {
+	w       io.Writer
+	dialect CSVDialect
+	by      string
+	groups  stackSummaries
+}
+
+func NewGroupReporter(w io.Writer, dialect CSVDialect, by string) *GroupReport {
+	return &GroupReport{
+		w:       w,
+		dialect: dialect,
+		by:      by,
+		groups:  make(stackSummaries),
+	}
+}
+
+// group names the group of a resource
+func (r *GroupReport) group(resource Resource, tags map[string]string) string {
+	switch {
+	case r.by == "stack":
+		return resource.Stack.Name
+	case r.by == "type":
+		return resource.Type
+	default:
+		if value, ok := tags[strings.TrimPrefix(r.by, "tag:")]; ok {
+			return value
+		}
+		return untaggedGroup
+	}
+}
+
+// scope labels the groups in the report
+func (r *GroupReport) scope() string {
+	switch r.by {
+	case "stack":
+		return "Stack"
+	case "type":
+		return "Type"
+	default:
+		return "Tag: " + strings.TrimPrefix(r.by, "tag:")
+	}
+}
+
+func (r *GroupReport) Add(result Result) {
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		r.groups.get(r.group(result.Resource, nil)).errors++
+	case finding == nil:
+		r.groups.get(r.group(result.Resource, nil)).notSupported++
+	default:
+		r.groups.add(r.group(result.Resource, result.Tags), finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+}
+
+// Write is a no-op, the groups are written on Close
+func (r *GroupReport) Write() {
+}
+
+func (r *GroupReport) Close() {
+	names := r.groups.names()
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := r.groups[names[i]], r.groups[names[j]]
+		// groups without any tags looked up have no coverage and come last
+		if (a.resources == 0) != (b.resources == 0) {
+			return b.resources == 0
+		}
+		return a.averageModern() < b.averageModern()
+	})
+
+	w := newCSVWriter(r.w, r.dialect)
+	err := w.Write(summaryHeader)
+	for _, name := range names {
+		if err == nil {
+			err = w.Write(summaryRow(r.scope(), name, r.groups[name]))
+		}
+	}
+	if err == nil {
+		err = w.Write(summaryRow("Total", "", r.groups.total()))
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed templates/report.html
+var htmlTemplate string
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	// level classifies a coverage percentage for colouring
+	"level": func(coverage int) string {
+		switch {
+		case coverage < 50:
+			return "low"
+		case coverage < 80:
+			return "mid"
+		default:
+			return "high"
+		}
+	},
+}).Parse(htmlTemplate))
+
+// HTMLReport renders the resources as a single self-contained html page
+// with a sortable and filterable table and coverage charts
+type HTMLReport struct {
+	w                io.Writer
+	search           string
+	groupByConstruct bool
+	rows             []htmlRow
+	stacks           stackSummaries
+}
+
+type htmlRow struct {
+	Stack         string
+	Type          string
+	Name          string
+	ConstructPath string
+	Tags          string
+	Missing       string
+	CreatedBy     string
+	Supported     bool
+	Classic       int
+	Modern        int
+	Error         string
+}
+
+type htmlSummary struct {
+	Name         string
+	Resources    int
+	NotSupported int
+	Errors       int
+	Compliant    int
+	Classic      int
+	Modern       int
+}
+
+func NewHTMLReporter(w io.Writer, search string, groupByConstruct bool) *HTMLReport {
+	return &HTMLReport{
+		w:                w,
+		search:           search,
+		groupByConstruct: groupByConstruct,
+		stacks:           make(stackSummaries),
+	}
+}
+
+func (r *HTMLReport) Add(result Result) {
+	resource := result.Resource
+	row := htmlRow{
+		Stack:         resource.Stack.Name,
+		Type:          extractType(resource.Type),
+		Name:          resource.Name,
+		ConstructPath: resource.ConstructPath,
+		CreatedBy:     resource.Stack.Origin,
+	}
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		row.Error = result.Err.Error()
+		r.stacks.get(resource.Stack.Name).errors++
+	case finding == nil:
+		r.stacks.get(resource.Stack.Name).notSupported++
+	default:
+		row.Tags = strings.Join(finding.Present, ", ")
+		row.Missing = strings.Join(finding.Missing, ", ")
+		row.Supported = true
+		row.Classic, row.Modern = finding.ClassicCoverage, finding.ModernCoverage
+		r.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+	r.rows = append(r.rows, row)
+}
+
+// Write is a no-op, the page is rendered on Close
+func (r *HTMLReport) Write() {
+}
+
+func (r *HTMLReport) Close() {
+	if r.groupByConstruct {
+		sort.SliceStable(r.rows, func(i, j int) bool {
+			return r.rows[i].ConstructPath < r.rows[j].ConstructPath
+		})
+	}
+
+	summarize := func(name string, s *stackSummary) htmlSummary {
+		return htmlSummary{name, s.resources, s.notSupported, s.errors, s.compliant, s.averageClassic(), s.averageModern()}
+	}
+	var stacks []htmlSummary
+	for _, name := range r.stacks.names() {
+		stacks = append(stacks, summarize(name, r.stacks[name]))
+	}
+
+	err := htmlReport.Execute(r.w, struct {
+		Search    string
+		Generated string
+		Total     htmlSummary
+		Stacks    []htmlSummary
+		Rows      []htmlRow
+	}{
+		Search:    r.search,
+		Generated: time.Now().Format(time.RFC1123),
+		Total:     summarize("", r.stacks.total()),
+		Stacks:    stacks,
+		Rows:      r.rows,
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
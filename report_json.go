@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// JSONReport writes the resources as a json array of records, or as json lines
+// where each record is written out as soon as it is added
+type JSONReport struct {
+	w     *bufio.Writer
+	count int
+	// json lines may be rotated into parts of the output
+	lines  bool
+	output *Output
+	// baseline adds how each resource changed since a previous report
+	baseline *Baseline
+	// when grouping by construct the records are held until Close
+	groupByConstruct bool
+	records          []jsonRecord
+}
+
+// jsonRecord is the json representation of a single resource
+type jsonRecord struct {
+	Stack           string            `json:"stack"`
+	StackId         string            `json:"stackId"`
+	Stacks          []string          `json:"stacks,omitempty"`
+	Type            string            `json:"type"`
+	Id              string            `json:"id"`
+	Arn             string            `json:"arn"`
+	Region          string            `json:"region"`
+	Account         string            `json:"account"`
+	ConstructPath   string            `json:"constructPath,omitempty"`
+	LastUpdated     string            `json:"lastUpdated,omitempty"`
+	StackCreated    string            `json:"stackCreated,omitempty"`
+	StackUpdated    string            `json:"stackLastUpdated,omitempty"`
+	CreatedBy       string            `json:"createdBy"`
+	Supported       bool              `json:"supported"`
+	Tags            map[string]string `json:"tags"`
+	MissingTags     []string          `json:"missingTags"`
+	InvalidTags     []string          `json:"invalidTags,omitempty"`
+	CaseMismatches  []string          `json:"caseMismatches,omitempty"`
+	Aliases         []string          `json:"aliases,omitempty"`
+	Violations      []string          `json:"violations,omitempty"`
+	Exemption       string            `json:"exemption,omitempty"`
+	ClassicCoverage *int              `json:"classicCoverage"`
+	ModernCoverage  *int              `json:"modernCoverage"`
+	Coverage        map[string]int    `json:"coverage,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Change          string            `json:"change,omitempty"`
+	MonthlyCost     *float64          `json:"monthlyCost,omitempty"`
+	DriftStatus     string            `json:"driftStatus,omitempty"`
+}
+
+func NewJSONReporter(w io.Writer, groupByConstruct bool, baseline *Baseline) *JSONReport {
+	return &JSONReport{
+		w:                bufio.NewWriter(w),
+		baseline:         baseline,
+		groupByConstruct: groupByConstruct,
+	}
+}
+
+func NewJSONLinesReporter(output *Output, groupByConstruct bool, baseline *Baseline) *JSONReport {
+	return &JSONReport{
+		w:                bufio.NewWriter(output.Open()),
+		baseline:         baseline,
+		lines:            true,
+		output:           output,
+		groupByConstruct: groupByConstruct,
+	}
+}
+
+func (r *JSONReport) Add(result Result) {
+	r.write(newJSONRecord(result))
+}
+
+// newJSONRecord is the record of a result
+func newJSONRecord(result Result) jsonRecord {
+	resource := result.Resource
+	record := jsonRecord{
+		Stack:         resource.Stack.Name,
+		StackId:       resource.Stack.Id,
+		Stacks:        resource.Stacks,
+		Type:          resource.Type,
+		Id:            resource.Name,
+		Arn:           result.Arn,
+		Region:        result.Region,
+		Account:       result.Account,
+		ConstructPath: resource.ConstructPath,
+		LastUpdated:   formatTime(resource.LastUpdated),
+		StackCreated:  formatTime(resource.Stack.CreationTime),
+		StackUpdated:  formatTime(resource.Stack.LastUpdatedTime),
+		CreatedBy:     resource.Stack.Origin,
+		Supported:     result.Supported,
+		MonthlyCost:   resource.MonthlyCost,
+		DriftStatus:   resource.DriftStatus,
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	if finding := result.Finding; finding != nil {
+		classicCoverage, modernCoverage := finding.ClassicCoverage, finding.ModernCoverage
+		record.Tags = result.Tags
+		record.MissingTags = finding.Missing
+		if record.MissingTags == nil {
+			record.MissingTags = []string{}
+		}
+		record.InvalidTags = finding.Invalid
+		record.CaseMismatches = finding.CaseMismatches
+		record.Aliases = resource.Aliases
+		record.Violations = resource.Violations
+		record.Exemption = resource.Exemption
+		record.ClassicCoverage = &classicCoverage
+		record.ModernCoverage = &modernCoverage
+		record.Coverage = finding.Coverage
+	}
+	return record
+}
+
+func (r *JSONReport) write(record jsonRecord) {
+	if r.baseline != nil && record.Change == "" {
+		record.Change = r.baseline.change(baselineKey(record.Arn, record.Stack, record.Id), record.ModernCoverage)
+	}
+	if r.groupByConstruct {
+		r.records = append(r.records, record)
+		return
+	}
+	if r.lines {
+		r.writeLine(record)
+		return
+	}
+
+	separator := ",\n  "
+	if r.count == 0 {
+		separator = "[\n  "
+	}
+	body, err := json.MarshalIndent(record, "  ", "  ")
+	if err != nil {
+		panic(err.Error())
+	}
+	r.count++
+	_, err = r.w.WriteString(separator)
+	if err == nil {
+		_, err = r.w.Write(body)
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *JSONReport) writeLine(record jsonRecord) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		panic(err.Error())
+	}
+	if r.output.RotateRows > 0 && r.count > 0 && r.count%r.output.RotateRows == 0 {
+		r.Write()
+		r.w = bufio.NewWriter(r.output.Open())
+	}
+	r.count++
+	_, err = r.w.Write(append(body, '\n'))
+	if err != nil {
+		panic(err.Error())
+	}
+	r.Write()
+}
+
+// Close writes any held records, grouped by construct path, and terminates the array
+func (r *JSONReport) Close() {
+	if r.groupByConstruct {
+		sort.SliceStable(r.records, func(i, j int) bool {
+			return r.records[i].ConstructPath < r.records[j].ConstructPath
+		})
+		r.groupByConstruct = false
+		for _, record := range r.records {
+			r.write(record)
+		}
+		r.records = nil
+	}
+	if r.lines {
+		r.Write()
+		return
+	}
+
+	end := "\n]\n"
+	if r.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := r.w.WriteString(end); err != nil {
+		panic(err.Error())
+	}
+	r.Write()
+}
+
+func (r *JSONReport) Write() {
+	err := r.w.Flush()
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JUnitReport writes a junit xml document with a test suite per stack and a test case
+// per resource, failing when required tags are missing, so CI servers can gate on it
+type JUnitReport struct {
+	w      io.Writer
+	search string
+	suites map[string]*junitSuite
+	order  []string
+}
+
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Name     string        `xml:"name,attr"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Errors   int           `xml:"errors,attr"`
+	Skipped  int           `xml:"skipped,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func NewJUnitReporter(w io.Writer, search string) *JUnitReport {
+	return &JUnitReport{
+		w:      w,
+		search: search,
+		suites: make(map[string]*junitSuite),
+	}
+}
+
+func (r *JUnitReport) Add(result Result) {
+	resource := result.Resource
+	test := r.testCase(resource.Type, resource.Name, resource.ConstructPath)
+	suite := r.suite(resource.Stack.Name)
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		test.Error = &junitMessage{Message: "tags lookup failed", Body: result.Err.Error()}
+		suite.Errors++
+	case finding == nil:
+		test.Skipped = &junitMessage{Message: resource.Type + " tags not supported"}
+		suite.Skipped++
+	case len(finding.Missing) > 0 || len(finding.Invalid) > 0 || len(resource.Violations) > 0:
+		message := fmt.Sprintf("missing %d required tags", len(finding.Missing))
+		if len(finding.Invalid) > 0 {
+			message += fmt.Sprintf(", %d with an invalid value", len(finding.Invalid))
+		}
+		if len(resource.Violations) > 0 {
+			message += fmt.Sprintf(", %d policy violations", len(resource.Violations))
+		}
+		test.Failure = &junitMessage{
+			Message: message,
+			Body: fmt.Sprintf("missing tags: %s\ninvalid tags: %s\npolicy violations: %s\nclassic coverage: %d%%\nmodern coverage: %d%%",
+				strings.Join(finding.Missing, ", "), strings.Join(finding.Invalid, ", "), strings.Join(resource.Violations, "; "),
+				finding.ClassicCoverage, finding.ModernCoverage),
+		}
+		suite.Failures++
+	}
+	suite.add(test)
+}
+
+func (r *JUnitReport) testCase(resourceType string, name string, constructPath string) junitCase {
+	if constructPath != "" {
+		name = fmt.Sprintf("%s (%s)", name, constructPath)
+	}
+	return junitCase{Name: name, ClassName: resourceType}
+}
+
+func (r *JUnitReport) suite(stack string) *junitSuite {
+	suite, ok := r.suites[stack]
+	if !ok {
+		suite = &junitSuite{Name: stack}
+		r.suites[stack] = suite
+		r.order = append(r.order, stack)
+	}
+	return suite
+}
+
+func (s *junitSuite) add(test junitCase) {
+	s.Tests++
+	s.Cases = append(s.Cases, test)
+}
+
+// Write is a no-op, the document is written on Close
+func (r *JUnitReport) Write() {
+}
+
+func (r *JUnitReport) Close() {
+	document := junitSuites{Name: r.search}
+	for _, stack := range r.order {
+		suite := r.suites[stack]
+		document.Tests += suite.Tests
+		document.Failures += suite.Failures
+		document.Errors += suite.Errors
+		document.Skipped += suite.Skipped
+		document.Suites = append(document.Suites, suite)
+	}
+
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err == nil {
+		_, err = fmt.Fprintf(r.w, "%s%s\n", xml.Header, body)
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MarkdownReport writes a compact markdown table of the resources followed by
+// the coverage totals, meant to be pasted into pull requests or wiki pages
+type MarkdownReport struct {
+	w                *bufio.Writer
+	search           string
+	groupByConstruct bool
+	rows             []markdownRow
+	stacks           stackSummaries
+}
+
+type markdownRow struct {
+	constructPath string
+	cells         []string
+}
+
+func NewMarkdownReporter(w io.Writer, search string, groupByConstruct bool) *MarkdownReport {
+	return &MarkdownReport{
+		w:                bufio.NewWriter(w),
+		search:           search,
+		groupByConstruct: groupByConstruct,
+		stacks:           make(stackSummaries),
+	}
+}
+
+func (r *MarkdownReport) Add(result Result) {
+	resource := result.Resource
+	var cells []string
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		cells = []string{"error: " + result.Err.Error(), "N/A", "N/A"}
+		r.stacks.get(resource.Stack.Name).errors++
+	case finding == nil:
+		cells = []string{"", "N/A", "N/A"}
+		r.stacks.get(resource.Stack.Name).notSupported++
+	default:
+		cells = []string{
+			strings.Join(finding.Missing, ", "),
+			fmt.Sprintf("%d%%", finding.ClassicCoverage),
+			fmt.Sprintf("%d%%", finding.ModernCoverage),
+		}
+		r.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+	r.rows = append(r.rows, markdownRow{resource.ConstructPath,
+		append([]string{resource.Stack.Name, extractType(resource.Type), resource.Name}, cells...)})
+}
+
+// Write is a no-op, the totals are only known on Close
+func (r *MarkdownReport) Write() {
+}
+
+func (r *MarkdownReport) Close() {
+	if r.groupByConstruct {
+		sort.SliceStable(r.rows, func(i, j int) bool {
+			return r.rows[i].constructPath < r.rows[j].constructPath
+		})
+	}
+
+	total := r.stacks.total()
+	fmt.Fprintf(r.w, "## Tag coverage: %s\n\n", markdownEscape(r.search))
+	fmt.Fprintf(r.w, "| Resources | Compliant | Not Supported | Errors | Classic Coverage | Modern Coverage |\n")
+	fmt.Fprintf(r.w, "|---:|---:|---:|---:|---:|---:|\n")
+	fmt.Fprintf(r.w, "| %d | %d | %d | %d | %d%% | %d%% |\n\n", total.resources, total.compliant,
+		total.notSupported, total.errors, total.averageClassic(), total.averageModern())
+
+	fmt.Fprintf(r.w, "| Stack | Type | Resource Name | Missing Tags | Classic | Modern |\n")
+	fmt.Fprintf(r.w, "|---|---|---|---|---:|---:|\n")
+	for _, row := range r.rows {
+		cells := make([]string, len(row.cells))
+		for i, cell := range row.cells {
+			cells[i] = markdownEscape(cell)
+		}
+		fmt.Fprintf(r.w, "| %s |\n", strings.Join(cells, " | "))
+	}
+
+	err := r.w.Flush()
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// markdownEscape keeps a value within its table cell
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(s)
+}
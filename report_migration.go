@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// classicToModern maps the classic tag keys to their modern equivalent, from the tag policy
+var classicToModern map[string]string
+
+// MigrationReport writes as csv a migration worksheet: for each resource missing modern
+// keys, the exact tags to add carried over from its classic keys, and the modern keys
+// left without a classic source which need a value from the owning team
+type MigrationReport struct {
+	w *csv.Writer
+}
+
+func NewMigrationReporter(w io.Writer, dialect CSVDialect) *MigrationReport {
+	report := &MigrationReport{
+		w: newCSVWriter(w, dialect),
+	}
+	err := report.w.Write([]string{"Type", "Resource Name", "ARN", "Stack Name", "Tags To Add", "Still Missing"})
+	if err != nil {
+		panic(err.Error())
+	}
+	return report
+}
+
+func (r *MigrationReport) Add(result Result) {
+	// resources without tags need no migration, and those whose tags are unknown cannot be
+	// migrated
+	if result.Finding == nil {
+		return
+	}
+	resource, tags, finding := result.Resource, result.Tags, result.Finding
+	if len(finding.Missing) == 0 {
+		return
+	}
+
+	add := make(map[string]string)
+	for classicKey, modernKey := range classicToModern {
+		if value, ok := lookupTag(tags, classicKey); ok && containsString(finding.Missing, modernKey) {
+			add[modernKey] = value
+		}
+	}
+	var missing []string
+	for _, key := range finding.Missing {
+		if _, ok := add[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	encoded, err := json.Marshal(add)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	err = r.w.Write([]string{
+		extractType(resource.Type),
+		resource.Name,
+		result.Arn,
+		resource.Stack.Name,
+		string(encoded),
+		strings.Join(missing, ","),
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *MigrationReport) Write() {
+	r.w.Flush()
+	err := r.w.Error()
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *MigrationReport) Close() {
+	r.Write()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// costAllocation maps the tag keys known to billing to their cost allocation status,
+// nil unless requested
+var costAllocation map[string]string
+
+// costAllocationStatus tells whether a tag key is activated for cost allocation, a key
+// billing never saw being Not Found
+func costAllocationStatus(key string) string {
+	if status, ok := costAllocation[key]; ok {
+		return status
+	}
+	return "Not Found"
+}
+
+// MissingTagsReport writes as csv how many of the resources whose tags were looked up
+// miss each required tag key, the biggest gaps first, to target remediation
+type MissingTagsReport struct {
+	w         io.Writer
+	dialect   CSVDialect
+	resources int
+	missing   map[string]int
+}
+
+func NewMissingTagsReporter(w io.Writer, dialect CSVDialect) *MissingTagsReport {
+	return &MissingTagsReport{
+		w:       w,
+		dialect: dialect,
+		missing: make(map[string]int),
+	}
+}
+
+func (r *MissingTagsReport) Add(result Result) {
+	// resources without tags cannot miss any, and those whose tags are unknown are not counted
+	if result.Finding == nil {
+		return
+	}
+	finding := result.Finding
+	r.resources++
+	for _, key := range finding.Missing {
+		r.missing[key]++
+	}
+}
+
+// Write is a no-op, the counts are written on Close
+func (r *MissingTagsReport) Write() {
+}
+
+func (r *MissingTagsReport) Close() {
+	keys := modern.allKeys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return r.missing[keys[i]] > r.missing[keys[j]]
+	})
+
+	w := newCSVWriter(r.w, r.dialect)
+	header := []string{"Tag", "Missing Resources", "Missing Percentage"}
+	if costAllocation != nil {
+		header = append(header, "Cost Allocation")
+	}
+	err := w.Write(header)
+	for _, key := range keys {
+		if err != nil {
+			break
+		}
+		percentage := "N/A"
+		if r.resources > 0 {
+			percentage = fmt.Sprintf("%d%%", 100*r.missing[key]/r.resources)
+		}
+		row := []string{key, fmt.Sprint(r.missing[key]), percentage}
+		if costAllocation != nil {
+			row = append(row, costAllocationStatus(key))
+		}
+		err = w.Write(row)
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
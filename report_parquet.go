@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParquetReport writes the resources as a parquet file, either to w or, when dir is set,
+// into a hive style account=/region=/date= partition below dir ready for Athena
+type ParquetReport struct {
+	w       io.Writer
+	dir     string
+	account string
+	region  string
+	search  string
+	date    string
+	columns []*parquetColumn
+}
+
+const (
+	parquetStack = iota
+	parquetStackId
+	parquetType
+	parquetId
+	parquetArn
+	parquetConstructPath
+	parquetLastUpdated
+	parquetStackCreated
+	parquetStackUpdated
+	parquetCreatedBy
+	parquetStatus
+	parquetTags
+	parquetMissingTags
+	parquetClassicCoverage
+	parquetModernCoverage
+	parquetError
+	parquetAccount
+	parquetRegion
+	parquetDate
+)
+
+func NewParquetReporter(w io.Writer, dir string, account string, region string, search string) *ParquetReport {
+	return &ParquetReport{
+		w:       w,
+		dir:     dir,
+		account: account,
+		region:  region,
+		search:  search,
+		date:    time.Now().UTC().Format("2006-01-02"),
+		columns: newParquetColumns(),
+	}
+}
+
+// newParquetColumns returns the empty columns of the parquet report
+func newParquetColumns() []*parquetColumn {
+	return []*parquetColumn{
+		newStringColumn("stack"),
+		newStringColumn("stack_id"),
+		newStringColumn("type"),
+		newStringColumn("id"),
+		newStringColumn("arn"),
+		newStringColumn("construct_path"),
+		newStringColumn("last_updated"),
+		newStringColumn("stack_created"),
+		newStringColumn("stack_last_updated"),
+		newStringColumn("created_by"),
+		newStringColumn("status"),
+		newStringColumn("tags"),
+		newStringColumn("missing_tags"),
+		newInt32Column("classic_coverage", true),
+		newInt32Column("modern_coverage", true),
+		newStringColumn("error"),
+		newStringColumn("account"),
+		newStringColumn("region"),
+		newStringColumn("scan_date"),
+	}
+}
+
+func (r *ParquetReport) Add(result Result) {
+	if result.Err != nil {
+		status := "ERROR"
+		if errorStatus(result.Err) != "" {
+			status = errorStatus(result.Err)
+		}
+		r.add(result, status)
+		r.set(parquetError, result.Err.Error())
+		return
+	}
+	if result.Finding == nil {
+		r.add(result, "NOT_SUPPORTED")
+		return
+	}
+	tags, finding := result.Tags, result.Finding
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		panic(err.Error())
+	}
+	r.add(result, "OK")
+	r.set(parquetTags, string(encoded))
+	r.set(parquetMissingTags, strings.Join(finding.Missing, ","))
+	r.set(parquetClassicCoverage, finding.ClassicCoverage)
+	r.set(parquetModernCoverage, finding.ModernCoverage)
+}
+
+// add appends a row with the common columns, the remaining ones defaulting to empty or null
+func (r *ParquetReport) add(result Result, status string) {
+	resource := result.Resource
+	row := map[int]interface{}{
+		parquetStack:         resource.Stack.Name,
+		parquetStackId:       resource.Stack.Id,
+		parquetType:          resource.Type,
+		parquetId:            resource.Name,
+		parquetArn:           result.Arn,
+		parquetConstructPath: resource.ConstructPath,
+		parquetLastUpdated:   formatTime(resource.LastUpdated),
+		parquetStackCreated:  formatTime(resource.Stack.CreationTime),
+		parquetStackUpdated:  formatTime(resource.Stack.LastUpdatedTime),
+		parquetCreatedBy:     resource.Stack.Origin,
+		parquetStatus:        status,
+		parquetAccount:       result.Account,
+		parquetRegion:        result.Region,
+		parquetDate:          r.date,
+	}
+	for i, column := range r.columns {
+		value, ok := row[i]
+		if !ok && !column.optional {
+			value = ""
+		}
+		column.values = append(column.values, value)
+	}
+}
+
+// set replaces the value of the last row
+func (r *ParquetReport) set(column int, value interface{}) {
+	values := r.columns[column].values
+	values[len(values)-1] = value
+}
+
+// Write is a no-op, a parquet file is written as a whole on Close
+func (r *ParquetReport) Write() {
+}
+
+func (r *ParquetReport) Close() {
+	w := r.w
+	if r.dir != "" {
+		dir := filepath.Join(r.dir, "account="+r.account, "region="+r.region, "date="+r.date)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			panic(err.Error())
+		}
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%s.parquet", sanitizeFileName(r.search))))
+		if err != nil {
+			panic(err.Error())
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err := writeParquet(w, r.columns)
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// sanitizeFileName replaces the characters not safe for file names
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// the findings of the propagation report
+const (
+	// propagationMismatch is a resource carrying another value than its stack, likely edited by hand
+	propagationMismatch = "MISMATCH"
+	// propagationMissing is a resource missing a stack tag while carrying others, likely drifted
+	propagationMissing = "MISSING"
+	// propagationNeverTagged is a resource carrying none of the stack tags, never propagated to
+	propagationNeverTagged = "NEVER_TAGGED"
+)
+
+// PropagationReport writes as csv the stack tags each resource lacks or carries another
+// value of, cloudformation propagating the tags of a stack to its resources. A resource
+// carrying some of the stack tags but not all, or other values, likely drifted or was edited
+// by hand, while one carrying none was never tagged through its stack
+type PropagationReport struct {
+	w *csv.Writer
+}
+
+func NewPropagationReporter(w io.Writer, dialect CSVDialect) *PropagationReport {
+	report := &PropagationReport{
+		w: newCSVWriter(w, dialect),
+	}
+	err := report.w.Write([]string{"Type", "Resource Name", "ARN", "Stack Name", "Tag", "Stack Value", "Resource Value", "Finding"})
+	if err != nil {
+		panic(err.Error())
+	}
+	return report
+}
+
+func (r *PropagationReport) Add(result Result) {
+	// resources without tags cannot carry the stack tags, and those whose tags are unknown
+	// are left out
+	if result.Finding == nil {
+		return
+	}
+	resource, tags := result.Resource, result.Tags
+	var keys []string
+	carried := 0
+	for _, key := range sortedTagKeys(resource.Stack.Tags) {
+		if strings.HasPrefix(key, "aws:") {
+			// reserved keys cloudformation sets itself
+			continue
+		}
+		keys = append(keys, key)
+		if _, ok := tags[key]; ok {
+			carried++
+		}
+	}
+
+	for _, key := range keys {
+		stackValue := resource.Stack.Tags[key]
+		value, ok := tags[key]
+		finding := propagationMismatch
+		if ok && value == stackValue {
+			continue
+		} else if !ok && carried > 0 {
+			finding = propagationMissing
+		} else if !ok {
+			finding = propagationNeverTagged
+		}
+		err := r.w.Write([]string{
+			extractType(resource.Type),
+			resource.Name,
+			result.Arn,
+			resource.Stack.Name,
+			key,
+			stackValue,
+			value,
+			finding,
+		})
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+func (r *PropagationReport) Write() {
+	r.w.Flush()
+	err := r.w.Error()
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func (r *PropagationReport) Close() {
+	r.Write()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SARIFReport writes a SARIF 2.1.0 log with a result for each missing tag, invalid tag
+// value and policy violation, two rules per required tag key, for code scanning dashboards
+type SARIFReport struct {
+	w             io.Writer
+	results       []sarifResult
+	notifications []sarifNotification
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func NewSARIFReporter(w io.Writer) *SARIFReport {
+	return &SARIFReport{w: w}
+}
+
+// sarifRuleId is the rule reporting a missing tag key
+func sarifRuleId(key string) string {
+	return "missing-tag/" + key
+}
+
+// sarifViolationRuleId is the rule reporting the violations of the rego policy
+const sarifViolationRuleId = "policy-violation"
+
+// sarifInvalidRuleId is the rule reporting a tag key with an invalid value
+func sarifInvalidRuleId(key string) string {
+	return "invalid-tag/" + key
+}
+
+func (r *SARIFReport) Add(result Result) {
+	resource := result.Resource
+	if result.Err != nil {
+		r.notifications = append(r.notifications, sarifNotification{
+			Level:     "warning",
+			Message:   sarifMessage{fmt.Sprintf("unable to lookup the tags of %s %s: %s", resource.Type, resource.Name, result.Err.Error())},
+			Locations: sarifLocations(resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name),
+		})
+		return
+	}
+	// resources without tags produce no findings
+	if result.Finding == nil {
+		return
+	}
+	tags, finding := result.Tags, result.Finding
+	for _, key := range finding.Missing {
+		r.results = append(r.results, sarifResult{
+			RuleId:    sarifRuleId(key),
+			RuleIndex: indexOf(modern.allKeys(), key),
+			Level:     "error",
+			Message:   sarifMessage{fmt.Sprintf("%s %s is missing the required tag %s", resource.Type, resource.Name, key)},
+			Locations: sarifLocations(resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name),
+		})
+	}
+	for _, key := range finding.Invalid {
+		value, _ := lookupTag(tags, key)
+		r.results = append(r.results, sarifResult{
+			RuleId:    sarifInvalidRuleId(key),
+			RuleIndex: len(modern.allKeys()) + indexOf(modern.allKeys(), key),
+			Level:     "error",
+			Message:   sarifMessage{fmt.Sprintf("%s %s has an invalid value %q for the tag %s", resource.Type, resource.Name, value, key)},
+			Locations: sarifLocations(resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name),
+		})
+	}
+	for _, violation := range resource.Violations {
+		r.results = append(r.results, sarifResult{
+			RuleId:    sarifViolationRuleId,
+			RuleIndex: 2 * len(modern.allKeys()),
+			Level:     "error",
+			Message:   sarifMessage{fmt.Sprintf("%s %s: %s", resource.Type, resource.Name, violation)},
+			Locations: sarifLocations(resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name),
+		})
+	}
+}
+
+// sarifLocations locates a resource within its stack, there is no source file so the
+// stack stands in as the artifact
+func sarifLocations(resourceType string, name string, constructPath string, stack string) []sarifLocation {
+	qualified := stack + "/" + name
+	if constructPath != "" {
+		qualified = constructPath
+	}
+	return []sarifLocation{{
+		PhysicalLocation: sarifPhysicalLocation{sarifArtifactLocation{Uri: stack, UriBaseId: "STACK"}},
+		LogicalLocations: []sarifLogicalLocation{{Name: name, FullyQualifiedName: qualified, Kind: resourceType}},
+	}}
+}
+
+// Write is a no-op, the log is written on Close
+func (r *SARIFReport) Write() {
+}
+
+func (r *SARIFReport) Close() {
+	var rules []sarifRule
+	for _, key := range modern.allKeys() {
+		rules = append(rules, sarifRule{
+			Id:               sarifRuleId(key),
+			Name:             "MissingTag",
+			ShortDescription: sarifMessage{fmt.Sprintf("Resources must be tagged with %s", key)},
+		})
+	}
+	for _, key := range modern.allKeys() {
+		rules = append(rules, sarifRule{
+			Id:               sarifInvalidRuleId(key),
+			Name:             "InvalidTagValue",
+			ShortDescription: sarifMessage{fmt.Sprintf("The values of %s must follow the tag policy", key)},
+		})
+	}
+	rules = append(rules, sarifRule{
+		Id:               sarifViolationRuleId,
+		Name:             "PolicyViolation",
+		ShortDescription: sarifMessage{"Resources must satisfy the rego policy"},
+	})
+	results, notifications := r.results, r.notifications
+	if results == nil {
+		results = []sarifResult{}
+	}
+	if notifications == nil {
+		notifications = []sarifNotification{}
+	}
+
+	encoder := json.NewEncoder(r.w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{sarifDriver{
+				Name:           "aws-tag-report",
+				InformationUri: "https://github.com/kasvela/aws-tag-report",
+				Rules:          rules,
+			}},
+			Invocations: []sarifInvocation{{ExecutionSuccessful: true, ToolExecutionNotifications: notifications}},
+			Results:     results,
+		}},
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func indexOf(col []string, want string) int {
+	for i, s := range col {
+		if s == want {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+)
+
+// securityHubType classifies the findings in the ASFF types taxonomy
+const securityHubType = "Software and Configuration Checks/AWS Security Best Practices/Tagging"
+
+// SecurityHubReport imports the tag compliance of each resource into Security Hub as an
+// AWS Security Finding Format finding, failed when the resource misses required tags,
+// has invalid values or violates the policy, passed otherwise so that Security Hub
+// resolves the findings of the resources since fixed
+type SecurityHubReport struct {
+	ctx        context.Context
+	client     *securityhub.Client
+	partition  string
+	productArn string
+	now        string
+	pending    []securityhub.AwsSecurityFinding
+}
+
+func NewSecurityHubReporter(ctx context.Context, config aws.Config, partition string, account string, region string) *SecurityHubReport {
+	return &SecurityHubReport{
+		ctx:        ctx,
+		client:     securityhub.New(config),
+		partition:  partition,
+		productArn: fmt.Sprintf("arn:%s:securityhub:%s:%s:product/%s/default", partition, region, account, account),
+		now:        time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (r *SecurityHubReport) Add(result Result) {
+	// the resources not supporting tags cannot be tagged, and the compliance of those whose
+	// tags are unknown is unknown
+	if result.Finding == nil {
+		return
+	}
+	resource, tags, finding := result.Resource, result.Tags, result.Finding
+	arn := result.Arn
+	// findings are identified by the ARN of their resource
+	if arn == "" {
+		return
+	}
+	invalid := finding.Invalid
+
+	var problems []string
+	if len(finding.Missing) > 0 {
+		problems = append(problems, "misses the required tags "+strings.Join(finding.Missing, ", "))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, "has invalid values for the tags "+strings.Join(invalid, ", "))
+	}
+	problems = append(problems, resource.Violations...)
+
+	status := securityhub.ComplianceStatusPassed
+	severity := securityhub.SeverityLabelInformational
+	description := fmt.Sprintf("%s %s of stack %s is tagged as required.", resource.Type, resource.Name, resource.Stack.Name)
+	if len(problems) > 0 {
+		status = securityhub.ComplianceStatusFailed
+		severity = securityhub.SeverityLabelLow
+		description = fmt.Sprintf("%s %s of stack %s %s.", resource.Type, resource.Name, resource.Stack.Name, strings.Join(problems, "; "))
+	}
+	// descriptions are limited to 1024 characters
+	if len(description) > 1024 {
+		description = description[:1021] + "..."
+	}
+
+	r.pending = append(r.pending, securityhub.AwsSecurityFinding{
+		SchemaVersion: aws.String("2018-10-08"),
+		Id:            aws.String(arn + "/tag-compliance"),
+		ProductArn:    aws.String(r.productArn),
+		GeneratorId:   aws.String(eventSource),
+		AwsAccountId:  aws.String(result.Account),
+		Types:         []string{securityHubType},
+		CreatedAt:     aws.String(r.now),
+		UpdatedAt:     aws.String(r.now),
+		Severity:      &securityhub.Severity{Label: severity},
+		Title:         aws.String("Resources should be tagged as required by the tag policy"),
+		Description:   aws.String(description),
+		Compliance:    &securityhub.Compliance{Status: status},
+		RecordState:   securityhub.RecordStateActive,
+		Remediation: &securityhub.Remediation{Recommendation: &securityhub.Recommendation{
+			Text: aws.String(fmt.Sprintf("Tag the resource in the template of stack %s and update the stack.", resource.Stack.Name)),
+		}},
+		ProductFields: map[string]string{
+			"aws-tag-report/Stack":          resource.Stack.Name,
+			"aws-tag-report/ModernCoverage": fmt.Sprint(finding.ModernCoverage),
+		},
+		Resources: []securityhub.Resource{{
+			Type:      aws.String(securityHubResourceType(resource.Type)),
+			Id:        aws.String(arn),
+			Partition: securityhub.Partition(r.partition),
+			Region:    aws.String(result.Region),
+			Tags:      tags,
+		}},
+	})
+	if len(r.pending) == 100 {
+		r.Write()
+	}
+}
+
+// Write imports the pending findings, BatchImportFindings taking up to 100 at a time
+func (r *SecurityHubReport) Write() {
+	for len(r.pending) > 0 {
+		n := len(r.pending)
+		if n > 100 {
+			n = 100
+		}
+		response, err := r.client.BatchImportFindingsRequest(&securityhub.BatchImportFindingsInput{
+			Findings: r.pending[:n],
+		}).Send(r.ctx)
+		if err != nil {
+			panic(err.Error())
+		}
+		if len(response.FailedFindings) > 0 {
+			failed := response.FailedFindings[0]
+			panic(fmt.Sprintf("importing %d findings into security hub failed, %s: %s: %s", len(response.FailedFindings),
+				aws.StringValue(failed.Id), aws.StringValue(failed.ErrorCode), aws.StringValue(failed.ErrorMessage)))
+		}
+		r.pending = r.pending[n:]
+	}
+}
+
+func (r *SecurityHubReport) Close() {
+	r.Write()
+}
+
+// securityHubResourceType names a CloudFormation resource type after the ASFF resource
+// types, e.g. AWS::S3::Bucket is AwsS3Bucket
+func securityHubResourceType(resourceType string) string {
+	if !strings.HasPrefix(resourceType, "AWS::") {
+		return "Other"
+	}
+	return "Aws" + strings.Replace(strings.TrimPrefix(resourceType, "AWS::"), "::", "", -1)
+}
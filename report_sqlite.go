@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteReport stores the resources in a sqlite database, every run is appended
+// so results can be queried and joined across runs
+type SQLiteReport struct {
+	db  *sql.DB
+	tx  *sql.Tx
+	run int64
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	started TEXT NOT NULL,
+	search TEXT NOT NULL,
+	account TEXT NOT NULL,
+	region TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS resources (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	stack TEXT NOT NULL,
+	type TEXT NOT NULL,
+	physical_id TEXT NOT NULL,
+	construct_path TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	status TEXT NOT NULL,
+	missing_tags TEXT,
+	classic_coverage INTEGER,
+	modern_coverage INTEGER,
+	error TEXT
+);
+CREATE TABLE IF NOT EXISTS tags (
+	resource_id INTEGER NOT NULL REFERENCES resources(id),
+	key TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (resource_id, key)
+);
+CREATE INDEX IF NOT EXISTS resources_run ON resources(run_id);
+CREATE INDEX IF NOT EXISTS tags_key ON tags(key, value);
+`
+
+func NewSQLiteReporter(path string, account string, region string, search string) *SQLiteReport {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		panic(err.Error())
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		panic(err.Error())
+	}
+	result, err := db.Exec("INSERT INTO runs (started, search, account, region) VALUES (?, ?, ?, ?)",
+		time.Now().UTC().Format(time.RFC3339), search, account, region)
+	if err != nil {
+		panic(err.Error())
+	}
+	run, err := result.LastInsertId()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	report := &SQLiteReport{db: db, run: run}
+	report.begin()
+	return report
+}
+
+func (r *SQLiteReport) Add(result Result) {
+	resource := result.Resource
+	if result.Err != nil {
+		status := "ERROR"
+		if errorStatus(result.Err) != "" {
+			status = errorStatus(result.Err)
+		}
+		r.insert(resource, result.Search, status, nil, nil, nil, result.Err.Error())
+		return
+	}
+	if result.Finding == nil {
+		r.insert(resource, result.Search, "NOT_SUPPORTED", nil, nil, nil, nil)
+		return
+	}
+	tags, finding := result.Tags, result.Finding
+	id := r.insert(resource, result.Search, "OK",
+		strings.Join(finding.Missing, ","), finding.ClassicCoverage, finding.ModernCoverage, nil)
+	for key, value := range tags {
+		_, err := r.tx.Exec("INSERT INTO tags (resource_id, key, value) VALUES (?, ?, ?)", id, key, value)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+func (r *SQLiteReport) insert(resource Resource, search string, status string,
+	missing interface{}, classicCoverage interface{}, modernCoverage interface{}, lookupError interface{}) int64 {
+	result, err := r.tx.Exec("INSERT INTO resources (run_id, stack, type, physical_id, construct_path, created_by, status, "+
+		"missing_tags, classic_coverage, modern_coverage, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.run, resource.Stack.Name, resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Origin, status,
+		missing, classicCoverage, modernCoverage, lookupError)
+	if err != nil {
+		panic(err.Error())
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		panic(err.Error())
+	}
+	return id
+}
+
+func (r *SQLiteReport) begin() {
+	tx, err := r.db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	r.tx = tx
+}
+
+// Write commits the resources added so far
+func (r *SQLiteReport) Write() {
+	if err := r.tx.Commit(); err != nil {
+		panic(err.Error())
+	}
+	r.begin()
+}
+
+func (r *SQLiteReport) Close() {
+	if err := r.tx.Commit(); err != nil {
+		panic(err.Error())
+	}
+	if err := r.db.Close(); err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// SummaryReport writes only the rollup of the resources as csv: a row per stack and per
+// resource type with the resource counts, the average coverage and the compliant resources,
+// followed by the total. The rollup is complete, and written, on Close
+type SummaryReport struct {
+	w       io.Writer
+	dialect CSVDialect
+	stacks  stackSummaries
+	types   stackSummaries
+}
+
+var summaryHeader = []string{"Scope", "Name", "Resources", "Not Supported", "Errors",
+	"Classic Coverage", "Modern Coverage", "Compliant Resources"}
+
+func NewSummaryReporter(w io.Writer, dialect CSVDialect) *SummaryReport {
+	return &SummaryReport{
+		w:       w,
+		dialect: dialect,
+		stacks:  make(stackSummaries),
+		types:   make(stackSummaries),
+	}
+}
+
+func (r *SummaryReport) Add(result Result) {
+	resource := result.Resource
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		r.stacks.get(resource.Stack.Name).errors++
+		r.types.get(resource.Type).errors++
+	case finding == nil:
+		r.stacks.get(resource.Stack.Name).notSupported++
+		r.types.get(resource.Type).notSupported++
+	default:
+		r.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+		r.types.add(resource.Type, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+}
+
+// Write is a no-op, the rollup is written on Close
+func (r *SummaryReport) Write() {
+}
+
+func (r *SummaryReport) Close() {
+	w := newCSVWriter(r.w, r.dialect)
+	err := w.Write(summaryHeader)
+	for _, name := range r.stacks.names() {
+		if err == nil {
+			err = w.Write(summaryRow("Stack", name, r.stacks[name]))
+		}
+	}
+	for _, name := range r.types.names() {
+		if err == nil {
+			err = w.Write(summaryRow("Type", name, r.types[name]))
+		}
+	}
+	if err == nil {
+		err = w.Write(summaryRow("Total", "", r.stacks.total()))
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+func summaryRow(scope string, name string, summary *stackSummary) []string {
+	classicCoverage, modernCoverage := "N/A", "N/A"
+	if summary.resources > 0 {
+		classicCoverage = fmt.Sprintf("%d%%", summary.averageClassic())
+		modernCoverage = fmt.Sprintf("%d%%", summary.averageModern())
+	}
+	return []string{
+		scope,
+		name,
+		fmt.Sprint(summary.resources),
+		fmt.Sprint(summary.notSupported),
+		fmt.Sprint(summary.errors),
+		classicCoverage,
+		modernCoverage,
+		fmt.Sprint(summary.compliant),
+	}
+}
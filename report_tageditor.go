@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// tagEditorNotTagged is how the AWS Tag Editor export marks a key absent from a resource
+const tagEditorNotTagged = "(not tagged)"
+
+// TagEditorReport writes a csv in the layout of the AWS Tag Editor export: one row per
+// taggable resource identified by its ARN, with a "Tag: key" column for every required
+// key followed by the other keys found, so the file can drive bulk tagging.
+// The columns depend on every resource so the rows are held until Close
+type TagEditorReport struct {
+	w       io.Writer
+	dialect CSVDialect
+	region  string
+	rows    []tagEditorRow
+	keys    map[string]bool
+}
+
+type tagEditorRow struct {
+	arn          string
+	resourceType string
+	tags         map[string]string
+}
+
+func NewTagEditorReporter(w io.Writer, dialect CSVDialect, region string) *TagEditorReport {
+	return &TagEditorReport{
+		w:       w,
+		dialect: dialect,
+		region:  region,
+		keys:    make(map[string]bool),
+	}
+}
+
+func (r *TagEditorReport) Add(result Result) {
+	// only taggable resources whose current tags are known can be edited
+	if result.Finding == nil {
+		return
+	}
+	resource, tags := result.Resource, result.Tags
+	arn := result.Arn
+	if arn == "" {
+		arn = resource.Name
+	}
+	r.rows = append(r.rows, tagEditorRow{arn, resource.Type, tags})
+	for key := range tags {
+		r.keys[key] = true
+	}
+}
+
+// Write is a no-op, the columns are only known on Close
+func (r *TagEditorReport) Write() {
+}
+
+func (r *TagEditorReport) Close() {
+	keys := tagColumns(r.keys)
+
+	columns := []string{"Identifier", "Service", "Type", "Region", "Tags"}
+	for _, key := range keys {
+		columns = append(columns, "Tag: "+key)
+	}
+
+	w := newCSVWriter(r.w, r.dialect)
+	err := w.Write(columns)
+	for _, row := range r.rows {
+		if err != nil {
+			break
+		}
+		record := []string{row.arn, arnService(row.arn), extractType(row.resourceType), r.region, fmt.Sprint(len(row.tags))}
+		for _, key := range keys {
+			if value, ok := row.tags[key]; ok {
+				record = append(record, value)
+			} else {
+				record = append(record, tagEditorNotTagged)
+			}
+		}
+		err = w.Write(record)
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
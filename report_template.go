@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateReport renders the resources through a user supplied text/template, for bespoke
+// formats such as ticket markup or CMDB import files. The template is executed once on
+// Close with a templateData
+type TemplateReport struct {
+	w        io.Writer
+	template *template.Template
+	data     templateData
+}
+
+// templateData is what templates are executed with
+type templateData struct {
+	Search    string
+	Partition string
+	Account   string
+	Region    string
+	Date      time.Time
+	// the keys required by the schemes
+	Classic   []string
+	Modern    []string
+	Resources []templateResource
+}
+
+// templateResource is a scanned resource along with the outcome of its tags lookup
+type templateResource struct {
+	Resource
+	ARN string
+	// Supported is false for resources which do not support tags
+	Supported       bool
+	Error           string
+	Tags            map[string]string
+	MissingTags     []string
+	InvalidTags     []string
+	ClassicCoverage int
+	ModernCoverage  int
+}
+
+// templateFuncs are available to the templates on top of the text/template builtins
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(v interface{}) (string, error) {
+		body, err := json.Marshal(v)
+		return string(body), err
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+func NewTemplateReporter(w io.Writer, path string, partition string, account string, region string, search string) *TemplateReport {
+	t, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		panic(err.Error())
+	}
+	return &TemplateReport{
+		w:        w,
+		template: t,
+		data: templateData{
+			Search:    search,
+			Partition: partition,
+			Account:   account,
+			Region:    region,
+			Date:      time.Now().UTC(),
+			Classic:   classic.allKeys(),
+			Modern:    modern.allKeys(),
+		},
+	}
+}
+
+func (r *TemplateReport) Add(result Result) {
+	resource := result.Resource
+	if result.Err != nil {
+		r.data.Resources = append(r.data.Resources, templateResource{
+			Resource:  resource,
+			ARN:       result.Arn,
+			Supported: true,
+			Error:     result.Err.Error(),
+		})
+		return
+	}
+	if result.Finding == nil {
+		r.data.Resources = append(r.data.Resources, templateResource{
+			Resource: resource,
+			ARN:      result.Arn,
+		})
+		return
+	}
+	tags, finding := result.Tags, result.Finding
+	r.data.Resources = append(r.data.Resources, templateResource{
+		Resource:        resource,
+		ARN:             result.Arn,
+		Supported:       true,
+		Tags:            tags,
+		MissingTags:     finding.Missing,
+		InvalidTags:     finding.Invalid,
+		ClassicCoverage: finding.ClassicCoverage,
+		ModernCoverage:  finding.ModernCoverage,
+	})
+}
+
+// Write is a no-op, the template is executed on Close
+func (r *TemplateReport) Write() {
+}
+
+func (r *TemplateReport) Close() {
+	if err := r.template.Execute(r.w, r.data); err != nil {
+		panic(err.Error())
+	}
+}
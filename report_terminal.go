@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// the coverage thresholds of the colors of the terminal summary
+const (
+	coverageGood = 90
+	coverageFair = 60
+)
+
+// TerminalSummary prints a table of the coverage of each stack once the scan is complete,
+// the coverages in green, yellow or red by threshold when colored
+type TerminalSummary struct {
+	w      io.Writer
+	color  bool
+	stacks stackSummaries
+}
+
+func NewTerminalSummary(w io.Writer, color bool) *TerminalSummary {
+	return &TerminalSummary{
+		w:      w,
+		color:  color,
+		stacks: make(stackSummaries),
+	}
+}
+
+// printsSummary tells whether the -print-summary of the options prints the terminal summary,
+// and in color, on auto when stderr is a terminal without NO_COLOR set
+func printsSummary(options *Options) (bool, bool) {
+	terminal := term.IsTerminal(int(os.Stderr.Fd()))
+	color := terminal && os.Getenv("NO_COLOR") == ""
+	switch options.PrintSummary {
+	case "always":
+		return true, color
+	case "auto":
+		return terminal && !options.TUI, color
+	}
+	return false, false
+}
+
+func (r *TerminalSummary) Add(result Result) {
+	stack := result.Resource.Stack.Name
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		r.stacks.get(stack).errors++
+	case finding == nil:
+		r.stacks.get(stack).notSupported++
+	default:
+		r.stacks.add(stack, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+}
+
+// Write is a no-op, the table is printed on Close
+func (r *TerminalSummary) Write() {
+}
+
+func (r *TerminalSummary) Close() {
+	names := r.stacks.names()
+	width := len("Total")
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	var b strings.Builder
+	header := fmt.Sprintf("%-*s %9s %7s %7s %6s", width, "Stack", "Resources", "Classic", "Modern", "Errors")
+	if r.color {
+		header = "\x1b[1m" + header + "\x1b[0m"
+	}
+	b.WriteString(header + "\n")
+	for _, name := range names {
+		b.WriteString(r.row(name, width, r.stacks[name]))
+	}
+	if len(names) > 1 {
+		b.WriteString(strings.Repeat("-", width+34) + "\n")
+		b.WriteString(r.row("Total", width, r.stacks.total()))
+	}
+	if _, err := io.WriteString(r.w, b.String()); err != nil {
+		panic(err.Error())
+	}
+}
+
+// row formats the line of a stack
+func (r *TerminalSummary) row(name string, width int, summary *stackSummary) string {
+	classicCoverage, modernCoverage := "N/A", "N/A"
+	if summary.resources > 0 {
+		classicCoverage = r.colored(fmt.Sprintf("%7s", fmt.Sprintf("%d%%", summary.averageClassic())), summary.averageClassic())
+		modernCoverage = r.colored(fmt.Sprintf("%7s", fmt.Sprintf("%d%%", summary.averageModern())), summary.averageModern())
+	} else {
+		classicCoverage, modernCoverage = fmt.Sprintf("%7s", classicCoverage), fmt.Sprintf("%7s", modernCoverage)
+	}
+	errors := fmt.Sprintf("%6d", summary.errors)
+	if r.color && summary.errors > 0 {
+		errors = "\x1b[31m" + errors + "\x1b[0m"
+	}
+	return fmt.Sprintf("%-*s %9d %s %s %s\n", width, name, summary.resources+summary.notSupported+summary.errors,
+		classicCoverage, modernCoverage, errors)
+}
+
+// colored colors the text of a coverage by threshold
+func (r *TerminalSummary) colored(text string, coverage int) string {
+	if !r.color {
+		return text
+	}
+	color := "\x1b[31m"
+	if coverage >= coverageGood {
+		color = "\x1b[32m"
+	} else if coverage >= coverageFair {
+		color = "\x1b[33m"
+	}
+	return color + text + "\x1b[0m"
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ValuesReport inventories as csv the distinct values of the selected tag keys with the
+// number of resources using each, flagging the values which look like a variant of
+// another one ("Prod", "prod", "produciton") so value drift can be cleaned up
+type ValuesReport struct {
+	w       io.Writer
+	dialect CSVDialect
+	keys    []string
+	values  map[string]map[string]int
+}
+
+func NewValuesReporter(w io.Writer, dialect CSVDialect, keys []string) *ValuesReport {
+	if len(keys) == 0 {
+		keys = modern.allKeys()
+	}
+	return &ValuesReport{
+		w:       w,
+		dialect: dialect,
+		keys:    keys,
+		values:  make(map[string]map[string]int),
+	}
+}
+
+func (r *ValuesReport) Add(result Result) {
+	// resources without tags have no values, and the values of those whose tags are unknown
+	// are unknown
+	if result.Finding == nil {
+		return
+	}
+	tags := result.Tags
+	for _, key := range r.keys {
+		value, ok := tags[key]
+		if !ok {
+			continue
+		}
+		if r.values[key] == nil {
+			r.values[key] = make(map[string]int)
+		}
+		r.values[key][value]++
+	}
+}
+
+// Write is a no-op, the inventory is written on Close
+func (r *ValuesReport) Write() {
+}
+
+func (r *ValuesReport) Close() {
+	w := newCSVWriter(r.w, r.dialect)
+	err := w.Write([]string{"Tag", "Value", "Resources", "Similar Values"})
+	for _, key := range r.keys {
+		counts := r.values[key]
+		var values []string
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if counts[values[i]] != counts[values[j]] {
+				return counts[values[i]] > counts[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		for _, value := range values {
+			if err != nil {
+				break
+			}
+			var similar []string
+			for _, other := range values {
+				if other != value && similarValues(value, other) {
+					similar = append(similar, other)
+				}
+			}
+			err = w.Write([]string{key, value, fmt.Sprint(counts[value]), strings.Join(similar, ",")})
+		}
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// similarValues tells whether two values only differ by case, surrounding spaces or a
+// couple of typos, short values being allowed a single one
+func similarValues(a string, b string) bool {
+	a, b = strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b))
+	if a == b {
+		return true
+	}
+	shortest := len(a)
+	if len(b) < shortest {
+		shortest = len(b)
+	}
+	if shortest < 3 {
+		return false
+	}
+	allowed := 2
+	if shortest <= 4 {
+		allowed = 1
+	}
+	return editDistance(a, b) <= allowed
+}
+
+// editDistance is the optimal string alignment distance: the insertions, deletions,
+// substitutions and transpositions of adjacent characters turning a into b
+func editDistance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// XLSXReport writes the resources as an excel workbook with sheets for the report,
+// a per stack summary, the unsupported resources and the lookup errors.
+// A workbook can only be written as a whole so everything is held until Close
+type XLSXReport struct {
+	w                io.Writer
+	groupByConstruct bool
+	report           [][]interface{}
+	notSupported     [][]interface{}
+	errors           [][]interface{}
+	stacks           stackSummaries
+}
+
+// xlsxSheet is a worksheet, a header row followed by string or numeric cells
+type xlsxSheet struct {
+	name   string
+	header []string
+	rows   [][]interface{}
+}
+
+func NewXLSXReporter(w io.Writer, groupByConstruct bool) *XLSXReport {
+	return &XLSXReport{
+		w:                w,
+		groupByConstruct: groupByConstruct,
+		stacks:           make(stackSummaries),
+	}
+}
+
+func (r *XLSXReport) Add(result Result) {
+	resource := result.Resource
+	r.report = append(r.report, r.row(result))
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		r.errors = append(r.errors, []interface{}{resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name, result.Err.Error()})
+		r.stacks.get(resource.Stack.Name).errors++
+	case finding == nil:
+		r.notSupported = append(r.notSupported, []interface{}{resource.Type, resource.Name, resource.ConstructPath, resource.Stack.Name})
+		r.stacks.get(resource.Stack.Name).notSupported++
+	default:
+		r.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+	}
+}
+
+// row is the report row of a result, the finding cells left empty and the coverage not
+// applicable for the resources not supporting tags, or the status of the error as in the csv
+func (r *XLSXReport) row(result Result) []interface{} {
+	resource := result.Resource
+	row := []interface{}{
+		extractType(resource.Type),
+		resource.Name,
+		resource.ConstructPath,
+	}
+	if finding := result.Finding; finding != nil {
+		row = append(row,
+			strings.Join(finding.Present, ","),
+			strings.Join(finding.Missing, ","),
+			strings.Join(finding.Invalid, ","),
+			strings.Join(finding.CaseMismatches, ","),
+			strings.Join(resource.Aliases, ","),
+			strings.Join(resource.Violations, "; "),
+			resource.Exemption,
+		)
+	} else {
+		row = append(row, "", "", "", "", "", "", "")
+	}
+	row = append(row, resource.Stack.Origin)
+	for _, scheme := range schemes {
+		if result.Finding != nil {
+			row = append(row, result.Finding.Coverage[scheme.Name])
+		} else if status := errorStatus(result.Err); status != "" {
+			row = append(row, status)
+		} else {
+			row = append(row, "N/A")
+		}
+	}
+	return append(row, r.details(result)...)
+}
+
+// details are the cells identifying the resource and its stack
+func (r *XLSXReport) details(result Result) []interface{} {
+	resource := result.Resource
+	return []interface{}{
+		result.Arn,
+		result.Region,
+		result.Account,
+		resource.Stack.Name,
+		resource.Stack.Id,
+		formatTime(resource.LastUpdated),
+		formatTime(resource.Stack.CreationTime),
+		formatTime(resource.Stack.LastUpdatedTime),
+	}
+}
+
+// Write is a no-op, the workbook is written on Close
+func (r *XLSXReport) Write() {
+}
+
+func (r *XLSXReport) Close() {
+	if r.groupByConstruct {
+		sort.SliceStable(r.report, func(i, j int) bool {
+			return r.report[i][2].(string) < r.report[j][2].(string)
+		})
+	}
+
+	var stacks [][]interface{}
+	for _, name := range r.stacks.names() {
+		summary := r.stacks[name]
+		row := []interface{}{name, summary.resources, summary.notSupported, summary.errors, "N/A", "N/A", summary.compliant}
+		if summary.resources > 0 {
+			row[4], row[5] = summary.averageClassic(), summary.averageModern()
+		}
+		stacks = append(stacks, row)
+	}
+
+	err := writeWorkbook(r.w, []xlsxSheet{
+		{"Report", reportHeader(), r.report},
+		{"Stacks", []string{"Stack", "Resources", "Not Supported", "Errors", "Classic Coverage",
+			"Modern Coverage", "Compliant Resources"}, stacks},
+		{"Not Supported", []string{"Type", "Resource Name", "Construct Path", "Stack"}, r.notSupported},
+		{"Errors", []string{"Type", "Resource Name", "Construct Path", "Stack", "Error"}, r.errors},
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// writeWorkbook writes the minimal set of SpreadsheetML parts making up an xlsx file,
+// each sheet gets a bold frozen header row and an auto-filter
+func writeWorkbook(w io.Writer, sheets []xlsxSheet) error {
+	buffered := bufio.NewWriter(w)
+	archive := zip.NewWriter(buffered)
+
+	var overrides, relationships, entries, filters strings.Builder
+	for i, sheet := range sheets {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&relationships, `<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		fmt.Fprintf(&entries, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.name), i+1, i+1)
+		fmt.Fprintf(&filters, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, xmlEscape(sheet.name), sheetRange(sheet, true))
+	}
+	styles := len(sheets) + 1
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xml.Header +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", xml.Header +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + entries.String() + `</sheets>` +
+			`<definedNames>` + filters.String() + `</definedNames></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + relationships.String() +
+			fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, styles) +
+			`</Relationships>`},
+		{"xl/styles.xml", xml.Header +
+			`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet)})
+	}
+
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func worksheet(sheet xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+		`</sheetView></sheetViews><sheetData>`)
+
+	b.WriteString(`<row r="1">`)
+	for c, name := range sheet.header {
+		fmt.Fprintf(&b, `<c r="%s1" s="1" t="inlineStr"><is><t>%s</t></is></c>`, columnName(c), xmlEscape(name))
+	}
+	b.WriteString(`</row>`)
+	for r, row := range sheet.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+2)
+		for c, value := range row {
+			ref := fmt.Sprintf("%s%d", columnName(c), r+2)
+			switch v := value.(type) {
+			case int:
+				fmt.Fprintf(&b, `<c r="%s"><v>%d</v></c>`, ref, v)
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(fmt.Sprint(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	fmt.Fprintf(&b, `<autoFilter ref="%s"/>`, sheetRange(sheet, false))
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// sheetRange is the A1 reference covering the header and rows of a sheet
+func sheetRange(sheet xlsxSheet, absolute bool) string {
+	last, rows := columnName(len(sheet.header)-1), len(sheet.rows)+1
+	if absolute {
+		return fmt.Sprintf("$A$1:$%s$%d", last, rows)
+	}
+	return fmt.Sprintf("A1:%s%d", last, rows)
+}
+
+// columnName converts a zero based column index to its letters, 0 is A and 26 is AA
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		panic(err.Error())
+	}
+	return b.String()
+}
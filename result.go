@@ -0,0 +1,90 @@
+package main
+
+// Result is the outcome of the scan of a resource, which the reporters render rather than
+// evaluating the tags themselves
+type Result struct {
+	Resource Resource
+	Search   string
+	// Arn, Region and Account qualify the resource across accounts and regions
+	Arn     string
+	Region  string
+	Account string
+	// Supported is false for the resources not supporting tags and those whose tags could
+	// not be looked up, Err telling why
+	Supported bool
+	Err       error
+	Tags      map[string]string
+	// Finding is nil unless the resource is supported
+	Finding *Finding
+}
+
+// Finding is what the tag policy finds of the tags of a resource
+type Finding struct {
+	// Present and Missing are the required modern keys the resource carries and misses
+	Present []string
+	Missing []string
+	// Invalid are the keys whose value the policy rejects
+	Invalid         []string
+	CaseMismatches  []string
+	ClassicCoverage int
+	ModernCoverage  int
+	// Coverage is the coverage of each selected scheme by name
+	Coverage map[string]int
+}
+
+// compliant tells whether the resource carries every required modern key with a valid value
+func (f *Finding) compliant() bool {
+	return len(f.Missing) == 0 && len(f.Invalid) == 0
+}
+
+// remediationKeys are the required keys the resource misses or carries an invalid value of
+func (f *Finding) remediationKeys() []string {
+	keys := append([]string{}, f.Missing...)
+	return append(keys, f.Invalid...)
+}
+
+// newResult evaluates the tags of a resource against the tag policy
+func newResult(resource Resource, search string, tags map[string]string, arn arnResolver, account string, region string) Result {
+	required := modern.required(resource)
+	present, missing := extractKeys(tags, required)
+	return Result{
+		Resource:  resource,
+		Search:    search,
+		Arn:       arn(resource.Type, resource.Name),
+		Region:    region,
+		Account:   account,
+		Supported: true,
+		Tags:      tags,
+		Finding: &Finding{
+			Present:         present,
+			Missing:         missing,
+			Invalid:         invalidKeys(tags, required),
+			CaseMismatches:  caseMismatches(tags, required),
+			ClassicCoverage: coverage(tags, classic.required(resource)),
+			ModernCoverage:  coverage(tags, required),
+			Coverage:        schemeCoverage(resource, tags),
+		},
+	}
+}
+
+// schemeCoverage is the coverage of each selected scheme by name
+func schemeCoverage(resource Resource, tags map[string]string) map[string]int {
+	coverages := make(map[string]int)
+	for _, scheme := range schemes {
+		coverages[scheme.Name] = coverage(tags, scheme.required(resource))
+	}
+	return coverages
+}
+
+// newUnsupportedResult is the result of a resource not supporting tags, or whose tags could
+// not be looked up when err is set
+func newUnsupportedResult(resource Resource, search string, err error, arn arnResolver, account string, region string) Result {
+	return Result{
+		Resource: resource,
+		Search:   search,
+		Arn:      arn(resource.Type, resource.Name),
+		Region:   region,
+		Account:  account,
+		Err:      err,
+	}
+}
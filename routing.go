@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+)
+
+// routeUnowned is the owner of the resources without the -route-by tag
+const routeUnowned = "unowned"
+
+// routeKey returns the tag key of a -route-by tag:<key>, empty when invalid
+func routeKey(by string) string {
+	if !strings.HasPrefix(by, "tag:") {
+		return ""
+	}
+	return strings.TrimPrefix(by, "tag:")
+}
+
+// Router delivers the report file of each owner of -route-by, as an email attachment sent
+// through SES to the owner, or as a file in the Slack direct messages of the owner with the
+// SLACK_BOT_TOKEN bot token; a delivery failing is a warning, the file being left in place
+type Router struct {
+	ctx      context.Context
+	to       string
+	from     string
+	search   string
+	ses      *ses.Client
+	token    string
+	client   *http.Client
+	warnings io.Writer
+}
+
+// newRouter creates the Router of the -route-to destination, nil when the files are
+// only written
+func newRouter(ctx context.Context, config aws.Config, options *Options, warnings io.Writer) *Router {
+	if options.RouteBy == "" || options.RouteTo == "file" {
+		return nil
+	}
+	return &Router{
+		ctx:      ctx,
+		to:       options.RouteTo,
+		from:     options.RouteFrom,
+		search:   options.Search,
+		ses:      ses.New(config),
+		token:    os.Getenv("SLACK_BOT_TOKEN"),
+		client:   &http.Client{Timeout: time.Minute},
+		warnings: warnings,
+	}
+}
+
+// upload returns the Upload of the output of an owner, delivering each file before handing
+// it to next, if any
+func (r *Router) upload(owner string, next func(path string)) func(path string) {
+	if r == nil {
+		return next
+	}
+	return func(path string) {
+		var err error
+		if owner == routeUnowned {
+			err = fmt.Errorf("the resources have no owner")
+		} else if r.to == "email" {
+			err = r.email(owner, path)
+		} else {
+			err = r.slack(owner, path)
+		}
+		if err != nil {
+			fmt.Fprintf(r.warnings, "%s was not delivered to %s: %v\n", path, owner, err)
+		}
+		if next != nil {
+			next(path)
+		}
+	}
+}
+
+// email sends the file as the attachment of an email to the owner
+func (r *Router) email(owner string, path string) error {
+	if !strings.Contains(owner, "@") {
+		return fmt.Errorf("not an email address")
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var message bytes.Buffer
+	parts := multipart.NewWriter(&message)
+	fmt.Fprintf(&message, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/mixed; boundary=%q\r\n\r\n",
+		r.from, owner, mime.QEncoding.Encode("utf-8", r.subject()), parts.Boundary())
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(text, "The tag compliance report of your resources is attached.\r\n")
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	attachment, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)})},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(body)
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+	if err := parts.Close(); err != nil {
+		return err
+	}
+
+	_, err = r.ses.SendRawEmailRequest(&ses.SendRawEmailInput{
+		Source:       aws.String(r.from),
+		Destinations: []string{owner},
+		RawMessage:   &ses.RawMessage{Data: message.Bytes()},
+	}).Send(r.ctx)
+	return err
+}
+
+// slack shares the file in the direct messages of the owner, a Slack user id or the email
+// address of a Slack user
+func (r *Router) slack(owner string, path string) error {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	user := owner
+	if strings.Contains(owner, "@") {
+		var lookup struct {
+			User struct {
+				Id string `json:"id"`
+			} `json:"user"`
+		}
+		if err := r.slackCall("users.lookupByEmail", url.Values{"email": {owner}}, &lookup); err != nil {
+			return err
+		}
+		user = lookup.User.Id
+	}
+	var conversation struct {
+		Channel struct {
+			Id string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := r.slackCall("conversations.open", url.Values{"users": {user}}, &conversation); err != nil {
+		return err
+	}
+
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileId    string `json:"file_id"`
+	}
+	err = r.slackCall("files.getUploadURLExternal", url.Values{
+		"filename": {filepath.Base(path)},
+		"length":   {fmt.Sprint(len(body))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+	response, err := r.client.Post(upload.UploadURL, "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("slack upload responded %s", response.Status)
+	}
+	files, err := json.Marshal([]map[string]string{{"id": upload.FileId, "title": filepath.Base(path)}})
+	if err != nil {
+		return err
+	}
+	return r.slackCall("files.completeUploadExternal", url.Values{
+		"files":           {string(files)},
+		"channel_id":      {conversation.Channel.Id},
+		"initial_comment": {r.subject()},
+	}, nil)
+}
+
+// slackCall calls a method of the Slack web api, decoding the response into result when set
+func (r *Router) slackCall(method string, form url.Values, result interface{}) error {
+	request, err := http.NewRequest(http.MethodPost, "https://slack.com/api/"+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+r.token)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	var status struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("slack %s responded %s", method, response.Status)
+	} else if !status.Ok {
+		return fmt.Errorf("slack %s failed: %s", method, status.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// subject is the subject of the messages delivering the reports
+func (r *Router) subject() string {
+	return fmt.Sprintf("Tag compliance report of your resources matching %s", r.search)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Upload copies the report files to s3 once complete, under a prefix partitioned by
+// date, account and region as date=2006-01-02/account=123456789012/region=us-east-1/
+type S3Upload struct {
+	ctx    context.Context
+	client *s3.Client
+	bucket string
+	// root is the prefix of the uri, prefix the one of the partition of the run below it
+	root   string
+	prefix string
+	// partition holds the date, account and region of the partition
+	partition []string
+	// kmsKey encrypts the objects with SSE-KMS when set
+	kmsKey string
+}
+
+func newS3Upload(ctx context.Context, config aws.Config, uri string, kmsKey string, account string, region string, now time.Time) (*S3Upload, error) {
+	bucket, prefix, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3Upload{
+		ctx:       ctx,
+		client:    s3.New(config),
+		bucket:    bucket,
+		root:      prefix,
+		prefix:    prefix + fmt.Sprintf("date=%s/account=%s/region=%s/", now.Format("2006-01-02"), account, region),
+		partition: []string{now.Format("2006-01-02"), account, region},
+		kmsKey:    kmsKey,
+	}, nil
+}
+
+// parseS3URI splits s3://bucket/prefix into the bucket and the prefix
+func parseS3URI(uri string) (string, string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "s3" || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid s3 uri %q, expected s3://bucket/prefix/", uri)
+	}
+	return parsed.Host, strings.TrimPrefix(parsed.Path, "/"), nil
+}
+
+// consoleURL links to the uploads of the run in the s3 console
+func (u *S3Upload) consoleURL() string {
+	return fmt.Sprintf("https://s3.console.aws.amazon.com/s3/buckets/%s?prefix=%s", u.bucket, url.QueryEscape(u.prefix))
+}
+
+// upload copies a complete report file to s3
+func (u *S3Upload) upload(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer f.Close()
+
+	if err := u.put(u.bucket, u.prefix+filepath.Base(path), f); err != nil {
+		panic(err.Error())
+	}
+}
+
+// put writes an object, encrypted with the kms key when set
+func (u *S3Upload) put(bucket string, key string, body io.ReadSeeker) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if u.kmsKey != "" {
+		input.ServerSideEncryption = s3.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(u.kmsKey)
+	}
+	_, err := u.client.PutObjectRequest(input).Send(u.ctx)
+	return err
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+)
+
+// the backoff of a service whose lookups are throttled, doubling up to maxThrottleBackoff, a
+// lookup being retried throttleAttempts times before its error is reported
+const (
+	throttleBackoff    = 500 * time.Millisecond
+	maxThrottleBackoff = 30 * time.Second
+	throttleAttempts   = 5
+)
+
+// throttleErrorCodes are the error codes of the apis rate limiting their callers
+var throttleErrorCodes = map[string]bool{
+	"EC2ThrottledException":                  true,
+	"PriorRequestNotComplete":                true,
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"SlowDown":                               true,
+	"ThrottledException":                     true,
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"TooManyRequestsException":               true,
+}
+
+// isThrottling tells whether a lookup failed on the rate limit of its api, once the retries
+// of the sdk are exhausted
+func isThrottling(err error) bool {
+	var ae awserr.Error
+	return errors.As(err, &ae) && throttleErrorCodes[ae.Code()]
+}
+
+// lookupService is the service of the api of a lookup, e.g. lambda, the types not supporting
+// tags having none
+func lookupService(lookup resourceLookup) string {
+	return strings.SplitN(lookup.api, ":", 2)[0]
+}
+
+// lookupJob is the tag lookup of a resource, done once its result is set
+type lookupJob struct {
+	service  string
+	lookup   func() (map[string]string, error)
+	attempts int
+	tags     map[string]string
+	err      error
+	// panicked is the value of a panic of the lookup, raised again by result
+	panicked interface{}
+	done     chan struct{}
+}
+
+func (j *lookupJob) run() (tags map[string]string, panicked interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = r
+		}
+	}()
+	tags, err = j.lookup()
+	return tags, nil, err
+}
+
+// lookupScheduler runs the tag lookups of the resources on a pool of workers, taking turns
+// between the services rather than following the order of the resources, so that a stack
+// full of Lambda functions does not burst the Lambda api while the others sit idle. At most
+// perService lookups of a service are in flight at once, and a service whose lookups are
+// throttled is paused for a backoff before its lookup is retried. The results are read in
+// any order with result, the report keeping the order of the resources.
+type lookupScheduler struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	workers    int
+	perService int
+	// services are in the order of their first lookup, next being the one whose turn it is
+	services []string
+	next     int
+	queues   map[string][]*lookupJob
+	inFlight map[string]int
+	paused   map[string]time.Time
+	backoff  map[string]time.Duration
+	jobs     map[int]*lookupJob
+	pending  int
+	stopped  bool
+}
+
+func newLookupScheduler(workers int, perService int) *lookupScheduler {
+	s := &lookupScheduler{
+		workers:    workers,
+		perService: perService,
+		queues:     make(map[string][]*lookupJob),
+		inFlight:   make(map[string]int),
+		paused:     make(map[string]time.Time),
+		backoff:    make(map[string]time.Duration),
+		jobs:       make(map[int]*lookupJob),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// add queues the lookup of the resource of index i, before start
+func (s *lookupScheduler) add(i int, service string, lookup func() (map[string]string, error)) {
+	if _, ok := s.queues[service]; !ok {
+		s.services = append(s.services, service)
+	}
+	job := &lookupJob{service: service, lookup: lookup, done: make(chan struct{})}
+	s.queues[service] = append(s.queues[service], job)
+	s.jobs[i] = job
+	s.pending++
+}
+
+// start starts the workers, which stop once every lookup is done
+func (s *lookupScheduler) start() {
+	for w := 0; w < s.workers; w++ {
+		go s.work()
+	}
+}
+
+// stop stops the workers after their current lookup, when the scan fails before reading
+// every result
+func (s *lookupScheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	s.cond.Broadcast()
+}
+
+// result waits for the lookup of the resource of index i, which must have been added
+func (s *lookupScheduler) result(i int) (map[string]string, error) {
+	job := s.jobs[i]
+	<-job.done
+	delete(s.jobs, i)
+	if job.panicked != nil {
+		panic(job.panicked)
+	}
+	return job.tags, job.err
+}
+
+func (s *lookupScheduler) work() {
+	for {
+		s.mu.Lock()
+		var job *lookupJob
+		for {
+			if s.stopped || s.pending == 0 {
+				s.mu.Unlock()
+				return
+			}
+			var wake time.Time
+			if job, wake = s.pick(time.Now()); job != nil {
+				break
+			}
+			if !wake.IsZero() {
+				// every service with lookups left is paused or busy
+				time.AfterFunc(time.Until(wake), s.cond.Broadcast)
+			}
+			s.cond.Wait()
+		}
+		s.mu.Unlock()
+
+		tags, panicked, err := job.run()
+		s.finish(job, tags, panicked, err)
+	}
+}
+
+// pick takes the next lookup of the first service, from the one whose turn it is, with
+// lookups left and neither busy nor paused; without any, wake is the end of the first pause
+func (s *lookupScheduler) pick(now time.Time) (job *lookupJob, wake time.Time) {
+	for i := range s.services {
+		service := s.services[(s.next+i)%len(s.services)]
+		if len(s.queues[service]) == 0 || s.inFlight[service] >= s.perService {
+			continue
+		}
+		if until := s.paused[service]; now.Before(until) {
+			if wake.IsZero() || until.Before(wake) {
+				wake = until
+			}
+			continue
+		}
+		job = s.queues[service][0]
+		s.queues[service] = s.queues[service][1:]
+		s.inFlight[service]++
+		s.next = (s.next + i + 1) % len(s.services)
+		return job, time.Time{}
+	}
+	return nil, wake
+}
+
+// finish sets the result of a lookup, or queues it again first in line when throttled,
+// pausing its service
+func (s *lookupScheduler) finish(job *lookupJob, tags map[string]string, panicked interface{}, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.cond.Broadcast()
+	s.inFlight[job.service]--
+
+	if panicked == nil && isThrottling(err) && job.attempts < throttleAttempts {
+		job.attempts++
+		backoff := s.backoff[job.service] * 2
+		if backoff == 0 {
+			backoff = throttleBackoff
+		} else if backoff > maxThrottleBackoff {
+			backoff = maxThrottleBackoff
+		}
+		s.backoff[job.service] = backoff
+		s.paused[job.service] = time.Now().Add(backoff)
+		s.queues[job.service] = append([]*lookupJob{job}, s.queues[job.service]...)
+		return
+	}
+	if err == nil {
+		s.backoff[job.service] = 0
+	}
+	job.tags, job.panicked, job.err = tags, panicked, err
+	close(job.done)
+	s.pending--
+}
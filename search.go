@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// searchResources lists the resources of the stacks of every search, each search discovering
+// its stacks on its own while at most -search-concurrency of them run at once, so that an
+// organisation wide run over many project prefixes does not list them one after the other.
+// The resources are returned in the order of the searches, searches holding the search of
+// each resource, the report being written from them one at a time.
+func searchResources(ctx context.Context, cfg aws.Config, options *Options) (resources []StackResource, searches []string) {
+	found := make([][]StackResource, len(options.Searches))
+	// the panics of a search are raised again once every search is done
+	panics := make([]interface{}, len(options.Searches))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, options.SearchWorkers)
+	for i := range options.Searches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() {
+				<-slots
+				if r := recover(); r != nil {
+					panics[i] = r
+				}
+			}()
+			search := options.Searches[i]
+			found[i] = getStackResources(ctx, cfg, &search)
+			if options.IncludeStacks {
+				found[i] = append(stackResources(found[i]), found[i]...)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, search := range options.Searches {
+		if panics[i] != nil {
+			panic(panics[i])
+		}
+		resources = append(resources, found[i]...)
+		for range found[i] {
+			searches = append(searches, search)
+		}
+	}
+	return resources, searches
+}
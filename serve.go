@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+)
+
+// contentTypes are the media types of the report formats, text/plain for the others
+var contentTypes = map[string]string{
+	"csv":      "text/csv; charset=utf-8",
+	"json":     "application/json",
+	"jsonl":    "application/x-ndjson",
+	"xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"html":     "text/html; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"parquet":  "application/octet-stream",
+	"junit":    "application/xml",
+	"sarif":    "application/sarif+json",
+}
+
+// Server runs scans on request, the report options of the command line applying to all of
+// them, and caches the reports for a while
+type Server struct {
+	ctx  context.Context
+	cfg  aws.Config
+	args []string
+	ttl  time.Duration
+	// scans are run one at a time, sharing the policy
+	scanning sync.Mutex
+	mu       sync.Mutex
+	cache    map[string]cachedReport
+}
+
+type cachedReport struct {
+	body      []byte
+	generated time.Time
+}
+
+// serve runs the serve subcommand: serve [-listen addr] [-cache-ttl duration] [-- report options]
+func serve(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report serve", flag.ContinueOnError)
+	fs.SetOutput(w)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	ttl := fs.Duration("cache-ttl", 15*time.Minute, "how long a report is served before being scanned again, 0 to always scan")
+	fs.Usage = func() {
+		fmt.Fprintf(w, "usage: aws-tag-report serve [-listen addr] [-cache-ttl duration] [-- report options]\n\n"+
+			"Serves GET /report?search=<stack name or pattern>&format=<format> and GET /healthz, the report\n"+
+			"options applying to every scan.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// the report options are validated once with a placeholder search
+	if _, err := newServerOptions(fs.Args(), "csv", "*"); err != nil {
+		return err
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	server := &Server{
+		ctx:   context.TODO(),
+		cfg:   cfg,
+		args:  fs.Args(),
+		ttl:   *ttl,
+		cache: make(map[string]cachedReport),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report", server.report)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	fmt.Fprintf(w, "listening on %s\n", *listen)
+	return http.ListenAndServe(*listen, mux)
+}
+
+// newServerOptions parses the report options of the server for a search and format
+func newServerOptions(args []string, format string, search string) (*Options, error) {
+	options, err := parseOptions(ioutil.Discard, append(append([]string{"-format", format}, args...), search))
+	if err != nil {
+		return nil, err
+	}
+	if options.Format == "sqlite" || options.ParquetDir != "" || options.SplitBy != "" || options.RotateRows > 0 {
+		return nil, fmt.Errorf("the server writes a single report file, the sqlite format, -parquet-dir, -split-by and -rotate-rows do not apply")
+	} else if options.Output != "" {
+		return nil, fmt.Errorf("the server writes the report in the response, -output does not apply")
+	}
+	// the scans of the requests are not printed in the logs of the server
+	options.PrintSummary = "never"
+	return options, nil
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	search := r.URL.Query().Get("search")
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if search == "" {
+		http.Error(w, "search is required", http.StatusBadRequest)
+		return
+	}
+	options, err := newServerOptions(s.args, format, search)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := format + "\x00" + search
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if !ok || time.Since(cached.generated) >= s.ttl {
+		if cached, err = s.scan(options); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.mu.Lock()
+		s.cache[key] = cached
+		s.mu.Unlock()
+	}
+
+	contentType, ok := contentTypes[options.Format]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Last-Modified", cached.generated.UTC().Format(http.TimeFormat))
+	w.Write(cached.body)
+}
+
+// scan runs a scan into a temporary file, the panics of the scan being returned as errors
+func (s *Server) scan(options *Options) (report cachedReport, err error) {
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+
+	dir, err := ioutil.TempDir("", "aws-tag-report")
+	if err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(dir)
+	options.Output = filepath.Join(dir, "report."+options.Format)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	generated := time.Now()
+	if _, err := run(s.ctx, s.cfg, options, nil); err != nil {
+		return report, err
+	}
+	body, err := ioutil.ReadFile(options.Output)
+	if err != nil {
+		return report, err
+	}
+	return cachedReport{body: body, generated: generated}, nil
+}
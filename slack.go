@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// slackTop is how many stacks and coverage drops the slack message lists
+const slackTop = 5
+
+// SlackReport posts a summary of the run to a Slack incoming webhook once complete: the
+// overall compliance, the stacks with the most noncompliant resources, the biggest drops
+// of coverage since the baseline and a link to the report
+type SlackReport struct {
+	webhook  string
+	search   string
+	account  string
+	region   string
+	link     string
+	baseline *Baseline
+	stacks   stackSummaries
+	drops    []coverageDrop
+}
+
+// coverageDrop is a resource whose modern coverage decreased since the baseline
+type coverageDrop struct {
+	resource Resource
+	from     int
+	to       int
+}
+
+func NewSlackReporter(webhook string, search string, account string, region string, link string, baseline *Baseline) *SlackReport {
+	return &SlackReport{
+		webhook:  webhook,
+		search:   search,
+		account:  account,
+		region:   region,
+		link:     link,
+		baseline: baseline,
+		stacks:   make(stackSummaries),
+	}
+}
+
+func (r *SlackReport) Add(result Result) {
+	resource := result.Resource
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		r.stacks.get(resource.Stack.Name).errors++
+	case finding == nil:
+		r.stacks.get(resource.Stack.Name).notSupported++
+	default:
+		r.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+		if r.baseline == nil {
+			return
+		}
+		key := baselineKey(result.Arn, resource.Stack.Name, resource.Name)
+		if previous := r.baseline.coverage[key]; previous != nil && *previous > finding.ModernCoverage {
+			r.drops = append(r.drops, coverageDrop{resource, *previous, finding.ModernCoverage})
+		}
+	}
+}
+
+// Write is a no-op, the message is posted on Close
+func (r *SlackReport) Write() {
+}
+
+func (r *SlackReport) Close() {
+	if err := r.post(r.message()); err != nil {
+		panic(err.Error())
+	}
+}
+
+// message renders the summary in the Slack mrkdwn format
+func (r *SlackReport) message() string {
+	var b strings.Builder
+	total := r.stacks.total()
+	fmt.Fprintf(&b, "*Tag compliance of %s in %s %s*\n", slackEscape(r.search), r.account, r.region)
+	fmt.Fprintf(&b, "%d of %d resources compliant (%d%%), %d%% modern coverage on average",
+		total.compliant, total.resources, percentage(total.compliant, total.resources), total.averageModern())
+	if total.notSupported > 0 || total.errors > 0 {
+		fmt.Fprintf(&b, ", %d not supported, %d errors", total.notSupported, total.errors)
+	}
+	b.WriteString("\n")
+
+	names := r.stacks.names()
+	sort.SliceStable(names, func(i, j int) bool {
+		return r.stacks[names[i]].noncompliant() > r.stacks[names[j]].noncompliant()
+	})
+	if len(names) > 0 && r.stacks[names[0]].noncompliant() > 0 {
+		b.WriteString("\n*Top offending stacks*\n")
+		for i, name := range names {
+			summary := r.stacks[name]
+			if i == slackTop || summary.noncompliant() == 0 {
+				break
+			}
+			fmt.Fprintf(&b, "• %s: %d of %d resources noncompliant, %d%% modern coverage\n",
+				slackEscape(name), summary.noncompliant(), summary.resources, summary.averageModern())
+		}
+	}
+
+	sort.SliceStable(r.drops, func(i, j int) bool {
+		return r.drops[i].from-r.drops[i].to > r.drops[j].from-r.drops[j].to
+	})
+	if len(r.drops) > 0 {
+		b.WriteString("\n*Biggest coverage drops since the baseline*\n")
+		for i, drop := range r.drops {
+			if i == slackTop {
+				break
+			}
+			fmt.Fprintf(&b, "• %s %s (%s): %d%% → %d%%\n", extractType(drop.resource.Type),
+				slackEscape(drop.resource.Name), slackEscape(drop.resource.Stack.Name), drop.from, drop.to)
+		}
+	}
+
+	if r.link != "" {
+		fmt.Fprintf(&b, "\n<%s|Open the report>\n", r.link)
+	}
+	return b.String()
+}
+
+// post sends the message to the incoming webhook
+func (r *SlackReport) post(text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	response, err := http.Post(r.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("slack webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+// percentage returns part of total as a percentage, 100 when total is zero
+func percentage(part int, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return part * 100 / total
+}
+
+// slackEscape escapes the characters of text that Slack reads as control sequences
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
@@ -0,0 +1,50 @@
+package main
+
+import "sort"
+
+// sortedFormats are the formats written at Close anyway, which -sort holds the resources of;
+// the others stream their rows, as jsonl does, and are left as they are scanned
+var sortedFormats = map[string]bool{"csv": true, "json": true, "xlsx": true, "parquet": true}
+
+// sortedFormat sorts the report of a format with -sort, unless the format streams its rows or
+// -flush-every or -flush-interval is given to flush the report during the scan, the default
+// -flush-every being of no use to a report written at Close
+func sortedFormat(options *Options, format string, report Reporter) Reporter {
+	if !options.Sort || !sortedFormats[format] || (options.flushed && (options.FlushEvery > 0 || options.FlushInterval > 0)) {
+		return report
+	}
+	return &sortedReporter{Reporter: report}
+}
+
+// sortedReporter holds every resource until Close to forward them sorted by stack, resource
+// type and name, so that reports of an unchanged account are identical from run to run
+type sortedReporter struct {
+	Reporter
+	results []Result
+}
+
+func (s *sortedReporter) Add(result Result) {
+	s.results = append(s.results, result)
+}
+
+// Write is a no-op, nothing is forwarded before Close
+func (s *sortedReporter) Write() {
+}
+
+func (s *sortedReporter) Close() {
+	sort.SliceStable(s.results, func(i, j int) bool {
+		a, b := s.results[i].Resource, s.results[j].Resource
+		if a.Stack.Name != b.Stack.Name {
+			return a.Stack.Name < b.Stack.Name
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Name < b.Name
+	})
+	for _, result := range s.results {
+		s.Reporter.Add(result)
+	}
+	s.results = nil
+	s.Reporter.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestSortedByDefault writes a csv report with the default options, flushed every resource as
+// the scan flushes it, the resources being added out of order
+func TestSortedByDefault(t *testing.T) {
+	policy, err := loadPolicy(context.Background(), aws.Config{}, "")
+	if err == nil {
+		err = policy.apply(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "sorted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "report.csv")
+	options, err := parseOptions(ioutil.Discard, []string{"-output", path, "golden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := newReporter(options, "aws", goldenAccount, goldenRegion, nil, nil)
+	arn := newArnResolver("aws", goldenRegion, goldenAccount)
+	names := []string{"golden-c", "golden-a", "golden-b"}
+	for _, name := range names {
+		resource := Resource{Type: "AWS::SQS::Queue", Name: name, Stack: Stack{Name: "golden-stack"}}
+		report.Add(newResult(resource, "golden", nil, arn, goldenAccount, goldenRegion))
+		report.Write()
+	}
+	report.Close()
+
+	csvReport, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := bytes.Index(csvReport, []byte("golden-a")), bytes.Index(csvReport, []byte("golden-b")), bytes.Index(csvReport, []byte("golden-c"))
+	if a < 0 || !(a < b && b < c) {
+		t.Errorf("the resources are not sorted by name:\n%s", csvReport)
+	}
+}
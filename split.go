@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// splitReporter writes a separate report per search term, stack or value of a tag:<key>,
+// each created on the first resource of its slice so every team receives only its own
+// resources; the resources without the tag make the slice of routeUnowned
+type splitReporter struct {
+	by          string
+	newReporter func(key string) Reporter
+	reporters   map[string]Reporter
+	keys        []string
+}
+
+func newSplitReporter(by string, newReporter func(key string) Reporter) *splitReporter {
+	return &splitReporter{
+		by:          by,
+		newReporter: newReporter,
+		reporters:   make(map[string]Reporter),
+	}
+}
+
+func (s *splitReporter) get(result Result) Reporter {
+	key := result.Resource.Stack.Name
+	if s.by == "search" {
+		key = result.Search
+	} else if tagKey := routeKey(s.by); tagKey != "" {
+		key = routeUnowned
+		if value, ok := lookupTag(result.Tags, tagKey); ok && strings.TrimSpace(value) != "" {
+			key = strings.TrimSpace(value)
+		}
+	}
+	report, ok := s.reporters[key]
+	if !ok {
+		report = s.newReporter(key)
+		s.reporters[key] = report
+		s.keys = append(s.keys, key)
+	}
+	return report
+}
+
+func (s *splitReporter) Add(result Result) {
+	s.get(result).Add(result)
+}
+
+func (s *splitReporter) Write() {
+	for _, key := range s.keys {
+		s.reporters[key].Write()
+	}
+}
+
+func (s *splitReporter) Close() {
+	for _, key := range s.keys {
+		s.reporters[key].Close()
+	}
+}
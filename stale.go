@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+)
+
+// statusStaleReference marks the resources of a stack which no longer exist, e.g. replaced
+// outside of cloudformation
+const statusStaleReference = "STALE_REFERENCE"
+
+// notFoundErrorCodes are the error codes of the tag apis of the supported services for the
+// resources which do not exist
+var notFoundErrorCodes = map[string]bool{
+	"AcceleratorNotFoundException":     true, // globalaccelerator
+	"EntityNotFoundException":          true, // glue
+	"InvalidGroup.NotFound":            true, // ec2
+	"InvalidLaunchTemplateId.NotFound": true,
+	"InvalidResourceId":                true, // ssm
+	"InvalidRouteTableID.NotFound":     true,
+	"InvalidSubnetID.NotFound":         true,
+	"InvalidVpcID.NotFound":            true,
+	"NoSuchBucket":                     true, // s3
+	"NoSuchEntity":                     true, // iam
+	"NoSuchHostedZone":                 true, // route53
+	"NoSuchResource":                   true, // cloudfront
+	"NotFound":                         true, // sns
+	"NotFoundException":                true, // kms
+	"ResourceNotFoundException":        true, // config, dynamodb, events, firehose, lambda, logs, service catalog
+	"ResourceNotFound":                 true, // cloudwatch
+}
+
+// isNotFound tells whether a lookup failed as the resource does not exist, cloudformation
+// telling so by a ValidationError
+func isNotFound(err error) bool {
+	var ae awserr.Error
+	if !errors.As(err, &ae) {
+		return false
+	}
+	return notFoundErrorCodes[ae.Code()] ||
+		(ae.Code() == "ValidationError" && strings.Contains(ae.Message(), "does not exist"))
+}
+
+// StaleReferenceError is the error of a resource of a stack whose physical resource no
+// longer exists, reported as STALE_REFERENCE
+type StaleReferenceError struct {
+	Type string
+	Id   string
+	Err  error
+}
+
+func (e *StaleReferenceError) Error() string {
+	return fmt.Sprintf("%s: %s %s no longer exists, %v", statusStaleReference, e.Type, e.Id, e.Err)
+}
+
+// errorStatus is the status of a resource whose tags could not be looked up for a known
+// reason, SKIPPED_PERMISSION or STALE_REFERENCE, empty otherwise
+func errorStatus(err error) string {
+	var stale *StaleReferenceError
+	if errors.As(err, &stale) {
+		return statusStaleReference
+	} else if isStackSkipped(err) {
+		return statusSkippedPermission
+	}
+	return ""
+}
+
+// stackHygiene lists the stale references of each stack along with the skipped stacks,
+// written to w on Close when there are any
+type stackHygiene struct {
+	w       io.Writer
+	stale   map[string][]string
+	skipped []string
+}
+
+func newStackHygiene(w io.Writer) *stackHygiene {
+	return &stackHygiene{w: w, stale: make(map[string][]string)}
+}
+
+// Add lists the stale references and the skipped stacks, the other results being left out
+func (h *stackHygiene) Add(result Result) {
+	resource := result.Resource
+	switch errorStatus(result.Err) {
+	case statusStaleReference:
+		h.stale[resource.Stack.Name] = append(h.stale[resource.Stack.Name], resource.Type+" "+resource.Name)
+	case statusSkippedPermission:
+		h.skipped = append(h.skipped, resource.Stack.Name)
+	}
+}
+
+// Write is a no-op, the summary is written on Close
+func (h *stackHygiene) Write() {
+}
+
+func (h *stackHygiene) Close() {
+	if len(h.stale) == 0 && len(h.skipped) == 0 {
+		return
+	}
+	count := 0
+	var stacks []string
+	for stack, resources := range h.stale {
+		stacks = append(stacks, stack)
+		count += len(resources)
+	}
+	sort.Strings(stacks)
+	fmt.Fprintf(h.w, "stack hygiene: %d stale references in %d stacks, %d stacks skipped for lack of permission\n",
+		count, len(stacks), len(h.skipped))
+	for _, stack := range stacks {
+		fmt.Fprintf(h.w, "  %s: %s\n", stack, strings.Join(h.stale[stack], ", "))
+	}
+	if len(h.skipped) > 0 {
+		sort.Strings(h.skipped)
+		fmt.Fprintf(h.w, "  skipped: %s\n", strings.Join(h.skipped, ", "))
+	}
+}
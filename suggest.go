@@ -0,0 +1,94 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tagSuggester suggests the values of the required keys a resource misses from its stack and
+// the other resources of the stack, every resource being observed before suggesting
+type tagSuggester struct {
+	// values counts the values of each key by stack
+	values map[string]map[string]map[string]int
+}
+
+func newTagSuggester() *tagSuggester {
+	return &tagSuggester{values: make(map[string]map[string]map[string]int)}
+}
+
+// observe counts the tag values of a resource within its stack
+func (s *tagSuggester) observe(resource Resource, tags map[string]string) {
+	counts, ok := s.values[resource.Stack.Name]
+	if !ok {
+		counts = make(map[string]map[string]int)
+		s.values[resource.Stack.Name] = counts
+	}
+	for key, value := range tags {
+		if counts[key] == nil {
+			counts[key] = make(map[string]int)
+		}
+		counts[key][value]++
+	}
+}
+
+// suggestions are the values suggested for the required keys a resource misses or carries an
+// invalid value of, the keys without a suggestion being left out
+func (s *tagSuggester) suggestions(result Result) map[string]string {
+	suggestions := make(map[string]string)
+	for _, key := range result.Finding.remediationKeys() {
+		if value := s.suggest(key, result.Resource); value != "" {
+			suggestions[key] = value
+		}
+	}
+	return suggestions
+}
+
+var stackNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// suggest infers the value of a key from the tags of the stack, the value the other resources
+// of the stack carry most, the Organizations tags of the account with -account-tags, the
+// environment of the stack or a word of the stack name allowed as the value, a suggestion
+// having to be valid
+func (s *tagSuggester) suggest(key string, resource Resource) string {
+	rule := valueRules[key]
+	valid := func(value string) bool {
+		return value != "" && (rule == nil || rule.valid(value))
+	}
+
+	if value, ok := lookupTag(resource.Stack.Tags, key); ok && valid(value) {
+		return value
+	}
+	counts := s.values[resource.Stack.Name][key]
+	var values []string
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	for _, value := range values {
+		if valid(value) {
+			return value
+		}
+	}
+	if value, ok := lookupTag(accountTags, key); ok && valid(value) {
+		return value
+	}
+	if containsString(environmentTags, key) && valid(resource.Stack.Environment) {
+		return resource.Stack.Environment
+	}
+	if rule != nil {
+		for _, word := range stackNameSeparators.Split(resource.Stack.Name, -1) {
+			for _, allowed := range rule.Allowed {
+				if strings.EqualFold(word, allowed) && valid(allowed) {
+					return allowed
+				}
+			}
+		}
+	}
+	return ""
+}
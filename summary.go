@@ -0,0 +1,105 @@
+package main
+
+import "sort"
+
+// stackSummary accumulates the coverage of the resources of a stack
+type stackSummary struct {
+	resources       int
+	notSupported    int
+	errors          int
+	classicCoverage int
+	modernCoverage  int
+	compliant       int
+}
+
+func (s *stackSummary) averageClassic() int {
+	if s.resources == 0 {
+		return 0
+	}
+	return s.classicCoverage / s.resources
+}
+
+func (s *stackSummary) averageModern() int {
+	if s.resources == 0 {
+		return 0
+	}
+	return s.modernCoverage / s.resources
+}
+
+func (s *stackSummary) noncompliant() int {
+	return s.resources - s.compliant
+}
+
+// stackSummaries holds the summary of each stack by name
+type stackSummaries map[string]*stackSummary
+
+func (s stackSummaries) get(name string) *stackSummary {
+	summary, ok := s[name]
+	if !ok {
+		summary = &stackSummary{}
+		s[name] = summary
+	}
+	return summary
+}
+
+// add accounts a resource whose tags were looked up
+func (s stackSummaries) add(name string, classicCoverage int, modernCoverage int, compliant bool) {
+	summary := s.get(name)
+	summary.resources++
+	summary.classicCoverage += classicCoverage
+	summary.modernCoverage += modernCoverage
+	if compliant {
+		summary.compliant++
+	}
+}
+
+// names returns the stack names in order
+func (s stackSummaries) names() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// total sums up every stack
+func (s stackSummaries) total() *stackSummary {
+	total := &stackSummary{}
+	for _, summary := range s {
+		total.resources += summary.resources
+		total.notSupported += summary.notSupported
+		total.errors += summary.errors
+		total.classicCoverage += summary.classicCoverage
+		total.modernCoverage += summary.modernCoverage
+		total.compliant += summary.compliant
+	}
+	return total
+}
+
+// totalsReporter accounts every resource of the run in a single summary
+type totalsReporter struct {
+	totals *stackSummary
+}
+
+func (t totalsReporter) Add(result Result) {
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		t.totals.errors++
+	case finding == nil:
+		t.totals.notSupported++
+	default:
+		t.totals.resources++
+		t.totals.classicCoverage += finding.ClassicCoverage
+		t.totals.modernCoverage += finding.ModernCoverage
+		if finding.compliant() {
+			t.totals.compliant++
+		}
+	}
+}
+
+func (t totalsReporter) Write() {
+}
+
+func (t totalsReporter) Close() {
+}
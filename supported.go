@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// supportedType is a resource type as listed by list-supported
+type supportedType struct {
+	Type      string `json:"type"`
+	Supported bool   `json:"supported"`
+	API       string `json:"api,omitempty"`
+}
+
+// listSupported runs the list-supported subcommand: list-supported [-format table|json],
+// listing the resource types whose tags are looked up, with the api looking them up, and
+// the types known not to support tags
+func listSupported(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report list-supported", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "table", "output format, table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report list-supported [options]"+
+			"\n\tlists the resource types whose tags are looked up along with the api used, and the types"+
+			"\n\tknown not to support tags; the other types stop the scan, Custom:: types being unsupported"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q, expected table or json", *format)
+	}
+
+	var types []supportedType
+	for resourceType, lookup := range newLookups(aws.Config{}, "aws", "", "") {
+		types = append(types, supportedType{resourceType, lookup.api != "", lookup.api})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Supported != types[j].Supported {
+			return types[i].Supported
+		}
+		return types[i].Type < types[j].Type
+	})
+
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(types)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TYPE\tTAGS\tAPI")
+	for _, t := range types {
+		tags, api := "supported", t.API
+		if !t.Supported {
+			tags, api = "not supported", "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", t.Type, tags, api)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroups"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+)
+
+// the results of a change of the tags of a resource
+const (
+	taggerDryRun   = "DRY_RUN"
+	taggerScripted = "SCRIPTED"
+	taggerOK       = "OK"
+	taggerFailed   = "FAILED"
+)
+
+// taggerFlags are the flags of the subcommands changing tags
+type taggerFlags struct {
+	dryRun bool
+	rate   int
+	log    string
+	script string
+}
+
+// register adds the flags to fs, action telling what is done to the tags
+func (f *taggerFlags) register(fs *flag.FlagSet, action string) {
+	fs.BoolVar(&f.dryRun, "dry-run", false, "only list the tags that would be "+action)
+	fs.IntVar(&f.rate, "rate", 5, "maximum tagging calls a second")
+	fs.StringVar(&f.log, "log", "", "append the csv log of the changes to this file instead of writing it to stdout")
+	fs.StringVar(&f.script, "emit-script", "",
+		"write the aws cli commands making the changes to this shell script instead of making them, for review")
+}
+
+func (f *taggerFlags) validate() error {
+	if f.rate < 1 {
+		return fmt.Errorf("invalid -rate %d", f.rate)
+	} else if f.dryRun && f.script != "" {
+		return fmt.Errorf("-dry-run and -emit-script are mutually exclusive")
+	}
+	return nil
+}
+
+// open creates the Tagger of the flags logging to w or the -log file, along with the function
+// closing its files
+func (f *taggerFlags) open(ctx context.Context, config aws.Config, w io.Writer, dialect CSVDialect) (*Tagger, func(), error) {
+	log, appending, err := openTaggerLog(w, f.log)
+	if err != nil {
+		return nil, nil, err
+	}
+	tagger := &Tagger{
+		ctx:      ctx,
+		tagging:  resourcegroupstaggingapi.New(config),
+		iam:      iam.New(config),
+		groups:   resourcegroups.New(config),
+		w:        newCSVWriter(log, dialect),
+		dryRun:   f.dryRun,
+		throttle: time.Tick(time.Second / time.Duration(f.rate)),
+	}
+	if !appending {
+		tagger.log([]string{"Time", "ARN", "Action", "Tags", "Result", "Error"})
+	}
+	if f.script == "" {
+		return tagger, func() { log.Close() }, nil
+	}
+
+	script, err := os.OpenFile(f.script, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		log.Close()
+		return nil, nil, err
+	}
+	tagger.script = bufio.NewWriter(script)
+	fmt.Fprintf(tagger.script, "#!/bin/sh\n# tag changes generated by aws-tag-report on %s\nset -e\n\n",
+		time.Now().UTC().Format(time.RFC3339))
+	return tagger, func() {
+		err := tagger.script.Flush()
+		if closeErr := script.Close(); err == nil {
+			err = closeErr
+		}
+		log.Close()
+		if err != nil {
+			panic(err.Error())
+		}
+	}, nil
+}
+
+// Tagger adds and removes the tags of resources by ARN through the resource groups tagging
+// api, or the IAM api for roles which it does not support, and puts resource groups, at
+// most rate calls a second.
+// Every change is written to w as a csv row, the tags being left alone when dry running,
+// or the aws cli command making the change written to the script instead
+type Tagger struct {
+	ctx      context.Context
+	tagging  *resourcegroupstaggingapi.Client
+	iam      *iam.Client
+	groups   *resourcegroups.Client
+	w        *csv.Writer
+	dryRun   bool
+	script   *bufio.Writer
+	throttle <-chan time.Time
+	// failed counts the changes that failed
+	failed int
+}
+
+// tag adds the tags to the resource, returning whether it succeeded
+func (t *Tagger) tag(arn string, tags map[string]string) bool {
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		panic(err.Error())
+	}
+	role := iamRoleName(arn)
+	var roleTags []iam.Tag
+	for _, key := range sortedTagKeys(tags) {
+		roleTags = append(roleTags, iam.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	command := []string{"aws", "resourcegroupstaggingapi", "tag-resources", "--resource-arn-list", arn, "--tags", string(encoded)}
+	if role != "" {
+		encodedRoleTags, err := json.Marshal(roleTags)
+		if err != nil {
+			panic(err.Error())
+		}
+		command = []string{"aws", "iam", "tag-role", "--role-name", role, "--tags", string(encodedRoleTags)}
+	}
+	return t.change(arn, "TAG", string(encoded), command, func() error {
+		if role != "" {
+			_, err := t.iam.TagRoleRequest(&iam.TagRoleInput{RoleName: aws.String(role), Tags: roleTags}).Send(t.ctx)
+			return err
+		}
+		response, err := t.tagging.TagResourcesRequest(&resourcegroupstaggingapi.TagResourcesInput{
+			ResourceARNList: []string{arn},
+			Tags:            tags,
+		}).Send(t.ctx)
+		if err != nil {
+			return err
+		}
+		return taggingFailure(response.FailedResourcesMap, arn)
+	})
+}
+
+// untag removes the tag keys from the resource, returning whether it succeeded
+func (t *Tagger) untag(arn string, keys []string) bool {
+	role := iamRoleName(arn)
+	command := append([]string{"aws", "resourcegroupstaggingapi", "untag-resources", "--resource-arn-list", arn, "--tag-keys"}, keys...)
+	if role != "" {
+		command = append([]string{"aws", "iam", "untag-role", "--role-name", role, "--tag-keys"}, keys...)
+	}
+	return t.change(arn, "UNTAG", strings.Join(keys, ","), command, func() error {
+		if role != "" {
+			_, err := t.iam.UntagRoleRequest(&iam.UntagRoleInput{RoleName: aws.String(role), TagKeys: keys}).Send(t.ctx)
+			return err
+		}
+		response, err := t.tagging.UntagResourcesRequest(&resourcegroupstaggingapi.UntagResourcesInput{
+			ResourceARNList: []string{arn},
+			TagKeys:         keys,
+		}).Send(t.ctx)
+		if err != nil {
+			return err
+		}
+		return taggingFailure(response.FailedResourcesMap, arn)
+	})
+}
+
+// group creates the resource group of the tag filters query, or updates the query of the
+// existing group, returning whether it succeeded
+func (t *Tagger) group(name string, query string) bool {
+	_, err := t.groups.GetGroupRequest(&resourcegroups.GetGroupInput{GroupName: aws.String(name)}).Send(t.ctx)
+	var ae awserr.Error
+	exists := err == nil
+	if err != nil && !(errors.As(err, &ae) && ae.Code() == resourcegroups.ErrCodeNotFoundException) {
+		panic(err.Error())
+	}
+	resourceQuery := &resourcegroups.ResourceQuery{Type: resourcegroups.QueryTypeTagFilters10, Query: aws.String(query)}
+	encoded, err := json.Marshal(map[string]string{"Type": string(resourceQuery.Type), "Query": query})
+	if err != nil {
+		panic(err.Error())
+	}
+	if exists {
+		command := []string{"aws", "resource-groups", "update-group-query", "--group-name", name, "--resource-query", string(encoded)}
+		return t.change(name, "UPDATE_GROUP", query, command, func() error {
+			_, err := t.groups.UpdateGroupQueryRequest(&resourcegroups.UpdateGroupQueryInput{
+				GroupName:     aws.String(name),
+				ResourceQuery: resourceQuery,
+			}).Send(t.ctx)
+			return err
+		})
+	}
+	command := []string{"aws", "resource-groups", "create-group", "--name", name, "--resource-query", string(encoded)}
+	return t.change(name, "CREATE_GROUP", query, command, func() error {
+		_, err := t.groups.CreateGroupRequest(&resourcegroups.CreateGroupInput{
+			Name:          aws.String(name),
+			ResourceQuery: resourceQuery,
+		}).Send(t.ctx)
+		return err
+	})
+}
+
+// change runs a change, or writes its command to the script, unless dry running, logging
+// its result
+func (t *Tagger) change(arn string, action string, tags string, command []string, apply func() error) bool {
+	result, message := taggerDryRun, ""
+	if t.script != nil {
+		result = taggerScripted
+		if region := arnRegion(arn); region != "" {
+			command = append(command, "--region", region)
+		}
+		for i, arg := range command {
+			command[i] = shellQuote(arg)
+		}
+		fmt.Fprintln(t.script, strings.Join(command, " "))
+	} else if !t.dryRun {
+		<-t.throttle
+		result = taggerOK
+		if err := apply(); err != nil {
+			result, message = taggerFailed, err.Error()
+			t.failed++
+		}
+	}
+	t.log([]string{time.Now().UTC().Format(time.RFC3339), arn, action, tags, result, message})
+	return result != taggerFailed
+}
+
+func (t *Tagger) log(row []string) {
+	t.w.Write(row)
+	// each change is flushed so the log is complete whatever happens next
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		panic(err.Error())
+	}
+}
+
+// taggingFailure returns the failure of the resource reported by the tagging api, if any
+func taggingFailure(failures map[string]resourcegroupstaggingapi.FailureInfo, arn string) error {
+	if failure, ok := failures[arn]; ok {
+		return fmt.Errorf("%s: %s", failure.ErrorCode, aws.StringValue(failure.ErrorMessage))
+	}
+	return nil
+}
+
+// arnRegion returns the region of an ARN, empty for the global services
+func arnRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 {
+		return ""
+	}
+	return parts[3]
+}
+
+// shellQuote quotes an argument of a shell command when needed
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@,") == "" {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+// iamRoleName returns the name of the role of an IAM role ARN, empty for other ARNs
+func iamRoleName(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "iam" || !strings.HasPrefix(parts[5], "role/") {
+		return ""
+	}
+	return parts[5][strings.LastIndex(parts[5], "/")+1:]
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+)
+
+// launchPath is a product of a portfolio, as its end users launch it, checked by tag-options
+type launchPath struct {
+	Portfolio     string `json:"portfolio"`
+	PortfolioName string `json:"portfolioName"`
+	Product       string `json:"product"`
+	ProductName   string `json:"productName"`
+	// LaunchRole is the role of the launch constraint of the product in the portfolio, the
+	// products without one being launched with the permissions of the end users
+	LaunchRole string `json:"launchRole,omitempty"`
+	// Missing are the modern keys without a TagOption in the portfolio or the product, which
+	// the provisioned resources may not carry
+	Missing []string `json:"missing,omitempty"`
+	// Invalid are the TagOptions as key=value whose value the tag policy rejects, which the
+	// end users may pick
+	Invalid   []string `json:"invalid,omitempty"`
+	Compliant bool     `json:"compliant"`
+}
+
+// tagOptions runs the tag-options subcommand: tag-options [-portfolio id] [-tag-policy file]
+// [-format table|json], checking the TagOptions of the products of the Service Catalog
+// portfolios against the modern scheme before anything is provisioned, the launch paths
+// that would provision non-compliant resources failing the command
+func tagOptions(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report tag-options", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	portfolio := fs.String("portfolio", "", "id of the portfolio to check, defaults to every portfolio of the account")
+	tagPolicy := fs.String("tag-policy", "",
+		"yaml or json file defining the required tag schemes, or ssm:<parameter name> or "+
+			"appconfig:<application>/<environment>/<profile>, defaults to the embedded policy.yaml")
+	format := fs.String("format", "table", "output format, table or json")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report tag-options [options]"+
+			"\n\tchecks the TagOptions of the portfolios and their products against the modern scheme: the keys"+
+			"\n\twithout a TagOption and the TagOption values the tag policy rejects would provision non-compliant"+
+			"\n\tresources, exiting with status 1"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q, expected table or json", *format)
+	}
+
+	cfg, err := external.LoadDefaultAWSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	ctx := context.TODO()
+	policy, err := loadPolicy(ctx, cfg, *tagPolicy)
+	if err == nil {
+		err = policy.apply(nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	client := servicecatalog.New(cfg)
+	var portfolios []string
+	if *portfolio != "" {
+		portfolios = []string{*portfolio}
+	} else if portfolios, err = listPortfolios(ctx, client); err != nil {
+		return err
+	}
+	var paths []launchPath
+	for _, id := range portfolios {
+		found, err := portfolioLaunchPaths(ctx, client, id)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, found...)
+	}
+
+	if err := writeLaunchPaths(w, paths, *format); err != nil {
+		return err
+	}
+	var noncompliant int
+	for _, path := range paths {
+		if !path.Compliant {
+			noncompliant++
+		}
+	}
+	if noncompliant > 0 {
+		return fmt.Errorf("%d of %d launch paths would provision non-compliant resources", noncompliant, len(paths))
+	}
+	return nil
+}
+
+// listPortfolios lists the ids of the portfolios of the account
+func listPortfolios(ctx context.Context, client *servicecatalog.Client) ([]string, error) {
+	var portfolios []string
+	var token *string
+	for {
+		response, err := client.ListPortfoliosRequest(&servicecatalog.ListPortfoliosInput{PageToken: token}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, portfolio := range response.PortfolioDetails {
+			portfolios = append(portfolios, aws.StringValue(portfolio.Id))
+		}
+		if token = response.NextPageToken; token == nil {
+			return portfolios, nil
+		}
+	}
+}
+
+// portfolioLaunchPaths checks the products of a portfolio, the TagOptions of the portfolio
+// and of the product both being offered at launch
+func portfolioLaunchPaths(ctx context.Context, client *servicecatalog.Client, id string) ([]launchPath, error) {
+	portfolio, err := client.DescribePortfolioRequest(&servicecatalog.DescribePortfolioInput{Id: aws.String(id)}).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var name string
+	if portfolio.PortfolioDetail != nil {
+		name = aws.StringValue(portfolio.PortfolioDetail.DisplayName)
+	}
+	roles, err := launchRoles(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []launchPath
+	var token *string
+	for {
+		response, err := client.SearchProductsAsAdminRequest(&servicecatalog.SearchProductsAsAdminInput{
+			PortfolioId: aws.String(id),
+			PageToken:   token,
+		}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, view := range response.ProductViewDetails {
+			if view.ProductViewSummary == nil {
+				continue
+			}
+			productId := aws.StringValue(view.ProductViewSummary.ProductId)
+			product, err := client.DescribeProductAsAdminRequest(&servicecatalog.DescribeProductAsAdminInput{
+				Id: aws.String(productId),
+			}).Send(ctx)
+			if err != nil {
+				return nil, err
+			}
+			path := launchPath{
+				Portfolio:     id,
+				PortfolioName: name,
+				Product:       productId,
+				ProductName:   aws.StringValue(view.ProductViewSummary.Name),
+				LaunchRole:    roles[productId],
+			}
+			path.check(append(append([]servicecatalog.TagOptionDetail{}, portfolio.TagOptions...), product.TagOptions...))
+			paths = append(paths, path)
+		}
+		if token = response.NextPageToken; token == nil {
+			return paths, nil
+		}
+	}
+}
+
+// check compares the active TagOptions of a launch path with the keys the modern scheme
+// requires on the provisioned products, the keys of the resource types and environments
+// being unknown before provisioning
+func (p *launchPath) check(options []servicecatalog.TagOptionDetail) {
+	offered := make(map[string]bool)
+	invalid := make(map[string]bool)
+	for _, option := range options {
+		if !aws.BoolValue(option.Active) {
+			continue
+		}
+		key, value := aws.StringValue(option.Key), aws.StringValue(option.Value)
+		offered[key] = true
+		if rule := valueRules[key]; rule != nil && !rule.valid(value) {
+			invalid[key+"="+value] = true
+		}
+	}
+	for _, key := range modern.required(Resource{Type: "AWS::ServiceCatalog::CloudFormationProvisionedProduct"}) {
+		if !offered[key] {
+			p.Missing = append(p.Missing, key)
+		}
+	}
+	for option := range invalid {
+		p.Invalid = append(p.Invalid, option)
+	}
+	sort.Strings(p.Invalid)
+	p.Compliant = len(p.Missing) == 0 && len(p.Invalid) == 0
+}
+
+// launchRoles maps the products of a portfolio to the role of their launch constraint
+func launchRoles(ctx context.Context, client *servicecatalog.Client, portfolio string) (map[string]string, error) {
+	roles := make(map[string]string)
+	var token *string
+	for {
+		response, err := client.ListConstraintsForPortfolioRequest(&servicecatalog.ListConstraintsForPortfolioInput{
+			PortfolioId: aws.String(portfolio),
+			PageToken:   token,
+		}).Send(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, constraint := range response.ConstraintDetails {
+			if aws.StringValue(constraint.Type) != "LAUNCH" {
+				continue
+			}
+			described, err := client.DescribeConstraintRequest(&servicecatalog.DescribeConstraintInput{
+				Id: constraint.ConstraintId,
+			}).Send(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// the launch role is named by its ARN, or by its name in the account of the launch
+			var parameters struct {
+				RoleArn       string
+				LocalRoleName string
+			}
+			json.Unmarshal([]byte(aws.StringValue(described.ConstraintParameters)), &parameters)
+			role := parameters.RoleArn
+			if role == "" {
+				role = parameters.LocalRoleName
+			}
+			roles[aws.StringValue(constraint.ProductId)] = role
+		}
+		if token = response.NextPageToken; token == nil {
+			return roles, nil
+		}
+	}
+}
+
+func writeLaunchPaths(w io.Writer, paths []launchPath, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(paths)
+	}
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PORTFOLIO\tPRODUCT\tLAUNCH ROLE\tCOMPLIANT\tMISSING\tINVALID")
+	for _, path := range paths {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%t\t%s\t%s\n", path.Portfolio, path.Product, orDash(path.LaunchRole),
+			path.Compliant, orDash(strings.Join(path.Missing, ",")), orDash(strings.Join(path.Invalid, ",")))
+	}
+	return table.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
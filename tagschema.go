@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// TagSchema validates the tags of each resource, as a json object of keys to values,
+// against a JSON Schema, for required keys, value patterns and conditional requirements
+// (if/then) in a standard format
+type TagSchema struct {
+	schema *gojsonschema.Schema
+}
+
+func loadTagSchema(path string) (*TagSchema, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return nil, fmt.Errorf("tag schema %s: %v", path, err)
+	}
+	return &TagSchema{schema: schema}, nil
+}
+
+// violations returns a message per schema constraint the tags break, naming the tag key
+// at fault
+func (s *TagSchema) violations(tags map[string]string) []string {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	result, err := s.schema.Validate(gojsonschema.NewGoLoader(tags))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	var messages []string
+	for _, violation := range result.Errors() {
+		// the errors of a conditional are reported on their own after it
+		if violation.Type() == "condition_then" || violation.Type() == "condition_else" {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", violation.Field(), violation.Description()))
+	}
+	sort.Strings(messages)
+	return messages
+}
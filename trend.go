@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// the trends of the coverage of a stack
+const (
+	trendDown = "DOWN"
+	trendUp   = "UP"
+	trendFlat = "FLAT"
+)
+
+//go:embed templates/trend.html
+var trendTemplate string
+
+var trendReport = template.Must(template.New("trend").Parse(trendTemplate))
+
+// trendKey is a series of the trend, the coverage of a stack under a scheme
+type trendKey struct {
+	Stack  string
+	Scheme string
+}
+
+// trendDay is the average coverage of each stack and scheme on a day
+type trendDay struct {
+	date string
+	sums map[trendKey][2]int
+}
+
+func (d *trendDay) add(stack string, scheme string, coverage *int) {
+	if coverage == nil {
+		return
+	}
+	key := trendKey{stack, scheme}
+	sum := d.sums[key]
+	d.sums[key] = [2]int{sum[0] + *coverage, sum[1] + 1}
+}
+
+func (d *trendDay) average(key trendKey) *int {
+	sum, ok := d.sums[key]
+	if !ok {
+		return nil
+	}
+	average := sum[0] / sum[1]
+	return &average
+}
+
+// trendSeries is the coverage over time of a stack under a scheme, nil on the days the
+// stack was not scanned
+type trendSeries struct {
+	Stack    string
+	Scheme   string
+	Coverage []*int
+	Change   int
+	Trend    string
+}
+
+// trend runs the trend subcommand: trend [-history-table name | -dir path] [options], the
+// coverage of each stack over the runs recorded with -history-table or the reports of a directory
+func trend(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report trend", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	table := fs.String("history-table", "", "DynamoDB table the runs were recorded in with -history-table")
+	dir := fs.String("dir", "", "directory of past csv, json or jsonl reports, optionally gzip compressed, dated by their modification time")
+	search := fs.String("search", "", "only the runs of the history table whose search string is this")
+	days := fs.Int("days", 30, "number of most recent days shown")
+	format := fs.String("format", "csv", "output format, csv or html")
+	delimiter := fs.String("csv-delimiter", ",", "csv field delimiter of the reports and of the output, a single character or \"tab\"")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report trend [-history-table name | -dir path] [options]"+
+			"\n\tlists the average coverage of each stack and scheme per day, the stacks trending downward first"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*table == "") == (*dir == "") {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *format != "csv" && *format != "html" {
+		return fmt.Errorf("invalid -format %q, expected csv or html", *format)
+	} else if *days < 2 {
+		return fmt.Errorf("invalid -days %d, expected at least 2", *days)
+	} else if *search != "" && *dir != "" {
+		return fmt.Errorf("-search only applies to -history-table")
+	}
+	var dialect CSVDialect
+	var err error
+	if dialect.Delimiter, err = parseDelimiter(*delimiter); err != nil {
+		return err
+	}
+
+	var history []*trendDay
+	if *table != "" {
+		cfg, err := external.LoadDefaultAWSConfig()
+		if err != nil {
+			return fmt.Errorf("unable to load SDK config, %v", err)
+		}
+		history = tableTrend(context.TODO(), dynamodb.New(cfg), *table, *search, *days)
+	} else if history, err = dirTrend(*dir, dialect, *days); err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf("no runs found")
+	}
+	series := trendSeriesOf(history)
+
+	down := 0
+	for _, s := range series {
+		if s.Trend == trendDown {
+			down++
+		}
+	}
+	fmt.Fprintf(stderr, "%d days, %d stacks trending downward\n", len(history), down)
+
+	if *format == "html" {
+		return writeTrendHTML(w, history, series)
+	}
+	writer := newCSVWriter(w, dialect)
+	header := []string{"Stack Name", "Scheme"}
+	for _, day := range history {
+		header = append(header, day.date)
+	}
+	writer.Write(append(header, "Change", "Trend"))
+	for _, s := range series {
+		row := []string{s.Stack, s.Scheme}
+		for _, coverage := range s.Coverage {
+			row = append(row, formatCoverage(coverage))
+		}
+		writer.Write(append(row, strconv.Itoa(s.Change), s.Trend))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// tableTrend averages the coverage recorded in the history table per day, the last run of
+// each account, region and search of the day counting
+func tableTrend(ctx context.Context, client *dynamodb.Client, table string, search string, days int) []*trendDay {
+	latest := make(map[string]HistoryRun)
+	for _, run := range getHistoryRuns(ctx, client, table) {
+		if search != "" && run.Search != search {
+			continue
+		}
+		// the runs are by time, the last one of the day overwriting the others
+		latest[strings.Join([]string{run.Timestamp[:10], run.Account, run.Region, run.Search}, "\x00")] = run
+	}
+	byDate := make(map[string][]HistoryRun)
+	var dates []string
+	for _, run := range latest {
+		date := run.Timestamp[:10]
+		if _, ok := byDate[date]; !ok {
+			dates = append(dates, date)
+		}
+		byDate[date] = append(byDate[date], run)
+	}
+	sort.Strings(dates)
+	if len(dates) > days {
+		dates = dates[len(dates)-days:]
+	}
+
+	var history []*trendDay
+	for _, date := range dates {
+		day := &trendDay{date: date, sums: make(map[trendKey][2]int)}
+		for _, run := range byDate[date] {
+			for _, resource := range getHistoryResources(ctx, client, table, run.Id) {
+				if resource.Status == "OK" {
+					day.add(resource.Stack, "classic", resource.ClassicCoverage)
+					day.add(resource.Stack, "modern", resource.ModernCoverage)
+				}
+			}
+		}
+		history = append(history, day)
+	}
+	return history
+}
+
+// dirTrend averages the modern coverage of the reports of a directory per day, the last
+// report of the day counting
+func dirTrend(dir string, dialect CSVDialect, days int) ([]*trendDay, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]string)
+	modified := make(map[string]time.Time)
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".gz")
+		if file.IsDir() || !(strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl")) {
+			continue
+		}
+		date := file.ModTime().UTC().Format("2006-01-02")
+		if file.ModTime().After(modified[date]) {
+			latest[date] = filepath.Join(dir, file.Name())
+			modified[date] = file.ModTime()
+		}
+	}
+	var dates []string
+	for date := range latest {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	if len(dates) > days {
+		dates = dates[len(dates)-days:]
+	}
+
+	var history []*trendDay
+	for _, date := range dates {
+		baseline, err := loadBaseline(latest[date], dialect)
+		if err != nil {
+			return nil, err
+		}
+		day := &trendDay{date: date, sums: make(map[trendKey][2]int)}
+		for key, resource := range baseline.resources {
+			day.add(resource.Stack, "modern", baseline.coverage[key])
+		}
+		history = append(history, day)
+	}
+	return history, nil
+}
+
+// trendSeriesOf returns the series of the days, the stacks trending downward first then
+// by stack and scheme
+func trendSeriesOf(history []*trendDay) []trendSeries {
+	keys := make(map[trendKey]bool)
+	for _, day := range history {
+		for key := range day.sums {
+			keys[key] = true
+		}
+	}
+	var series []trendSeries
+	for key := range keys {
+		s := trendSeries{Stack: key.Stack, Scheme: key.Scheme}
+		for _, day := range history {
+			s.Coverage = append(s.Coverage, day.average(key))
+		}
+		s.Change, s.Trend = trendOf(s.Coverage)
+		series = append(series, s)
+	}
+	order := map[string]int{trendDown: 0, trendFlat: 1, trendUp: 2}
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Trend != series[j].Trend {
+			return order[series[i].Trend] < order[series[j].Trend]
+		}
+		if series[i].Stack != series[j].Stack {
+			return series[i].Stack < series[j].Stack
+		}
+		return series[i].Scheme < series[j].Scheme
+	})
+	return series
+}
+
+// trendOf returns the change between the first and the last known coverage, and the trend of
+// the least squares fit of the coverage, downward when it loses a point or more over the days
+func trendOf(coverage []*int) (int, string) {
+	var first, last *int
+	var n, sumX, sumY, sumXY, sumXX float64
+	for i, c := range coverage {
+		if c == nil {
+			continue
+		}
+		if first == nil {
+			first = c
+		}
+		last = c
+		x, y := float64(i), float64(*c)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	if n < 2 {
+		return 0, trendFlat
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	fitted := slope * float64(len(coverage)-1)
+	switch {
+	case fitted <= -1:
+		return *last - *first, trendDown
+	case fitted >= 1:
+		return *last - *first, trendUp
+	default:
+		return *last - *first, trendFlat
+	}
+}
+
+// trendRow is a series of the html page, drawn as a sparkline
+type trendRow struct {
+	trendSeries
+	Points string
+	Last   string
+}
+
+// the size of the sparklines
+const (
+	sparklineWidth  = 200
+	sparklineHeight = 30
+)
+
+func writeTrendHTML(w io.Writer, history []*trendDay, series []trendSeries) error {
+	var rows []trendRow
+	step := float64(sparklineWidth) / float64(len(history)-1)
+	if len(history) == 1 {
+		step = 0
+	}
+	for _, s := range series {
+		row := trendRow{trendSeries: s}
+		var points []string
+		for i, coverage := range s.Coverage {
+			if coverage == nil {
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", float64(i)*step,
+				float64(sparklineHeight)*(1-float64(*coverage)/100)))
+			row.Last = formatCoverage(coverage) + "%"
+		}
+		row.Points = strings.Join(points, " ")
+		rows = append(rows, row)
+	}
+	return trendReport.Execute(w, struct {
+		Generated string
+		From      string
+		To        string
+		Width     int
+		Height    int
+		Rows      []trendRow
+	}{
+		Generated: time.Now().Format(time.RFC1123),
+		From:      history[0].date,
+		To:        history[len(history)-1].date,
+		Width:     sparklineWidth,
+		Height:    sparklineHeight,
+		Rows:      rows,
+	})
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// the views of the terminal UI
+const (
+	tuiStacks = iota
+	tuiResources
+)
+
+// TUI renders the progress of the scan in the terminal, the coverage of each stack as a
+// bar, then lets the results be browsed and filtered once the scan completes
+type TUI struct {
+	in       *os.File
+	out      *os.File
+	stacks   stackSummaries
+	rows     []tuiRow
+	current  string
+	rendered time.Time
+
+	// the state of the browser
+	view      int
+	offset    int
+	selected  int
+	filter    string
+	filtering bool
+}
+
+// tuiRow is a resource of the results table
+type tuiRow struct {
+	resourceType string
+	name         string
+	stack        string
+	coverage     string
+	missing      string
+}
+
+func newTUI(in *os.File, out *os.File) (*TUI, error) {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, fmt.Errorf("-tui requires a terminal")
+	}
+	return &TUI{in: in, out: out, stacks: make(stackSummaries)}, nil
+}
+
+func (t *TUI) Add(result Result) {
+	resource := result.Resource
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		t.stacks.get(resource.Stack.Name).errors++
+		t.add(resource, "error", result.Err.Error())
+	case finding == nil:
+		t.stacks.get(resource.Stack.Name).notSupported++
+		t.add(resource, "n/a", "")
+	default:
+		t.stacks.add(resource.Stack.Name, finding.ClassicCoverage, finding.ModernCoverage, finding.compliant())
+		t.add(resource, fmt.Sprintf("%d%%", finding.ModernCoverage), strings.Join(finding.Missing, ","))
+	}
+}
+
+func (t *TUI) add(resource Resource, coverage string, missing string) {
+	t.rows = append(t.rows, tuiRow{extractType(resource.Type), resource.Name, resource.Stack.Name, coverage, missing})
+	t.current = resource.Stack.Name
+	// the progress is rendered at most every 100ms
+	if time.Since(t.rendered) > 100*time.Millisecond {
+		t.render()
+	}
+}
+
+func (t *TUI) Write() {
+}
+
+// Close renders the complete results, browsed by browse
+func (t *TUI) Close() {
+	t.current = ""
+	t.render()
+}
+
+// browse lets the results be explored until q is pressed
+func (t *TUI) browse() error {
+	state, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(t.in.Fd()), state)
+	defer fmt.Fprint(t.out, "\x1b[?25h\x1b[2J\x1b[H")
+	fmt.Fprint(t.out, "\x1b[?25l")
+
+	key := make([]byte, 16)
+	for {
+		t.render()
+		n, err := t.in.Read(key)
+		if err != nil {
+			return err
+		}
+		if !t.keypress(string(key[:n])) {
+			return nil
+		}
+	}
+}
+
+// keypress handles a key, returning false to quit
+func (t *TUI) keypress(key string) bool {
+	_, height := t.size()
+	page := height - 4
+	if t.filtering {
+		switch key {
+		case "\r", "\n":
+			t.filtering = false
+		case "\x1b":
+			t.filtering = false
+			t.filter = ""
+		case "\x7f", "\b":
+			if t.filter != "" {
+				t.filter = t.filter[:len(t.filter)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= ' ' {
+				t.filter += key
+			}
+		}
+		t.selected, t.offset = 0, 0
+		return true
+	}
+	switch key {
+	case "q", "\x03":
+		return false
+	case "\t":
+		t.view = 1 - t.view
+		t.selected, t.offset = 0, 0
+	case "/":
+		t.filtering = true
+	case "\x1b":
+		t.filter = ""
+	case "k", "\x1b[A":
+		t.selected--
+	case "j", "\x1b[B":
+		t.selected++
+	case "\x1b[5~":
+		t.selected -= page
+	case "\x1b[6~", " ":
+		t.selected += page
+	case "g":
+		t.selected = 0
+	case "G":
+		t.selected = 1 << 30
+	}
+	return true
+}
+
+func (t *TUI) size() (int, int) {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+	return width, height
+}
+
+// lines returns the lines of the current view matching the filter
+func (t *TUI) lines(width int) (string, []string) {
+	filter := strings.ToLower(t.filter)
+	var lines []string
+	if t.view == tuiStacks {
+		names := t.stacks.names()
+		sort.SliceStable(names, func(i, j int) bool {
+			return t.stacks[names[i]].averageModern() < t.stacks[names[j]].averageModern()
+		})
+		nameWidth := width - 46
+		if nameWidth < 10 {
+			nameWidth = 10
+		}
+		for _, name := range names {
+			if !strings.Contains(strings.ToLower(name), filter) {
+				continue
+			}
+			summary := t.stacks[name]
+			lines = append(lines, fmt.Sprintf("%-*s %s %3d%% %5d/%-5d compliant",
+				nameWidth, truncate(name, nameWidth), coverageBar(summary.averageModern(), 20),
+				summary.averageModern(), summary.compliant, summary.resources))
+		}
+		return fmt.Sprintf("%-*s %-20s %4s %s", nameWidth, "Stack", "Modern Coverage", "", "Resources"), lines
+	}
+	nameWidth := (width - 40) / 2
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+	for _, row := range t.rows {
+		text := strings.ToLower(strings.Join([]string{row.resourceType, row.name, row.stack, row.missing}, " "))
+		if !strings.Contains(text, filter) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%-20s %-*s %-*s %8s %s", truncate(row.resourceType, 20),
+			nameWidth, truncate(row.name, nameWidth), nameWidth, truncate(row.stack, nameWidth), row.coverage, row.missing))
+	}
+	return fmt.Sprintf("%-20s %-*s %-*s %8s %s", "Type", nameWidth, "Resource Name", nameWidth, "Stack Name", "Coverage", "Missing Tags"), lines
+}
+
+// render draws the screen, the progress while scanning and the browser after
+func (t *TUI) render() {
+	t.rendered = time.Now()
+	width, height := t.size()
+	header, lines := t.lines(width)
+	rows := height - 4
+	if t.selected >= len(lines) {
+		t.selected = len(lines) - 1
+	}
+	if t.selected < 0 {
+		t.selected = 0
+	}
+	if t.selected < t.offset {
+		t.offset = t.selected
+	} else if t.selected >= t.offset+rows {
+		t.offset = t.selected - rows + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	total := t.stacks.total()
+	status := fmt.Sprintf("%d resources, %d compliant, %d%% modern coverage", total.resources, total.compliant, total.averageModern())
+	if t.current != "" {
+		status = "scanning " + t.current + " - " + status
+	}
+	fmt.Fprintf(&b, "\x1b[1m%s\x1b[0m\r\n\x1b[4m%s\x1b[0m\r\n", truncate(status, width), truncate(header, width))
+	for i := t.offset; i < len(lines) && i < t.offset+rows; i++ {
+		line := truncate(lines[i], width)
+		if i == t.selected && t.current == "" {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		b.WriteString(line + "\r\n")
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H", height)
+	if t.filtering {
+		fmt.Fprintf(&b, "/%s", t.filter)
+	} else if t.current == "" {
+		footer := "tab: stacks/resources  ↑↓ pgup pgdn: scroll  /: filter  esc: clear  q: quit"
+		if t.filter != "" {
+			footer += "  filter: " + t.filter
+		}
+		b.WriteString(truncate(footer, width))
+	}
+	io.WriteString(t.out, b.String())
+}
+
+// coverageBar draws the coverage as a bar of width characters
+func coverageBar(coverage int, width int) string {
+	filled := coverage * width / 100
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// truncate cuts s to width characters
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
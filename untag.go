@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// untag runs the untag subcommand: untag [-keys keys] [-dry-run] [-rate n] [-log file]
+// [-emit-script file] [-- report options] search, removing the deprecated keys from the resources already
+// carrying their modern equivalent
+func untag(w io.Writer, stderr io.Writer, args []string) error {
+	fs := flag.NewFlagSet("aws-tag-report untag", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var keys []string
+	fs.Var((*listFlag)(&keys), "keys", "deprecated tag keys to remove, defaults to the classic keys of the migration of the tag policy")
+	var flags taggerFlags
+	flags.register(fs, "removed")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: aws-tag-report untag [options] [-- report options] searchString"+
+			"\n\tremoves the deprecated keys from the resources carrying the modern key they migrate to, logging"+
+			"\n\tthe result of each resource; the report is only written with -output"+
+			"\noptions:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var removal *untagReporter
+	err := tagScan(w, stderr, fs.Args(), &flags, func(tagger *Tagger) Reporter {
+		removal = &untagReporter{w: stderr, tagger: tagger, keys: keys}
+		return removal
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "%d resources carrying deprecated keys, %d failed, %d without an ARN\n",
+		removal.changes, removal.tagger.failed, removal.skipped)
+	if removal.tagger.failed > 0 {
+		return fmt.Errorf("untagging failed for %d resources", removal.tagger.failed)
+	}
+	return nil
+}
+
+// untagReporter removes from each resource the deprecated keys whose modern equivalent,
+// from the migration of the tag policy, it carries
+type untagReporter struct {
+	w      io.Writer
+	tagger *Tagger
+	// keys are the deprecated keys, resolved against the policy on the first resource
+	keys     []string
+	resolved bool
+	changes  int
+	skipped  int
+}
+
+// resolve keeps the keys migrating to another modern key, every classic key of the migration
+// by default, the policy being loaded by the scan
+func (r *untagReporter) resolve() {
+	r.resolved = true
+	if len(r.keys) == 0 {
+		for key := range classicToModern {
+			r.keys = append(r.keys, key)
+		}
+		sort.Strings(r.keys)
+	}
+	var keys []string
+	for _, key := range r.keys {
+		if modernKey, ok := classicToModern[key]; ok && strings.EqualFold(modernKey, key) {
+			// the key is kept as is by the modern scheme
+			continue
+		} else if ok {
+			keys = append(keys, key)
+		} else {
+			fmt.Fprintf(r.w, "%s has no modern equivalent in the migration of the tag policy, kept\n", key)
+		}
+	}
+	r.keys = keys
+}
+
+func (r *untagReporter) Add(result Result) {
+	if result.Finding == nil {
+		return
+	}
+	resource, tags := result.Resource, result.Tags
+	if !r.resolved {
+		r.resolve()
+	}
+	var remove []string
+	for _, key := range r.keys {
+		if _, ok := tags[key]; !ok {
+			continue
+		}
+		if value, ok := lookupTag(tags, classicToModern[key]); ok && value != "" {
+			remove = append(remove, key)
+		}
+	}
+	if len(remove) == 0 {
+		return
+	}
+	r.changes++
+	arn := result.Arn
+	if arn == "" {
+		fmt.Fprintf(r.w, "%s %s has no known ARN, skipped\n", resource.Type, resource.Name)
+		r.skipped++
+		return
+	}
+	r.tagger.untag(arn, remove)
+}
+
+func (r *untagReporter) Write() {
+}
+
+func (r *untagReporter) Close() {
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WatchState remembers the state of each resource between the scans of -watch, the
+// scans after the first only reporting the resources whose state changed
+type WatchState struct {
+	w        io.Writer
+	scanned  bool
+	previous map[string]string
+	current  map[string]string
+}
+
+func newWatchState(w io.Writer) *WatchState {
+	return &WatchState{
+		w:        w,
+		previous: make(map[string]string),
+		current:  make(map[string]string),
+	}
+}
+
+// changed records the state of the resource, returning whether it differs from the
+// previous scan; every resource changed on the first scan
+func (s *WatchState) changed(key string, state string) bool {
+	s.current[key] = state
+	previous, ok := s.previous[key]
+	return !s.scanned || !ok || previous != state
+}
+
+// next completes a scan, warning about the resources that disappeared since the previous one
+func (s *WatchState) next() {
+	removed := 0
+	for key := range s.previous {
+		if _, ok := s.current[key]; !ok {
+			removed++
+		}
+	}
+	if s.scanned && removed > 0 {
+		fmt.Fprintf(s.w, "%d resources removed since the last scan\n", removed)
+	}
+	s.scanned = true
+	s.previous = s.current
+	s.current = make(map[string]string)
+}
+
+// watchReporter only forwards the resources whose state changed since the previous scan
+type watchReporter struct {
+	Reporter
+	state *WatchState
+}
+
+func (r watchReporter) Add(result Result) {
+	resource := result.Resource
+	var state string
+	switch finding := result.Finding; {
+	case result.Err != nil:
+		state = "ERROR " + result.Err.Error()
+	case finding == nil:
+		state = "NOT_SUPPORTED"
+	default:
+		state = fmt.Sprintf("%d %s|%s|%s", finding.ModernCoverage, strings.Join(finding.Missing, ","),
+			strings.Join(finding.Invalid, ","), strings.Join(resource.Violations, ","))
+	}
+	if r.state.changed(baselineKey(result.Arn, resource.Stack.Name, resource.Name), state) {
+		r.Reporter.Add(result)
+	}
+}
+
+func (r watchReporter) Close() {
+	r.Reporter.Close()
+	r.state.next()
+}
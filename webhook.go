@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// headerFlag collects http headers as "Name: value", the flag being repeated for each header
+type headerFlag []string
+
+func (h *headerFlag) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlag) Set(value string) error {
+	if name, _ := splitHeader(value); name == "" {
+		return fmt.Errorf("expected a header as \"Name: value\"")
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// splitHeader splits a "Name: value" header, the value expanding the ${VAR} environment
+// variables so secrets stay off the command line
+func splitHeader(header string) (string, string) {
+	i := strings.Index(header, ":")
+	if i <= 0 {
+		return "", ""
+	}
+	return strings.TrimSpace(header[:i]), os.ExpandEnv(strings.TrimSpace(header[i+1:]))
+}
+
+// parseWebhookURL checks the webhook is an https url
+func parseWebhookURL(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid -webhook-url %q, expected an https url", webhook)
+	}
+	return nil
+}
+
+// WebhookReport posts the resources to a webhook as a json array of the records of the
+// json report, all at once when the run is complete or by batches as they are scanned
+type WebhookReport struct {
+	url     string
+	headers http.Header
+	batch   int
+	client  *http.Client
+	records *JSONReport
+	buffer  bytes.Buffer
+	pending int
+	posted  bool
+}
+
+func NewWebhookReporter(webhook string, headers []string, batch int, baseline *Baseline) *WebhookReport {
+	r := &WebhookReport{
+		url:     webhook,
+		headers: make(http.Header),
+		batch:   batch,
+		client:  &http.Client{Timeout: time.Minute},
+	}
+	for _, header := range headers {
+		name, value := splitHeader(header)
+		r.headers.Add(name, value)
+	}
+	r.records = &JSONReport{
+		w:        bufio.NewWriter(&r.buffer),
+		lines:    true,
+		output:   &Output{},
+		baseline: baseline,
+	}
+	return r
+}
+
+func (r *WebhookReport) Add(result Result) {
+	r.records.Add(result)
+	r.added()
+}
+
+// added posts the pending records once they make a batch
+func (r *WebhookReport) added() {
+	r.pending++
+	if r.batch > 0 && r.pending == r.batch {
+		r.post()
+	}
+}
+
+// Write is a no-op, the records are posted by batch or on Close
+func (r *WebhookReport) Write() {
+}
+
+// Close posts the records left, an empty array when no resource was scanned
+func (r *WebhookReport) Close() {
+	if r.pending > 0 || !r.posted {
+		r.post()
+	}
+}
+
+// post sends the pending records as a json array
+func (r *WebhookReport) post() {
+	lines := bytes.Split(bytes.TrimSpace(r.buffer.Bytes()), []byte("\n"))
+	if r.pending == 0 {
+		lines = nil
+	}
+	body := append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')
+	r.buffer.Reset()
+	r.pending = 0
+	r.posted = true
+
+	request, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		panic(err.Error())
+	}
+	for name, values := range r.headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := r.client.Do(request)
+	if err != nil {
+		panic(err.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		panic(fmt.Sprintf("webhook responded %s", response.Status))
+	}
+}